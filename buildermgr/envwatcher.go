@@ -41,7 +41,8 @@ const (
 	GET_BUILDER requestType = iota
 	CLEANUP_BUILDERS
 
-	LABEL_ENV_NAME            = "envName"
+	LABEL_ENV_NAME = "envName"
+	// the value is the crd.EnvironmentVersion; the name predates it
 	LABEL_ENV_RESOURCEVERSION = "envResourceVersion"
 )
 
@@ -125,14 +126,14 @@ func makeEnvironmentWatcher(fissionClient *crd.FissionClient,
 	return envWatcher
 }
 
-func (envw *environmentWatcher) getCacheKey(envName string, envResourceVersion string) string {
-	return fmt.Sprintf("%v-%v", envName, envResourceVersion)
+func (envw *environmentWatcher) getCacheKey(envName string, envVersion string) string {
+	return fmt.Sprintf("%v-%v", envName, envVersion)
 }
 
-func (envw *environmentWatcher) getLabels(envName string, envResourceVersion string) map[string]string {
+func (envw *environmentWatcher) getLabels(envName string, envVersion string) map[string]string {
 	return map[string]string{
 		LABEL_ENV_NAME:            envName,
-		LABEL_ENV_RESOURCEVERSION: envResourceVersion,
+		LABEL_ENV_RESOURCEVERSION: envVersion,
 	}
 }
 
@@ -208,7 +209,7 @@ func (envw *environmentWatcher) service() {
 		req := <-envw.requestChan
 		switch req.requestType {
 		case GET_BUILDER:
-			key := envw.getCacheKey(req.env.Metadata.Name, req.env.Metadata.ResourceVersion)
+			key := envw.getCacheKey(req.env.Metadata.Name, crd.EnvironmentVersion(req.env))
 			builderInfo, ok := envw.cache[key]
 			if !ok {
 				builderInfo, err := envw.createBuilder(req.env)
@@ -224,7 +225,7 @@ func (envw *environmentWatcher) service() {
 			latestEnvList := make(map[string]*crd.Environment)
 			for i := range req.envList {
 				env := req.envList[i]
-				key := envw.getCacheKey(env.Metadata.Name, env.Metadata.ResourceVersion)
+				key := envw.getCacheKey(env.Metadata.Name, crd.EnvironmentVersion(&env))
 				latestEnvList[key] = &env
 			}
 
@@ -293,7 +294,7 @@ func (envw *environmentWatcher) createBuilder(env *crd.Environment) (*builderInf
 	var svc *apiv1.Service
 	var deploy *v1beta1.Deployment
 
-	sel := envw.getLabels(env.Metadata.Name, env.Metadata.ResourceVersion)
+	sel := envw.getLabels(env.Metadata.Name, crd.EnvironmentVersion(env))
 
 	svcList, err := envw.getBuilderServiceList(sel)
 	if err != nil {
@@ -394,8 +395,8 @@ func (envw *environmentWatcher) getBuilderServiceList(sel map[string]string) ([]
 }
 
 func (envw *environmentWatcher) createBuilderService(env *crd.Environment) (*apiv1.Service, error) {
-	name := envw.getCacheKey(env.Metadata.Name, env.Metadata.ResourceVersion)
-	sel := envw.getLabels(env.Metadata.Name, env.Metadata.ResourceVersion)
+	name := envw.getCacheKey(env.Metadata.Name, crd.EnvironmentVersion(env))
+	sel := envw.getLabels(env.Metadata.Name, crd.EnvironmentVersion(env))
 	service := apiv1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: envw.builderNamespace,
@@ -450,8 +451,8 @@ func (envw *environmentWatcher) createBuilderDeployment(env *crd.Environment) (*
 	sharedMountPath := "/packages"
 	sharedCfgMapPath := "/configs"
 	sharedSecretPath := "/secrets"
-	name := envw.getCacheKey(env.Metadata.Name, env.Metadata.ResourceVersion)
-	sel := envw.getLabels(env.Metadata.Name, env.Metadata.ResourceVersion)
+	name := envw.getCacheKey(env.Metadata.Name, crd.EnvironmentVersion(env))
+	sel := envw.getLabels(env.Metadata.Name, crd.EnvironmentVersion(env))
 	var replicas int32 = 1
 
 	podAnnotation := make(map[string]string)
@@ -574,7 +575,8 @@ func (envw *environmentWatcher) createBuilderDeployment(env *crd.Environment) (*
 			},
 		},
 	}
-	log.Printf("Creating builder deployment: %v", envw.getCacheKey(env.Metadata.Name, env.Metadata.ResourceVersion))
+	fission.ApplyScheduling(&deployment.Spec.Template, env.Spec.Scheduling)
+	log.Printf("Creating builder deployment: %v", envw.getCacheKey(env.Metadata.Name, crd.EnvironmentVersion(env)))
 	_, err := envw.kubernetesClient.ExtensionsV1beta1().Deployments(envw.builderNamespace).Create(deployment)
 	if err != nil {
 		return nil, err
@@ -120,7 +120,7 @@ func (pkgw *packageWatcher) build(buildCache *cache.Cache, srcpkg *crd.Package)
 
 			// Filter non-matching pods
 			if pod.ObjectMeta.Labels[LABEL_ENV_NAME] != env.Metadata.Name ||
-				pod.ObjectMeta.Labels[LABEL_ENV_RESOURCEVERSION] != env.Metadata.ResourceVersion {
+				pod.ObjectMeta.Labels[LABEL_ENV_RESOURCEVERSION] != crd.EnvironmentVersion(env) {
 				continue
 			}
 
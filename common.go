@@ -25,9 +25,12 @@ import (
 	"runtime/debug"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/imdario/mergo"
+	"github.com/robfig/cron"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apiv1 "k8s.io/client-go/pkg/api/v1"
 )
 
@@ -88,6 +91,93 @@ func MergeContainerSpecs(specs ...*apiv1.Container) apiv1.Container {
 	return *result
 }
 
+// ApplyScheduling sets the scheduling controls of a pod template.
+//
+// The order of the arguments indicates which spec has precedence, as in MergeContainerSpecs.
+// The node selector, affinity, tolerations and spread topology keys are each taken from the
+// first spec that sets them. Pods are spread by preferring not to schedule them next to pods
+// with the template's labels, so the template's labels must be set first.
+func ApplyScheduling(podTemplate *apiv1.PodTemplateSpec, specs ...*Scheduling) {
+	podSpec := &podTemplate.Spec
+	var spreadTopologyKeys []string
+	for i := len(specs) - 1; i >= 0; i-- {
+		spec := specs[i]
+		if spec == nil {
+			continue
+		}
+		if spec.NodeSelector != nil {
+			podSpec.NodeSelector = spec.NodeSelector
+		}
+		if spec.Affinity != nil {
+			podSpec.Affinity = spec.Affinity
+		}
+		if spec.Tolerations != nil {
+			podSpec.Tolerations = spec.Tolerations
+		}
+		if spec.SpreadTopologyKeys != nil {
+			spreadTopologyKeys = spec.SpreadTopologyKeys
+		}
+	}
+	if len(spreadTopologyKeys) == 0 {
+		return
+	}
+
+	// Copy the affinity rather than change the spec's.
+	affinity := apiv1.Affinity{}
+	if podSpec.Affinity != nil {
+		affinity = *podSpec.Affinity
+	}
+	antiAffinity := apiv1.PodAntiAffinity{}
+	if affinity.PodAntiAffinity != nil {
+		antiAffinity = *affinity.PodAntiAffinity
+	}
+	preferred := make([]apiv1.WeightedPodAffinityTerm, 0,
+		len(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution)+len(spreadTopologyKeys))
+	preferred = append(preferred, antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution...)
+	for _, key := range spreadTopologyKeys {
+		preferred = append(preferred, apiv1.WeightedPodAffinityTerm{
+			Weight: 100,
+			PodAffinityTerm: apiv1.PodAffinityTerm{
+				LabelSelector: &metav1.LabelSelector{MatchLabels: podTemplate.Labels},
+				TopologyKey:   key,
+			},
+		})
+	}
+	antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = preferred
+	affinity.PodAntiAffinity = &antiAffinity
+	podSpec.Affinity = &affinity
+}
+
+// ApplyPodSecurity sets the service account of a pod template, and the
+// security context and seccomp profile of its container running the
+// function.
+//
+// The order of the arguments indicates which spec has precedence, as in ApplyScheduling.
+func ApplyPodSecurity(podTemplate *apiv1.PodTemplateSpec, container string, specs ...*PodSecurity) {
+	for i := len(specs) - 1; i >= 0; i-- {
+		spec := specs[i]
+		if spec == nil {
+			continue
+		}
+		if len(spec.ServiceAccountName) > 0 {
+			podTemplate.Spec.ServiceAccountName = spec.ServiceAccountName
+		}
+		if spec.SecurityContext != nil {
+			for j := range podTemplate.Spec.Containers {
+				if podTemplate.Spec.Containers[j].Name == container {
+					podTemplate.Spec.Containers[j].SecurityContext = spec.SecurityContext
+				}
+			}
+		}
+		if len(spec.SeccompProfile) > 0 {
+			if podTemplate.ObjectMeta.Annotations == nil {
+				podTemplate.ObjectMeta.Annotations = make(map[string]string)
+			}
+			podTemplate.ObjectMeta.Annotations[SECCOMP_CONTAINER_ANNOTATION_PREFIX+container] = spec.SeccompProfile
+		}
+	}
+}
+
 // IsNetworkDialError returns true if its a network dial error
 func IsNetworkDialError(err error) bool {
 	netErr, ok := err.(net.Error)
@@ -104,6 +194,45 @@ func IsNetworkDialError(err error) bool {
 	return false
 }
 
+// ParseCIDROrIP parses a CIDR, or a single IP address which is treated
+// as a network containing only that address.
+func ParseCIDROrIP(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, ipNet, err := net.ParseCIDR(s)
+		return ipNet, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address: %v", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// WarmInstances returns the number of instances that the warm schedule
+// keeps the function at, at the given time; 0 if no window is open.
+func (es ExecutionStrategy) WarmInstances(now time.Time) int {
+	instances := 0
+	for _, w := range es.WarmSchedule {
+		if w.MinInstances <= instances || w.Duration <= 0 {
+			continue
+		}
+		sched, err := cron.Parse(w.Cron)
+		if err != nil {
+			// rejected by validation
+			continue
+		}
+		// the window is open if it opened within the last Duration
+		opened := now.Add(-time.Duration(w.Duration) * time.Second)
+		if !sched.Next(opened).After(now) {
+			instances = w.MinInstances
+		}
+	}
+	return instances
+}
+
 // IsReadyPod checks that all containers in a pod are ready and returns true if so
 func IsReadyPod(pod *apiv1.Pod) bool {
 	// since its a utility function, just ensuring there is no nil pointer exception
@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"os"
 	"runtime/debug"
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
@@ -40,8 +41,13 @@ type (
 		storageServiceUrl string
 		builderManagerUrl string
 		workflowApiUrl    string
+		executorUrl       string
 		functionNamespace string
 		useIstio          bool
+
+		// whether the executor runs environments with a local
+		// command; they're rejected otherwise
+		localExecutor bool
 	}
 
 	logDBConfig struct {
@@ -75,6 +81,13 @@ func MakeAPI() (*API, error) {
 		api.workflowApiUrl = "http://workflows-apiserver"
 	}
 
+	u = os.Getenv("EXECUTOR_URL")
+	if len(u) > 0 {
+		api.executorUrl = strings.TrimSuffix(u, "/")
+	} else {
+		api.executorUrl = "http://executor"
+	}
+
 	fnNs := os.Getenv("FISSION_FUNCTION_NAMESPACE")
 	if len(fnNs) > 0 {
 		api.functionNamespace = fnNs
@@ -82,6 +95,8 @@ func MakeAPI() (*API, error) {
 		api.functionNamespace = "fission-function"
 	}
 
+	api.localExecutor, _ = strconv.ParseBool(os.Getenv("ENABLE_LOCAL_EXECUTOR"))
+
 	return api, err
 }
 
@@ -189,6 +204,10 @@ func (api *API) Serve(port int) {
 	r.HandleFunc("/v2/triggers/messagequeue/{mqTrigger}", api.MessageQueueTriggerApiUpdate).Methods("PUT")
 	r.HandleFunc("/v2/triggers/messagequeue/{mqTrigger}", api.MessageQueueTriggerApiDelete).Methods("DELETE")
 
+	r.HandleFunc("/v2/funcsvcs", api.ExecutorProxy).Methods("GET")
+	r.HandleFunc("/v2/funcsvcs/{function}", api.ExecutorProxy).Methods("DELETE")
+	r.HandleFunc("/v2/executortypes", api.ExecutorProxy).Methods("GET")
+
 	r.HandleFunc("/v2/deleteTpr", api.Tpr2crdApi).Methods("DELETE")
 
 	r.HandleFunc("/proxy/{dbType}", api.FunctionLogsApiPost).Methods("POST")
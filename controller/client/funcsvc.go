@@ -0,0 +1,83 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+)
+
+// FunctionServiceList lists the function services cached by the executor,
+// only those of the given function if m isn't nil.
+func (c *Client) FunctionServiceList(m *metav1.ObjectMeta) ([]fission.FunctionServiceInfo, error) {
+	relativeUrl := "funcsvcs"
+	if m != nil {
+		relativeUrl += fmt.Sprintf("?function=%v&namespace=%v", m.Name, m.Namespace)
+	}
+
+	resp, err := http.Get(c.url(relativeUrl))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := c.handleResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]fission.FunctionServiceInfo, 0)
+	err = json.Unmarshal(body, &infos)
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// FunctionServiceEvict deletes the function's services, so that the next
+// request to the function gets a fresh one.
+func (c *Client) FunctionServiceEvict(m *metav1.ObjectMeta) error {
+	relativeUrl := fmt.Sprintf("funcsvcs/%v", m.Name)
+	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
+	return c.delete(relativeUrl)
+}
+
+// ExecutorTypeList lists the executor types the executor runs.
+func (c *Client) ExecutorTypeList() ([]fission.ExecutorType, error) {
+	resp, err := http.Get(c.url("executortypes"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := c.handleResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]fission.ExecutorType, 0)
+	err = json.Unmarshal(body, &names)
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
@@ -29,6 +29,17 @@ import (
 	"github.com/fission/fission/crd"
 )
 
+// checkLocalCommand rejects environments with a local command unless the
+// local executor is enabled: the executor would run the command in its
+// own pod, with its service account.
+func (a *API) checkLocalCommand(env *crd.Environment) error {
+	if len(env.Spec.Runtime.LocalCommand) == 0 || a.localExecutor {
+		return nil
+	}
+	return fission.MakeError(fission.ErrorInvalidArgument,
+		"Runtime.LocalCommand is only allowed when the local executor is enabled (ENABLE_LOCAL_EXECUTOR)")
+}
+
 func (a *API) EnvironmentApiList(w http.ResponseWriter, r *http.Request) {
 	envs, err := a.fissionClient.Environments(metav1.NamespaceAll).List(metav1.ListOptions{})
 	if err != nil {
@@ -60,6 +71,12 @@ func (a *API) EnvironmentApiCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = a.checkLocalCommand(&env)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
 	enew, err := a.fissionClient.Environments(env.Metadata.Namespace).Create(&env)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -122,6 +139,12 @@ func (a *API) EnvironmentApiUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = a.checkLocalCommand(&env)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
 	enew, err := a.fissionClient.Environments(env.Metadata.Namespace).Update(&env)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -28,7 +28,9 @@ import (
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/pkg/api"
 	apiv1 "k8s.io/client-go/pkg/api/v1"
 	restclient "k8s.io/client-go/rest"
@@ -73,6 +75,15 @@ func (a *API) FunctionApiCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = a.checkFunctionEnv(&f)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	// not stored; see FunctionStatus
+	f.Status = fission.FunctionStatus{}
+
 	fnew, err := a.fissionClient.Functions(f.Metadata.Namespace).Create(&f)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -102,6 +113,7 @@ func (a *API) FunctionApiGet(w http.ResponseWriter, r *http.Request) {
 		a.respondWithError(w, err)
 		return
 	}
+	f.Status = a.functionStatus(f)
 
 	resp, err := json.Marshal(f)
 	if err != nil {
@@ -111,6 +123,41 @@ func (a *API) FunctionApiGet(w http.ResponseWriter, r *http.Request) {
 	a.respondWithSuccess(w, resp)
 }
 
+// checkFunctionEnv rejects functions with environment variables that
+// their environment can't set. Functions may be created before their
+// environment; the executor then refuses to specialize them.
+func (a *API) checkFunctionEnv(f *crd.Function) error {
+	if len(f.Spec.Env) == 0 {
+		return nil
+	}
+	env, err := a.fissionClient.Environments(f.Spec.Environment.Namespace).Get(f.Spec.Environment.Name)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	err = f.Spec.ValidateEnvSupport(&env.Spec)
+	if err != nil {
+		return fission.MakeError(fission.ErrorInvalidArgument, err.Error())
+	}
+	return nil
+}
+
+// functionStatus reads the status of the function from its events.
+func (a *API) functionStatus(f *crd.Function) fission.FunctionStatus {
+	events, err := a.kubernetesClient.CoreV1().Events(f.Metadata.Namespace).List(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.uid", string(f.Metadata.UID)).String(),
+	})
+	if err != nil {
+		log.Printf("Error getting events of function %v: %v", f.Metadata.Name, err)
+		return fission.FunctionStatus{}
+	}
+	return fission.FunctionStatus{
+		Specialization: fission.LatestSpecialization(events.Items),
+	}
+}
+
 func (a *API) FunctionApiUpdate(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["function"]
@@ -134,6 +181,14 @@ func (a *API) FunctionApiUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = a.checkFunctionEnv(&f)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	f.Status = fission.FunctionStatus{}
+
 	fnew, err := a.fissionClient.Functions(f.Metadata.Namespace).Update(&f)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -17,7 +17,9 @@ limitations under the License.
 package crd
 
 import (
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -31,3 +33,18 @@ import (
 func CacheKey(metadata *metav1.ObjectMeta) string {
 	return fmt.Sprintf("%v_%v", metadata.UID, metadata.ResourceVersion)
 }
+
+// EnvironmentVersion identifies the contents of an environment's spec.
+// Unlike the resource version, it doesn't change when only the status of
+// the environment is updated, so the pools and builders created from
+// the spec aren't replaced then.
+func EnvironmentVersion(env *Environment) string {
+	spec, err := json.Marshal(env.Spec)
+	if err != nil {
+		// not expected for a spec that was decoded from JSON
+		return env.Metadata.ResourceVersion
+	}
+	h := fnv.New32a()
+	h.Write(spec)
+	return fmt.Sprintf("%08x", h.Sum32())
+}
@@ -58,6 +58,8 @@ type (
 		metav1.TypeMeta `json:",inline"`
 		Metadata        metav1.ObjectMeta    `json:"metadata"`
 		Spec            fission.FunctionSpec `json:"spec"`
+
+		Status fission.FunctionStatus `json:"status"`
 	}
 	FunctionList struct {
 		metav1.TypeMeta `json:",inline"`
@@ -71,6 +73,8 @@ type (
 		metav1.TypeMeta `json:",inline"`
 		Metadata        metav1.ObjectMeta       `json:"metadata"`
 		Spec            fission.EnvironmentSpec `json:"spec"`
+
+		Status fission.EnvironmentStatus `json:"status"`
 	}
 	EnvironmentList struct {
 		metav1.TypeMeta `json:",inline"`
@@ -20,6 +20,9 @@ const (
 
 var specialized bool
 
+// functionEnv holds the environment variables of the specialized function
+var functionEnv map[string]string
+
 type (
 	BinaryServer struct {
 		fetchedCodePath  string
@@ -41,6 +44,9 @@ type (
 		// URL to expose this function at. Optional; defaults
 		// to "/".
 		URL string `json:"url"`
+
+		// Environment variables of the function. Optional.
+		Env map[string]string `json:"env"`
 	}
 )
 
@@ -102,6 +108,7 @@ func (bs *BinaryServer) SpecializeHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	fmt.Println("Specializing ...")
+	functionEnv = request.Env
 	specialized = true
 	fmt.Println("Done")
 }
@@ -118,6 +125,9 @@ func (bs *BinaryServer) InvocationHandler(w http.ResponseWriter, r *http.Request
 	execEnv.SetEnv(&EnvVar{"REQUEST_METHOD", r.Method})
 	execEnv.SetEnv(&EnvVar{"REQUEST_URI", r.RequestURI})
 	execEnv.SetEnv(&EnvVar{"CONTENT_LENGTH", fmt.Sprintf("%d", r.ContentLength)})
+	for name, value := range functionEnv {
+		execEnv.SetEnv(&EnvVar{name, value})
+	}
 
 	for header, val := range r.Header {
 		execEnv.SetEnv(&EnvVar{fmt.Sprintf("HTTP_%s", strings.ToUpper(header)), val[0]})
@@ -155,7 +165,12 @@ func (bs *BinaryServer) InvocationHandler(w http.ResponseWriter, r *http.Request
 }
 
 func main() {
-	codePath := flag.String("c", DEFAULT_CODE_PATH, "Path to expected fetched executable.")
+	defaultCodePath := DEFAULT_CODE_PATH
+	if path := os.Getenv("CODE_PATH"); len(path) > 0 {
+		// set by the local executor
+		defaultCodePath = path
+	}
+	codePath := flag.String("c", defaultCodePath, "Path to expected fetched executable.")
 	internalCodePath := flag.String("i", DEFAULT_INTERNAL_CODE_PATH, "Path to specialized executable.")
 	flag.Parse()
 	absInternalCodePath, err := filepath.Abs(*internalCodePath)
@@ -170,8 +185,12 @@ func main() {
 	http.HandleFunc("/specialize", server.SpecializeHandler)
 	http.HandleFunc("/v2/specialize", server.SpecializeHandler)
 
-	fmt.Println("Listening on 8888 ...")
-	err = http.ListenAndServe(":8888", nil)
+	port := os.Getenv("PORT")
+	if len(port) == 0 {
+		port = "8888"
+	}
+	fmt.Printf("Listening on %v ...\n", port)
+	err = http.ListenAndServe(fmt.Sprintf(":%v", port), nil)
 	if err != nil {
 		panic(err)
 	}
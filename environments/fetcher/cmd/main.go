@@ -109,6 +109,29 @@ func specializePod(f *fetcher.Fetcher, fetchPayload *string, loadPayload *string
 		log.Fatalf("Error parsing environment version %v, error: %v", os.Getenv("ENV_VERSION"), err)
 	}
 
+	// pass the function's environment variables to the environment
+	// server, which sets them when loading the function
+	if envVersion == 2 && len(fetchReq.Env) > 0 {
+		var loadReq fission.FunctionLoadRequest
+		err = json.Unmarshal([]byte(*loadPayload), &loadReq)
+		if err != nil {
+			log.Fatalf("Error parsing load request: %v", err)
+		}
+		namespace := fetchReq.Package.Namespace
+		if loadReq.FunctionMetadata != nil {
+			namespace = loadReq.FunctionMetadata.Namespace
+		}
+		loadReq.Env, err = f.ResolveEnv(namespace, fetchReq.Env)
+		if err != nil {
+			log.Fatalf("Error resolving environment variables: %v", err)
+		}
+		payload, err := json.Marshal(loadReq)
+		if err != nil {
+			log.Fatalf("Error encoding load request: %v", err)
+		}
+		*loadPayload = string(payload)
+	}
+
 	maxRetries := 30
 	var contentType string
 	var specializeURL string
@@ -19,6 +19,7 @@ import (
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
 
 	"github.com/fission/fission"
 	"github.com/fission/fission/crd"
@@ -36,6 +37,11 @@ type (
 		Filename      string                       `json:"filename"`
 		Secrets       []fission.SecretReference    `json:"secretList"`
 		ConfigMaps    []fission.ConfigMapReference `json:"configMapList"`
+
+		// Environment variables of the function, resolved into the
+		// load request when specializing on startup. Values taken from
+		// secrets are not part of the request.
+		Env []apiv1.EnvVar `json:"env,omitempty"`
 	}
 
 	// UploadRequest send from builder manager describes which
@@ -83,6 +89,19 @@ func MakeFetcher(sharedVolumePath string, sharedSecretPath string, sharedConfigP
 	if err != nil {
 		return nil, err
 	}
+	return MakeFetcherWithClients(sharedVolumePath, sharedSecretPath, sharedConfigPath, fissionClient, kubeClient)
+}
+
+// MakeFetcherWithClients creates a fetcher that uses existing clients, for
+// fetching in-process rather than through the fetcher server.
+func MakeFetcherWithClients(sharedVolumePath string, sharedSecretPath string, sharedConfigPath string,
+	fissionClient *crd.FissionClient, kubeClient *kubernetes.Clientset) (*Fetcher, error) {
+	for _, dirPath := range []string{sharedVolumePath, sharedSecretPath, sharedConfigPath} {
+		err := os.MkdirAll(dirPath, os.ModeDir|0700)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return &Fetcher{
 		sharedVolumePath: sharedVolumePath,
 		sharedSecretPath: sharedSecretPath,
@@ -378,6 +397,68 @@ func (fetcher *Fetcher) FetchSecretsAndCfgMaps(secrets []fission.SecretReference
 	return http.StatusOK, nil
 }
 
+// ResolveEnv returns the values of the function's environment variables.
+func (fetcher *Fetcher) ResolveEnv(namespace string, vars []apiv1.EnvVar) (map[string]string, error) {
+	return ResolveEnv(fetcher.kubeClient, namespace, vars)
+}
+
+// ResolveEnv returns the values of a function's environment variables,
+// reading the secret and configmap keys they reference from the
+// function's namespace.
+func ResolveEnv(kubeClient *kubernetes.Clientset, namespace string, vars []apiv1.EnvVar) (map[string]string, error) {
+	if len(vars) == 0 {
+		return nil, nil
+	}
+
+	env := make(map[string]string)
+	secrets := make(map[string]*apiv1.Secret)
+	cfgmaps := make(map[string]*apiv1.ConfigMap)
+	for _, v := range vars {
+		switch {
+		case v.ValueFrom == nil:
+			env[v.Name] = v.Value
+
+		case v.ValueFrom.SecretKeyRef != nil:
+			ref := v.ValueFrom.SecretKeyRef
+			secret, ok := secrets[ref.Name]
+			if !ok {
+				var err error
+				secret, err = kubeClient.CoreV1().Secrets(namespace).Get(ref.Name, metav1.GetOptions{})
+				if err != nil {
+					return nil, fmt.Errorf("failed to get secret %v for environment variable %v: %v", ref.Name, v.Name, err)
+				}
+				secrets[ref.Name] = secret
+			}
+			val, ok := secret.Data[ref.Key]
+			if !ok {
+				return nil, fmt.Errorf("secret %v has no key %v for environment variable %v", ref.Name, ref.Key, v.Name)
+			}
+			env[v.Name] = string(val)
+
+		case v.ValueFrom.ConfigMapKeyRef != nil:
+			ref := v.ValueFrom.ConfigMapKeyRef
+			cfgmap, ok := cfgmaps[ref.Name]
+			if !ok {
+				var err error
+				cfgmap, err = kubeClient.CoreV1().ConfigMaps(namespace).Get(ref.Name, metav1.GetOptions{})
+				if err != nil {
+					return nil, fmt.Errorf("failed to get configmap %v for environment variable %v: %v", ref.Name, v.Name, err)
+				}
+				cfgmaps[ref.Name] = cfgmap
+			}
+			val, ok := cfgmap.Data[ref.Key]
+			if !ok {
+				return nil, fmt.Errorf("configmap %v has no key %v for environment variable %v", ref.Name, ref.Key, v.Name)
+			}
+			env[v.Name] = val
+
+		default:
+			return nil, fmt.Errorf("environment variable %v has an unsupported source", v.Name)
+		}
+	}
+	return env, nil
+}
+
 func (fetcher *Fetcher) UploadHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "only POST is supported on this endpoint", http.StatusMethodNotAllowed)
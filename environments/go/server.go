@@ -13,7 +13,7 @@ import (
 )
 
 const (
-	CODE_PATH = "/userfunc/user"
+	DEFAULT_CODE_PATH = "/userfunc/user"
 )
 
 type (
@@ -32,11 +32,18 @@ type (
 		// URL to expose this function at. Optional; defaults
 		// to "/".
 		URL string `json:"url"`
+
+		// Environment variables of the function. Optional.
+		Env map[string]string `json:"env"`
 	}
 )
 
 var userFunc http.HandlerFunc
 
+// codePath is where v1 specialization loads the function from; the local
+// executor sets CODE_PATH to its own directory.
+var codePath = DEFAULT_CODE_PATH
+
 func loadPlugin(codePath, entrypoint string) http.HandlerFunc {
 
 	// if codepath's a directory, load the file inside it
@@ -90,11 +97,11 @@ func specializeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := os.Stat(CODE_PATH)
+	_, err := os.Stat(codePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte(CODE_PATH + ": not found"))
+			w.Write([]byte(codePath + ": not found"))
 			return
 		} else {
 			panic(err)
@@ -102,7 +109,7 @@ func specializeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	fmt.Println("Specializing ...")
-	userFunc = loadPlugin(CODE_PATH, "Handler")
+	userFunc = loadPlugin(codePath, "Handler")
 	fmt.Println("Done")
 }
 
@@ -129,13 +136,17 @@ func specializeHandlerV2(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if os.IsNotExist(err) {
 			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte(CODE_PATH + ": not found"))
+			w.Write([]byte(loadreq.FilePath + ": not found"))
 			return
 		} else {
 			panic(err)
 		}
 	}
 
+	for name, value := range loadreq.Env {
+		os.Setenv(name, value)
+	}
+
 	fmt.Println("Specializing ...")
 	userFunc = loadPlugin(loadreq.FilePath, loadreq.FunctionName)
 	fmt.Println("Done")
@@ -146,6 +157,10 @@ func readinessProbeHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	if path := os.Getenv("CODE_PATH"); len(path) > 0 {
+		codePath = path
+	}
+
 	http.HandleFunc("/healthz", readinessProbeHandler)
 	http.HandleFunc("/specialize", specializeHandler)
 	http.HandleFunc("/v2/specialize", specializeHandlerV2)
@@ -160,6 +175,10 @@ func main() {
 		userFunc(w, r)
 	})
 
-	fmt.Println("Listening on 8888 ...")
-	http.ListenAndServe(":8888", nil)
+	port := os.Getenv("PORT")
+	if len(port) == 0 {
+		port = "8888"
+	}
+	fmt.Printf("Listening on %v ...\n", port)
+	http.ListenAndServe(fmt.Sprintf(":%v", port), nil)
 }
@@ -26,6 +26,10 @@ def loadv2():
     filepath = body['filepath']
     handler = body['functionName']
 
+    # set the function's environment variables before loading it
+    for name, value in (body.get('env') or {}).items():
+        os.environ[name] = value
+
     # The value of "functionName" is consist of `<module-name>.<function-name>`.
     moduleName, funcName = handler.split(".")
 
@@ -17,18 +17,24 @@ limitations under the License.
 package executor
 
 import (
-	"context"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-multierror"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
 
 	"github.com/fission/fission"
+	"github.com/fission/fission/executor/executortype"
+	"github.com/fission/fission/executor/fscache"
 )
 
 func (executor *Executor) getServiceForFunctionApi(w http.ResponseWriter, r *http.Request) {
@@ -46,6 +52,18 @@ func (executor *Executor) getServiceForFunctionApi(w http.ResponseWriter, r *htt
 		return
 	}
 
+	if executor.following() {
+		// followers answer with the function services they know of,
+		// and leave creating one to the leader
+		if fsvc, ok := executor.getCachedFuncSvc(&m); ok {
+			w.Write([]byte(fsvc.Address))
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		executor.proxyToLeader(w, r)
+		return
+	}
+
 	serviceName, err := executor.getServiceForFunction(&m)
 	if err != nil {
 		code, msg := fission.GetHTTPError(err)
@@ -67,17 +85,9 @@ func (executor *Executor) getServiceForFunctionApi(w http.ResponseWriter, r *htt
 // To make it optimal, plan is to add an eager cache invalidator function that watches for pod deletion events and
 // invalidates the cache entry if the pod address was cached.
 func (executor *Executor) getServiceForFunction(m *metav1.ObjectMeta) (string, error) {
-	// Check function -> svc cache
-	log.Printf("[%v] Checking for cached function service", m.Name)
-	fsvc, err := executor.fsCache.GetByFunction(m)
-	if err == nil {
-		if executor.isValidAddress(fsvc) {
-			// Cached, return svc address
-			return fsvc.Address, nil
-		} else {
-			log.Printf("[%v] Deleting cache entry for invalid address : %s", m.Name, fsvc.Address)
-			executor.fsCache.DeleteEntry(fsvc)
-		}
+	if fsvc, ok := executor.getCachedFuncSvc(m); ok {
+		// Cached, return svc address
+		return fsvc.Address, nil
 	}
 
 	respChan := make(chan *createFuncServiceResponse)
@@ -92,6 +102,102 @@ func (executor *Executor) getServiceForFunction(m *metav1.ObjectMeta) (string, e
 	return resp.funcSvc.Address, resp.err
 }
 
+// getCachedFuncSvc returns the cached function service of the function,
+// if it has a valid one.
+func (executor *Executor) getCachedFuncSvc(m *metav1.ObjectMeta) (*fscache.FuncSvc, bool) {
+	// Check function -> svc cache
+	log.Printf("[%v] Checking for cached function service", m.Name)
+	fsvc, err := executor.fsCache.GetByFunction(m)
+	if err != nil {
+		return nil, false
+	}
+	if !executor.isValidAddress(fsvc) {
+		log.Printf("[%v] Deleting cache entry for invalid address : %s", m.Name, fsvc.Address)
+		executor.fsCache.DeleteEntry(fsvc)
+		return nil, false
+	}
+	return fsvc, true
+}
+
+// getServicesForFunctionApi responds with the addresses of all function
+// services of the function, as a JSON list.
+func (executor *Executor) getServicesForFunctionApi(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request", 500)
+		return
+	}
+
+	// get function metadata
+	m := metav1.ObjectMeta{}
+	err = json.Unmarshal(body, &m)
+	if err != nil {
+		http.Error(w, "Failed to parse request", 400)
+		return
+	}
+
+	if executor.following() {
+		// as for getServiceForFunctionApi
+		if fsvc, ok := executor.getCachedFuncSvc(&m); ok {
+			executor.writeAddresses(w, executor.otherServices(&m, fsvc.Address))
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		executor.proxyToLeader(w, r)
+		return
+	}
+
+	addresses, err := executor.getServicesForFunction(&m)
+	if err != nil {
+		code, msg := fission.GetHTTPError(err)
+		log.Printf("Error: %v: %v", code, msg)
+		http.Error(w, msg, code)
+		return
+	}
+
+	executor.writeAddresses(w, addresses)
+}
+
+func (executor *Executor) writeAddresses(w http.ResponseWriter, addresses []string) {
+	resp, err := json.Marshal(addresses)
+	if err != nil {
+		http.Error(w, "Failed to encode response", 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// getServicesForFunction returns the address of the function's service,
+// as getServiceForFunction does, followed by the addresses of any other
+// valid function services of the function.
+func (executor *Executor) getServicesForFunction(m *metav1.ObjectMeta) ([]string, error) {
+	address, err := executor.getServiceForFunction(m)
+	if err != nil {
+		return nil, err
+	}
+	return executor.otherServices(m, address), nil
+}
+
+// otherServices returns the given address of the function's service,
+// followed by the addresses of the other valid function services of the
+// function.
+func (executor *Executor) otherServices(m *metav1.ObjectMeta, address string) []string {
+	addresses := []string{address}
+	for _, fsvc := range executor.fsCache.ListByFunction(m) {
+		if fsvc.Address == address {
+			continue
+		}
+		if !executor.isValidAddress(fsvc) {
+			log.Printf("[%v] Deleting cache entry for invalid address : %s", m.Name, fsvc.Address)
+			executor.fsCache.DeleteEntry(fsvc)
+			continue
+		}
+		addresses = append(addresses, fsvc.Address)
+	}
+	return addresses
+}
+
 // find funcSvc and update its atime
 func (executor *Executor) tapService(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
@@ -111,6 +217,164 @@ func (executor *Executor) tapService(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// tapServices handles the batched reports from routers: it updates the
+// atime of each function service, and passes concurrency figures on to
+// the backends that scale on them.
+func (executor *Executor) tapServices(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request", 500)
+		return
+	}
+
+	var req fission.TapServicesRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		http.Error(w, "Failed to parse request", 400)
+		return
+	}
+
+	for _, tap := range req.Services {
+		svcHost := strings.TrimPrefix(tap.ServiceUrl, "http://")
+
+		err = executor.fsCache.TouchByAddress(svcHost)
+		if err != nil {
+			log.Printf("funcSvc tap error: %v", err)
+			continue
+		}
+
+		fsvc, err := executor.fsCache.GetByAddress(svcHost)
+		if err != nil {
+			continue
+		}
+		et, ok := executor.executorTypes[fsvc.Executor]
+		if !ok {
+			continue
+		}
+		if observer, ok := et.(executortype.ConcurrencyObserver); ok {
+			observer.ObserveConcurrency(fsvc, req.Reporter, tap.Concurrency)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// funcSvcMatches checks whether the function service is for the function
+// with the given name and namespace. An empty name matches all functions.
+func funcSvcMatches(fsvc *fscache.FuncSvc, name string, namespace string) bool {
+	if len(name) == 0 {
+		return true
+	}
+	return fsvc.Function.Name == name && fsvc.Function.Namespace == namespace
+}
+
+// listFuncSvcs responds with the cached function services, optionally only
+// those of the function given by the "function" and "namespace" query
+// parameters.
+func (executor *Executor) listFuncSvcs(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("function")
+	namespace := r.FormValue("namespace")
+	if len(namespace) == 0 {
+		namespace = metav1.NamespaceDefault
+	}
+
+	infos := make([]fission.FunctionServiceInfo, 0)
+	for _, fsvc := range executor.fsCache.List() {
+		if !funcSvcMatches(fsvc, name, namespace) {
+			continue
+		}
+		info := fission.FunctionServiceInfo{
+			Name:     fsvc.Name,
+			Function: *fsvc.Function,
+			Executor: fsvc.Executor,
+			Address:  fsvc.Address,
+			Ctime:    fsvc.Ctime,
+			Atime:    fsvc.Atime,
+		}
+		if fsvc.Environment != nil {
+			info.Environment = fsvc.Environment.Metadata
+		}
+		for _, obj := range fsvc.KubernetesObjects {
+			info.KubernetesObjects = append(info.KubernetesObjects, apiv1.ObjectReference{
+				Kind:            obj.Kind,
+				Namespace:       obj.Namespace,
+				Name:            obj.Name,
+				UID:             obj.UID,
+				APIVersion:      obj.APIVersion,
+				ResourceVersion: obj.ResourceVersion,
+			})
+		}
+		infos = append(infos, info)
+	}
+
+	resp, err := json.Marshal(infos)
+	if err != nil {
+		http.Error(w, "Failed to encode response", 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// listExecutorTypes lists the executor types this executor runs, sorted,
+// so that clients can check a function's executor type before creating it.
+func (executor *Executor) listExecutorTypes(w http.ResponseWriter, r *http.Request) {
+	names := make([]fission.ExecutorType, 0, len(executor.executorTypes))
+	for name := range executor.executorTypes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+
+	resp, err := json.Marshal(names)
+	if err != nil {
+		http.Error(w, "Failed to encode response", 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// evictFuncSvcs deletes all function services of a function, so that the
+// next request to it gets a fresh one.
+func (executor *Executor) evictFuncSvcs(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["function"]
+	namespace := r.FormValue("namespace")
+	if len(namespace) == 0 {
+		namespace = metav1.NamespaceDefault
+	}
+
+	evicted := 0
+	var result *multierror.Error
+	for _, fsvc := range executor.fsCache.List() {
+		if !funcSvcMatches(fsvc, name, namespace) {
+			continue
+		}
+		et, err := executor.getExecutorType(fsvc.Executor)
+		if err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+		log.Printf("[%v] Evicting function service %v", name, fsvc.Name)
+		err = et.DeleteFuncSvc(fsvc)
+		if err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+		evicted++
+	}
+
+	if result != nil {
+		http.Error(w, result.Error(), 500)
+		return
+	}
+	if evicted == 0 {
+		http.Error(w, fmt.Sprintf("function %v has no function services", name), 404)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
 func (executor *Executor) healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
@@ -118,14 +382,16 @@ func (executor *Executor) healthHandler(w http.ResponseWriter, r *http.Request)
 func (executor *Executor) Serve(port int) {
 	r := mux.NewRouter()
 	r.HandleFunc("/v2/getServiceForFunction", executor.getServiceForFunctionApi).Methods("POST")
-	r.HandleFunc("/v2/tapService", executor.tapService).Methods("POST")
+	r.HandleFunc("/v2/getServicesForFunction", executor.getServicesForFunctionApi).Methods("POST")
+	r.HandleFunc("/v2/tapService", executor.leaderOnly(executor.tapService)).Methods("POST")
+	r.HandleFunc("/v2/tapServices", executor.leaderOnly(executor.tapServices)).Methods("POST")
+	r.HandleFunc("/v2/funcsvcs", executor.leaderOnly(executor.listFuncSvcs)).Methods("GET")
+	r.HandleFunc("/v2/funcsvcs/{function}", executor.leaderOnly(executor.evictFuncSvcs)).Methods("DELETE")
+	r.HandleFunc("/v2/executortypes", executor.listExecutorTypes).Methods("GET")
 	r.HandleFunc("/healthz", executor.healthHandler).Methods("GET")
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
 	address := fmt.Sprintf(":%v", port)
 	log.Printf("starting executor at port %v", port)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	executor.ndm.Run(ctx)
-	executor.gpm.Run(ctx)
 	r.Use(fission.LoggingMiddleware)
 	log.Fatal(http.ListenAndServe(address, r))
 }
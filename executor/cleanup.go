@@ -30,15 +30,18 @@ import (
 
 	"github.com/fission/fission"
 	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/executortype"
 	"github.com/fission/fission/executor/fscache"
 )
 
-// cleanupObjects cleans up resources created by old executortype instances
+// cleanupObjects cleans up resources created by old executortype instances,
+// except the objects in keep, which were adopted by this instance.
 func cleanupObjects(kubernetesClient *kubernetes.Clientset,
 	namespace string,
-	instanceId string) {
+	instanceId string,
+	keep map[types.UID]bool) {
 	go func() {
-		err := cleanup(kubernetesClient, namespace, instanceId)
+		err := cleanup(kubernetesClient, namespace, instanceId, keep)
 		if err != nil {
 			// TODO retry cleanup; logged and ignored for now
 			log.Printf("Failed to cleanup: %v", err)
@@ -46,14 +49,14 @@ func cleanupObjects(kubernetesClient *kubernetes.Clientset,
 	}()
 }
 
-func cleanup(client *kubernetes.Clientset, namespace string, instanceId string) error {
+func cleanup(client *kubernetes.Clientset, namespace string, instanceId string, keep map[types.UID]bool) error {
 
-	err := cleanupServices(client, namespace, instanceId)
+	err := cleanupServices(client, namespace, instanceId, keep)
 	if err != nil {
 		return err
 	}
 
-	err = cleanupHpa(client, namespace, instanceId)
+	err = cleanupHpa(client, namespace, instanceId, keep)
 	if err != nil {
 		return err
 	}
@@ -61,7 +64,7 @@ func cleanup(client *kubernetes.Clientset, namespace string, instanceId string)
 	// Deployments are used for idle pools and can be cleaned up
 	// immediately.  (We should "adopt" these instead of creating
 	// a new pool.)
-	err = cleanupDeployments(client, namespace, instanceId)
+	err = cleanupDeployments(client, namespace, instanceId, keep)
 	if err != nil {
 		return err
 	}
@@ -69,7 +72,7 @@ func cleanup(client *kubernetes.Clientset, namespace string, instanceId string)
 	// through the API doesn't cause the associated ReplicaSet to
 	// be deleted.  (Fixed recently, but we may be running a
 	// version before the fix.)
-	err = cleanupReplicaSets(client, namespace, instanceId)
+	err = cleanupReplicaSets(client, namespace, instanceId, keep)
 	if err != nil {
 		return err
 	}
@@ -81,7 +84,7 @@ func cleanup(client *kubernetes.Clientset, namespace string, instanceId string)
 	// time.
 	time.Sleep(6 * time.Minute)
 
-	err = cleanupPods(client, namespace, instanceId)
+	err = cleanupPods(client, namespace, instanceId, keep)
 	if err != nil {
 		return err
 	}
@@ -89,10 +92,29 @@ func cleanup(client *kubernetes.Clientset, namespace string, instanceId string)
 	return nil
 }
 
-// idleObjectReaper reaps objects after certain idle time
+// functionIdleTimeout returns the idle timeout set on the function, if
+// any. Functions that are kept warm forever, or in an open window of
+// their warm schedule, get a negative timeout.
+func functionIdleTimeout(fn *crd.Function, now time.Time) (time.Duration, bool) {
+	if fn.Spec.InvokeStrategy.ExecutionStrategy.WarmInstances(now) > 0 {
+		return -1, true
+	}
+	idleTimeout := fn.Spec.InvokeStrategy.ExecutionStrategy.IdleTimeout
+	if idleTimeout == nil {
+		return 0, false
+	}
+	if *idleTimeout <= 0 {
+		return -1, true
+	}
+	return time.Duration(*idleTimeout) * time.Second, true
+}
+
+// idleObjectReaper reaps objects after certain idle time, or after the
+// idle timeout of the function if it has one
 func idleObjectReaper(kubeClient *kubernetes.Clientset,
 	fissionClient *crd.FissionClient,
 	fsCache *fscache.FunctionServiceCache,
+	executorTypes map[fission.ExecutorType]executortype.ExecutorType,
 	idlePodReapTime time.Duration) {
 
 	pollSleep := time.Duration(2 * time.Minute)
@@ -110,7 +132,22 @@ func idleObjectReaper(kubeClient *kubernetes.Clientset,
 			envList[env.Metadata.UID] = struct{}{}
 		}
 
-		funcSvcs, err := fsCache.ListOld(idlePodReapTime)
+		fns, err := fissionClient.Functions(meta_v1.NamespaceAll).List(meta_v1.ListOptions{})
+		if err != nil {
+			log.Printf("Failed to get function list: %v", err)
+			continue
+		}
+
+		now := time.Now()
+		idleTimeouts := make(map[types.UID]time.Duration)
+		for i := range fns.Items {
+			fn := fns.Items[i]
+			if timeout, ok := functionIdleTimeout(&fn, now); ok {
+				idleTimeouts[fn.Metadata.UID] = timeout
+			}
+		}
+
+		funcSvcs, err := fsCache.ListOld(idlePodReapTime, idleTimeouts)
 		if err != nil {
 			log.Printf("Error reaping idle pods: %v", err)
 			continue
@@ -126,26 +163,53 @@ func idleObjectReaper(kubeClient *kubernetes.Clientset,
 				continue
 			}
 
-			// Newdeploy manager handles the function delete event and clean cache/kubeobjs itself,
-			// so we ignore the function service cache with newdepoy executor type here.
-			if fsvc.Executor != fscache.NEWDEPLOY {
-				deleted, err := fsCache.DeleteOld(fsvc, idlePodReapTime)
+			minAge := idlePodReapTime
+			if timeout, ok := idleTimeouts[fsvc.Function.UID]; ok {
+				minAge = timeout
+			}
+
+			// Backends that keep the objects of idle functions scale
+			// them down instead, e.g. newdeploy, which also cleans
+			// up after deleted functions itself.
+			et, registered := executorTypes[fsvc.Executor]
+			if scaler, ok := et.(executortype.IdleScaler); registered && ok {
+				scaled, err := scaler.ScaleDownIdle(fsvc, minAge)
 				if err != nil {
-					log.Printf("Error deleting Kubernetes objects for fsvc '%v': %v", fsvc, err)
-					log.Printf("Object Name| Object Kind | Object Namespace")
-					for _, kubeobj := range fsvc.KubernetesObjects {
-						log.Printf("%v | %v | %v", kubeobj.Name, kubeobj.Kind, kubeobj.Namespace)
-					}
+					log.Printf("Error scaling down idle fsvc '%v': %v", fsvc.Name, err)
 				}
-
-				if !deleted {
-					continue
+				if scaled {
+					idleReaps.WithLabelValues(string(fsvc.Executor)).Inc()
 				}
+				continue
+			}
 
+			deleted, err := fsCache.DeleteOld(fsvc, minAge)
+			if err != nil {
+				log.Printf("Error deleting Kubernetes objects for fsvc '%v': %v", fsvc, err)
+				log.Printf("Object Name| Object Kind | Object Namespace")
 				for _, kubeobj := range fsvc.KubernetesObjects {
-					deleteKubeobject(kubeClient, &kubeobj)
+					log.Printf("%v | %v | %v", kubeobj.Name, kubeobj.Kind, kubeobj.Namespace)
 				}
 			}
+
+			if !deleted {
+				continue
+			}
+			idleReaps.WithLabelValues(string(fsvc.Executor)).Inc()
+
+			// Let the executor type clean up what it created, e.g.
+			// processes that have no Kubernetes objects.
+			if registered {
+				err = et.DeleteFuncSvc(fsvc)
+				if err != nil {
+					log.Printf("Error deleting fsvc '%v': %v", fsvc.Name, err)
+				}
+				continue
+			}
+
+			for _, kubeobj := range fsvc.KubernetesObjects {
+				deleteKubeobject(kubeClient, &kubeobj)
+			}
 		}
 	}
 }
@@ -192,12 +256,15 @@ func cleanupDeploymentObjects(kubeClient *kubernetes.Clientset, namespace string
 	}
 }
 
-func cleanupDeployments(client *kubernetes.Clientset, namespace string, instanceId string) error {
+func cleanupDeployments(client *kubernetes.Clientset, namespace string, instanceId string, keep map[types.UID]bool) error {
 	deploymentList, err := client.ExtensionsV1beta1().Deployments(namespace).List(meta_v1.ListOptions{})
 	if err != nil {
 		return err
 	}
 	for _, dep := range deploymentList.Items {
+		if keep[dep.ObjectMeta.UID] {
+			continue
+		}
 		id, ok := dep.ObjectMeta.Labels[fission.EXECUTOR_INSTANCEID_LABEL]
 		if ok && id != instanceId {
 			log.Printf("Cleaning up deployment %v", dep.ObjectMeta.Name)
@@ -217,12 +284,18 @@ func cleanupDeployments(client *kubernetes.Clientset, namespace string, instance
 	return nil
 }
 
-func cleanupReplicaSets(client *kubernetes.Clientset, namespace string, instanceId string) error {
+func cleanupReplicaSets(client *kubernetes.Clientset, namespace string, instanceId string, keep map[types.UID]bool) error {
 	rsList, err := client.ExtensionsV1beta1().ReplicaSets(namespace).List(meta_v1.ListOptions{})
 	if err != nil {
 		return err
 	}
 	for _, rs := range rsList.Items {
+		// replicasets of adopted deployments are kept too, and so are
+		// their pods
+		if keep[rs.ObjectMeta.UID] || ownedByKept(rs.ObjectMeta.OwnerReferences, keep) {
+			keep[rs.ObjectMeta.UID] = true
+			continue
+		}
 		id, ok := rs.ObjectMeta.Labels[fission.EXECUTOR_INSTANCEID_LABEL]
 		if ok && id != instanceId {
 			log.Printf("Cleaning up replicaset %v", rs.ObjectMeta.Name)
@@ -240,12 +313,15 @@ func cleanupReplicaSets(client *kubernetes.Clientset, namespace string, instance
 	return nil
 }
 
-func cleanupPods(client *kubernetes.Clientset, namespace string, instanceId string) error {
+func cleanupPods(client *kubernetes.Clientset, namespace string, instanceId string, keep map[types.UID]bool) error {
 	podList, err := client.CoreV1().Pods(namespace).List(meta_v1.ListOptions{})
 	if err != nil {
 		return err
 	}
 	for _, pod := range podList.Items {
+		if keep[pod.ObjectMeta.UID] || ownedByKept(pod.ObjectMeta.OwnerReferences, keep) {
+			continue
+		}
 		id, ok := pod.ObjectMeta.Labels[fission.EXECUTOR_INSTANCEID_LABEL]
 		if ok && id != instanceId {
 			log.Printf("Cleaning up pod %v", pod.ObjectMeta.Name)
@@ -266,12 +342,15 @@ func cleanupPods(client *kubernetes.Clientset, namespace string, instanceId stri
 	return nil
 }
 
-func cleanupServices(client *kubernetes.Clientset, namespace string, instanceId string) error {
+func cleanupServices(client *kubernetes.Clientset, namespace string, instanceId string, keep map[types.UID]bool) error {
 	svcList, err := client.CoreV1().Services(namespace).List(meta_v1.ListOptions{})
 	if err != nil {
 		return err
 	}
 	for _, svc := range svcList.Items {
+		if keep[svc.ObjectMeta.UID] {
+			continue
+		}
 		id, ok := svc.ObjectMeta.Labels[fission.EXECUTOR_INSTANCEID_LABEL]
 		if ok && id != instanceId {
 			log.Printf("Cleaning up svc %v", svc.ObjectMeta.Name)
@@ -283,13 +362,16 @@ func cleanupServices(client *kubernetes.Clientset, namespace string, instanceId
 	return nil
 }
 
-func cleanupHpa(client *kubernetes.Clientset, namespace string, instanceId string) error {
+func cleanupHpa(client *kubernetes.Clientset, namespace string, instanceId string, keep map[types.UID]bool) error {
 	hpaList, err := client.AutoscalingV1().HorizontalPodAutoscalers(namespace).List(meta_v1.ListOptions{})
 	if err != nil {
 		return err
 	}
 
 	for _, hpa := range hpaList.Items {
+		if keep[hpa.ObjectMeta.UID] {
+			continue
+		}
 		id, ok := hpa.ObjectMeta.Labels[fission.EXECUTOR_INSTANCEID_LABEL]
 		if ok && id != instanceId {
 			log.Printf("Cleaning up HPA %v", hpa.ObjectMeta.Name)
@@ -302,6 +384,16 @@ func cleanupHpa(client *kubernetes.Clientset, namespace string, instanceId strin
 
 }
 
+// ownedByKept checks whether any of the owners is in keep.
+func ownedByKept(owners []meta_v1.OwnerReference, keep map[types.UID]bool) bool {
+	for _, owner := range owners {
+		if keep[owner.UID] {
+			return true
+		}
+	}
+	return false
+}
+
 func logErr(msg string, err error) {
 	if err != nil {
 		log.Printf("Error %v: %v", msg, err)
@@ -23,9 +23,11 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/dchest/uniuri"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/fission/fission"
@@ -33,15 +35,23 @@ import (
 
 type Client struct {
 	executorUrl string
+	reporter    string
 	tappedByUrl map[string]bool
 	requestChan chan string
+	concurrency *concurrencyTracker
 }
 
 func MakeClient(executorUrl string) *Client {
+	reporter, err := os.Hostname()
+	if err != nil {
+		reporter = uniuri.NewLen(8)
+	}
 	c := &Client{
 		executorUrl: strings.TrimSuffix(executorUrl, "/"),
+		reporter:    reporter,
 		tappedByUrl: make(map[string]bool),
 		requestChan: make(chan string),
+		concurrency: makeConcurrencyTracker(time.Now()),
 	}
 	go c.service()
 	return c
@@ -73,23 +83,56 @@ func (c *Client) GetServiceForFunction(metadata *metav1.ObjectMeta) (string, err
 	return string(svcName), nil
 }
 
+// GetServicesForFunction returns the addresses of all function services
+// of the function. There's always at least one.
+func (c *Client) GetServicesForFunction(metadata *metav1.ObjectMeta) ([]string, error) {
+	executorUrl := c.executorUrl + "/v2/getServicesForFunction"
+
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(executorUrl, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fission.MakeErrorFromHTTP(resp)
+	}
+
+	var addresses []string
+	err = json.NewDecoder(resp.Body).Decode(&addresses)
+	if err != nil {
+		return nil, err
+	}
+	if len(addresses) == 0 {
+		return nil, fission.MakeError(fission.ErrorInternal, "executor returned no function service")
+	}
+	return addresses, nil
+}
+
 func (c *Client) service() {
 	ticker := time.NewTicker(time.Second * 5)
 	for {
 		select {
 		case serviceUrl := <-c.requestChan:
 			c.tappedByUrl[serviceUrl] = true
-		case <-ticker.C:
+		case now := <-ticker.C:
 			urls := c.tappedByUrl
 			c.tappedByUrl = make(map[string]bool)
-			if len(urls) > 0 {
+			concurrency := c.concurrency.report(now)
+			if len(urls) > 0 || len(concurrency) > 0 {
 				go func() {
-					for u := range c.tappedByUrl {
-						c._tapService(u)
+					err := c._tapServices(urls, concurrency)
+					if err != nil {
+						log.Printf("Error tapping services: %v", err)
+						return
 					}
 					log.Printf("Tapped %v services in batch", len(urls))
 				}()
-				log.Printf("Tapped %v services in batch", len(urls))
 			}
 		}
 	}
@@ -99,10 +142,48 @@ func (c *Client) TapService(serviceUrl *url.URL) {
 	c.requestChan <- serviceUrl.String()
 }
 
-func (c *Client) _tapService(serviceUrlStr string) error {
-	executorUrl := c.executorUrl + "/v2/tapService"
+// RequestStarted records a request in flight to the function service,
+// for the concurrency reported to the executor.
+func (c *Client) RequestStarted(serviceUrl *url.URL) {
+	c.concurrency.started(serviceUrl.String(), time.Now())
+}
 
-	resp, err := http.Post(executorUrl, "application/octet-stream", bytes.NewReader([]byte(serviceUrlStr)))
+// RequestFinished records the end of a request started with
+// RequestStarted.
+func (c *Client) RequestFinished(serviceUrl *url.URL) {
+	c.concurrency.finished(serviceUrl.String(), time.Now())
+}
+
+// _tapServices reports the tapped services, and the concurrency of busy
+// ones, to the executor in one request.
+func (c *Client) _tapServices(urls map[string]bool, concurrency map[string]float64) error {
+	executorUrl := c.executorUrl + "/v2/tapServices"
+
+	req := fission.TapServicesRequest{
+		Reporter: c.reporter,
+		Services: make([]fission.ServiceTap, 0, len(urls)),
+	}
+	for u := range urls {
+		req.Services = append(req.Services, fission.ServiceTap{
+			ServiceUrl:  u,
+			Concurrency: concurrency[u],
+		})
+	}
+	for u, value := range concurrency {
+		if !urls[u] {
+			req.Services = append(req.Services, fission.ServiceTap{
+				ServiceUrl:  u,
+				Concurrency: value,
+			})
+		}
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(executorUrl, "application/json", bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
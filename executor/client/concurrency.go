@@ -0,0 +1,98 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// concurrencyTracker keeps the time-weighted average number of
+	// in-flight requests to each function service between reports.
+	concurrencyTracker struct {
+		lock       sync.Mutex
+		services   map[string]*serviceConcurrency
+		lastReport time.Time
+	}
+
+	serviceConcurrency struct {
+		inFlight int
+		area     float64   // request-seconds since the last report
+		since    time.Time // last time inFlight changed
+	}
+)
+
+func makeConcurrencyTracker(now time.Time) *concurrencyTracker {
+	return &concurrencyTracker{
+		services:   make(map[string]*serviceConcurrency),
+		lastReport: now,
+	}
+}
+
+func (ct *concurrencyTracker) started(serviceUrl string, now time.Time) {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+
+	sc, ok := ct.services[serviceUrl]
+	if !ok {
+		sc = &serviceConcurrency{since: now}
+		ct.services[serviceUrl] = sc
+	}
+	sc.advance(now)
+	sc.inFlight++
+}
+
+func (ct *concurrencyTracker) finished(serviceUrl string, now time.Time) {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+
+	sc, ok := ct.services[serviceUrl]
+	if !ok || sc.inFlight == 0 {
+		return
+	}
+	sc.advance(now)
+	sc.inFlight--
+}
+
+// report returns the average concurrency of each service since the
+// previous report, leaving out idle services.
+func (ct *concurrencyTracker) report(now time.Time) map[string]float64 {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+
+	window := now.Sub(ct.lastReport).Seconds()
+	ct.lastReport = now
+
+	concurrency := make(map[string]float64)
+	for serviceUrl, sc := range ct.services {
+		sc.advance(now)
+		if window > 0 && sc.area > 0 {
+			concurrency[serviceUrl] = sc.area / window
+		}
+		sc.area = 0
+		if sc.inFlight == 0 {
+			delete(ct.services, serviceUrl)
+		}
+	}
+	return concurrency
+}
+
+func (sc *serviceConcurrency) advance(now time.Time) {
+	sc.area += float64(sc.inFlight) * now.Sub(sc.since).Seconds()
+	sc.since = now
+}
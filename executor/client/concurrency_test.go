@@ -0,0 +1,60 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"log"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyTracker(t *testing.T) {
+	start := time.Now()
+	ct := makeConcurrencyTracker(start)
+
+	// two requests for the whole window, a third for half of it
+	ct.started("http://a", start)
+	ct.started("http://a", start)
+	ct.started("http://a", start.Add(5*time.Second))
+	ct.finished("http://a", start.Add(10*time.Second))
+
+	// a short request to another service
+	ct.started("http://b", start.Add(2*time.Second))
+	ct.finished("http://b", start.Add(3*time.Second))
+
+	report := ct.report(start.Add(10 * time.Second))
+	if math.Abs(report["http://a"]-2.5) > 1e-9 {
+		log.Panicf("expected concurrency 2.5 for a, got %v", report["http://a"])
+	}
+	if math.Abs(report["http://b"]-0.1) > 1e-9 {
+		log.Panicf("expected concurrency 0.1 for b, got %v", report["http://b"])
+	}
+
+	// b was idle and is forgotten; a still has two requests in flight
+	report = ct.report(start.Add(20 * time.Second))
+	if len(report) != 1 || math.Abs(report["http://a"]-2) > 1e-9 {
+		log.Panicf("unexpected second report %v", report)
+	}
+
+	ct.finished("http://a", start.Add(20*time.Second))
+	ct.finished("http://a", start.Add(20*time.Second))
+	report = ct.report(start.Add(30 * time.Second))
+	if len(report) != 0 {
+		log.Panicf("expected empty report, got %v", report)
+	}
+}
@@ -17,33 +17,49 @@ limitations under the License.
 package executor
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/dchest/uniuri"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 
 	"github.com/fission/fission"
 	"github.com/fission/fission/cache"
 	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/executortype"
 	"github.com/fission/fission/executor/fscache"
-	"github.com/fission/fission/executor/newdeploy"
-	"github.com/fission/fission/executor/poolmgr"
+	"github.com/fission/fission/executor/localexec"
+	"github.com/fission/fission/executor/netpolicy"
+
+	// Built-in executor types register themselves on import.
+	_ "github.com/fission/fission/executor/newdeploy"
+	_ "github.com/fission/fission/executor/poolmgr"
 )
 
 type (
 	Executor struct {
-		gpm           *poolmgr.GenericPoolManager
-		ndm           *newdeploy.NewDeploy
+		executorTypes map[fission.ExecutorType]executortype.ExecutorType
 		functionEnv   *cache.Cache
 		fissionClient *crd.FissionClient
 		fsCache       *fscache.FunctionServiceCache
 
 		requestChan chan *createFuncServiceRequest
 		fsCreateWg  map[string]*sync.WaitGroup
+
+		// nil unless replicas elect a leader
+		leadership *leadership
+
+		// nil unless the executor manages network policies
+		networkPolicies *netpolicy.Manager
 	}
 	createFuncServiceRequest struct {
 		funcMeta *metav1.ObjectMeta
@@ -56,10 +72,9 @@ type (
 	}
 )
 
-func MakeExecutor(gpm *poolmgr.GenericPoolManager, ndm *newdeploy.NewDeploy, fissionClient *crd.FissionClient, fsCache *fscache.FunctionServiceCache) *Executor {
+func MakeExecutor(executorTypes map[fission.ExecutorType]executortype.ExecutorType, fissionClient *crd.FissionClient, fsCache *fscache.FunctionServiceCache) *Executor {
 	executor := &Executor{
-		gpm:           gpm,
-		ndm:           ndm,
+		executorTypes: executorTypes,
 		functionEnv:   cache.MakeCache(10*time.Second, 0),
 		fissionClient: fissionClient,
 		fsCache:       fsCache,
@@ -124,8 +139,19 @@ func (executor *Executor) getFunctionExecutorType(meta *metav1.ObjectMeta) (fiss
 	if err != nil {
 		return "", err
 	}
-	return fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType, nil
+	executorType := fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType
+	if len(executorType) == 0 {
+		executorType = fission.ExecutorTypePoolmgr
+	}
+	return executorType, nil
+}
 
+func (executor *Executor) getExecutorType(executorType fission.ExecutorType) (executortype.ExecutorType, error) {
+	et, ok := executor.executorTypes[executorType]
+	if !ok {
+		return nil, fission.MakeError(fission.ErrorInvalidArgument, fmt.Sprintf("unknown executor type %v", executorType))
+	}
+	return et, nil
 }
 
 func (executor *Executor) createServiceForFunction(meta *metav1.ObjectMeta) (*fscache.FuncSvc, error) {
@@ -143,21 +169,11 @@ func (executor *Executor) createServiceForFunction(meta *metav1.ObjectMeta) (*fs
 		return nil, err
 	}
 
-	switch executorType {
-	case fission.ExecutorTypeNewdeploy:
-		fs, err := executor.ndm.GetFuncSvc(meta)
-		return fs, err
-	default:
-		pool, err := executor.gpm.GetPool(env)
-		if err != nil {
-			return nil, err
-		}
-		// from GenericPool -> get one function container
-		// (this also adds to the cache)
-		log.Printf("[%v] getting function service from pool", meta.Name)
-		fsvc, err := pool.GetFuncSvc(meta)
-		return fsvc, err
+	et, err := executor.getExecutorType(executorType)
+	if err != nil {
+		return nil, err
 	}
+	return et.GetFuncSvc(meta, env)
 }
 
 func (executor *Executor) getFunctionEnv(m *metav1.ObjectMeta) (*crd.Environment, error) {
@@ -189,13 +205,14 @@ func (executor *Executor) getFunctionEnv(m *metav1.ObjectMeta) (*crd.Environment
 	return env, nil
 }
 
-// isValidAddress invokes isValidService or isValidPod depending on the type of executor
+// isValidAddress asks the executor type that created the function service
+// whether its address is still valid
 func (executor *Executor) isValidAddress(fsvc *fscache.FuncSvc) bool {
-	if fsvc.Executor == fscache.NEWDEPLOY {
-		return executor.ndm.IsValidService(fsvc.Address)
-	} else {
-		return executor.gpm.IsValidPod(fsvc.KubernetesObjects, fsvc.Address)
+	et, err := executor.getExecutorType(fsvc.Executor)
+	if err != nil {
+		return false
 	}
+	return et.IsValid(fsvc)
 }
 
 func dumpStackTrace() {
@@ -222,22 +239,102 @@ func StartExecutor(fissionNamespace string, functionNamespace string, port int)
 	}
 
 	fsCache := fscache.MakeFunctionServiceCache()
+	err = registerCacheMetrics(fsCache)
+	if err != nil {
+		return err
+	}
 
 	poolID := strings.ToLower(uniuri.NewLen(8))
-	cleanupObjects(kubernetesClient, functionNamespace, poolID)
-	go idleObjectReaper(kubernetesClient, fissionClient, fsCache, time.Minute*2)
 
-	gpm := poolmgr.MakeGenericPoolManager(
-		fissionClient, kubernetesClient,
-		functionNamespace, fsCache, poolID)
+	enableLocal, _ := strconv.ParseBool(os.Getenv("ENABLE_LOCAL_EXECUTOR"))
+	if enableLocal {
+		localexec.Register()
+	}
+
+	opts := &executortype.Options{
+		FissionClient:     fissionClient,
+		KubernetesClient:  kubernetesClient,
+		CrdClient:         restClient,
+		FunctionNamespace: functionNamespace,
+		FsCache:           fsCache,
+		InstanceID:        poolID,
+	}
+	executorTypes := make(map[fission.ExecutorType]executortype.ExecutorType)
+	for _, name := range executortype.Registered() {
+		et, err := executortype.Make(name, opts)
+		if err != nil {
+			log.Printf("Failed to create executor type %v: %v", name, err)
+			return err
+		}
+		log.Printf("Created executor type %v", name)
+		executorTypes[name] = et
+	}
+
+	api := MakeExecutor(executorTypes, fissionClient, fsCache)
 
-	ndm := newdeploy.MakeNewDeploy(
-		fissionClient, kubernetesClient, restClient,
-		functionNamespace, fsCache, poolID)
+	enablePolicies, _ := strconv.ParseBool(os.Getenv("ENABLE_NETWORK_POLICIES"))
+	if enablePolicies {
+		api.networkPolicies = netpolicy.MakeManager(kubernetesClient, fissionClient, fissionNamespace, functionNamespace)
+	}
 
-	api := MakeExecutor(gpm, ndm, fissionClient, fsCache)
+	adopt, _ := strconv.ParseBool(os.Getenv("ADOPT_EXISTING_RESOURCES"))
+	// With leader election, a leader that loses the election exits and
+	// is restarted as a follower; see runLeaderElection.
+	electLeader, _ := strconv.ParseBool(os.Getenv("ENABLE_LEADER_ELECTION"))
+	if !electLeader {
+		api.lead(kubernetesClient, functionNamespace, poolID, adopt)
+		go api.Serve(port)
+		return nil
+	}
 
+	identity, err := replicaIdentity(port)
+	if err != nil {
+		return err
+	}
+	api.leadership = makeLeadership(identity)
 	go api.Serve(port)
 
-	return nil
+	// A new leader takes over the function services of the previous
+	// one, rather than cleaning them up.
+	return api.runLeaderElection(kubernetesClient, fissionNamespace, func() {
+		api.lead(kubernetesClient, functionNamespace, poolID, true)
+	})
+}
+
+// lead starts managing function services: those of earlier executor
+// instances are adopted or cleaned up, idle ones are reaped, and the
+// controllers of the backends run, along with the network policy
+// manager.
+func (executor *Executor) lead(kubernetesClient *kubernetes.Clientset, functionNamespace string, instanceID string, adopt bool) {
+	// Function services of earlier executor instances are cleaned up,
+	// unless they're adopted by this one.
+	keep := make(map[types.UID]bool)
+	if adopt {
+		for name, et := range executor.executorTypes {
+			adopter, ok := et.(executortype.Adopter)
+			if !ok {
+				continue
+			}
+			uids, err := adopter.AdoptExistingResources()
+			if err != nil {
+				// whatever wasn't adopted is cleaned up
+				log.Printf("Error adopting existing resources for executor type %v: %v", name, err)
+			}
+			log.Printf("Executor type %v adopted %v objects", name, len(uids))
+			for _, uid := range uids {
+				keep[uid] = true
+			}
+		}
+	}
+	cleanupObjects(kubernetesClient, functionNamespace, instanceID, keep)
+
+	go idleObjectReaper(kubernetesClient, executor.fissionClient, executor.fsCache, executor.executorTypes, time.Minute*2)
+
+	for _, et := range executor.executorTypes {
+		et.Run(context.Background())
+	}
+	go executor.keepWarm(context.Background())
+	if executor.networkPolicies != nil {
+		executor.networkPolicies.Run(context.Background())
+	}
 }
@@ -0,0 +1,173 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executortype
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/fscache"
+)
+
+type (
+	// ExecutorType is implemented by executor backends. A backend creates
+	// the function services for functions whose ExecutionStrategy names
+	// it, and records them in the function service cache.
+	ExecutorType interface {
+		// Run starts the backend's controllers. It must not block.
+		// With leader election, it's only called on the leader.
+		Run(ctx context.Context)
+
+		// GetFuncSvc returns a function service for the function,
+		// creating one if there's none. The function service is added
+		// to the cache.
+		GetFuncSvc(metadata *metav1.ObjectMeta, env *crd.Environment) (*fscache.FuncSvc, error)
+
+		// IsValid checks that a cached function service can still
+		// serve requests.
+		IsValid(fsvc *fscache.FuncSvc) bool
+
+		// DeleteFuncSvc removes the function service from the cache
+		// and deletes the objects backing it.
+		DeleteFuncSvc(fsvc *fscache.FuncSvc) error
+
+		// ListFuncSvcs lists the cached function services created by
+		// this backend.
+		ListFuncSvcs() ([]*fscache.FuncSvc, error)
+	}
+
+	// ConcurrencyObserver is implemented by backends that scale
+	// function services on the concurrency reported by the routers.
+	ConcurrencyObserver interface {
+		// ObserveConcurrency records the average number of in-flight
+		// requests to the function service seen by one router.
+		ObserveConcurrency(fsvc *fscache.FuncSvc, reporter string, concurrency float64)
+	}
+
+	// Adopter is implemented by backends that can take over the
+	// function services created by earlier executor instances, instead
+	// of having them cleaned up.
+	Adopter interface {
+		// AdoptExistingResources adds the function services of earlier
+		// executor instances to the cache, and returns the UIDs of the
+		// Kubernetes objects that must not be cleaned up.
+		AdoptExistingResources() ([]types.UID, error)
+	}
+
+	// Follower is implemented by backends whose function services
+	// follower replicas can pick up from the Kubernetes objects backing
+	// them.
+	Follower interface {
+		// Follow watches the objects backing the function services
+		// of the leader, and keeps the cache in step with them. It
+		// blocks until stop is closed and its watches are done.
+		Follow(stop <-chan struct{})
+	}
+
+	// IdleScaler is implemented by backends that keep the objects of
+	// idle function services around, scaled down, instead of having
+	// them deleted by the idle reaper.
+	IdleScaler interface {
+		// ScaleDownIdle scales the function service down, if it has
+		// been idle for minAge and its function allows it, and
+		// removes it from the cache. It returns whether it did.
+		ScaleDownIdle(fsvc *fscache.FuncSvc, minAge time.Duration) (bool, error)
+	}
+
+	// WarmKeeper is implemented by backends that follow the warm
+	// schedule of functions.
+	WarmKeeper interface {
+		// KeepWarm makes sure the function, which has a function
+		// service, runs at least the given number of instances. It's
+		// called periodically for functions with a warm schedule,
+		// with 0 instances when no window is open.
+		KeepWarm(fn *crd.Function, instances int) error
+	}
+
+	// Options holds what the executor shares with all backends.
+	Options struct {
+		FissionClient     *crd.FissionClient
+		KubernetesClient  *kubernetes.Clientset
+		CrdClient         *rest.RESTClient
+		FunctionNamespace string
+		FsCache           *fscache.FunctionServiceCache
+		InstanceID        string
+	}
+
+	// Factory creates a backend. It's called once, when the executor
+	// starts.
+	Factory func(opts *Options) (ExecutorType, error)
+)
+
+var (
+	registryLock sync.Mutex
+	registry     = make(map[fission.ExecutorType]Factory)
+)
+
+// Register makes a backend available under the given name, which
+// functions use as their ExecutionStrategy.ExecutorType. It is meant to
+// be called from the init function of the backend's package, and panics
+// if the name is registered twice.
+func Register(name fission.ExecutorType, factory Factory) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if factory == nil {
+		panic("executortype: Register factory is nil")
+	}
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("executortype: Register called twice for %v", name))
+	}
+	registry[name] = factory
+}
+
+// Registered returns the names of all registered backends, sorted.
+func Registered() []fission.ExecutorType {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	names := make([]fission.ExecutorType, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// Make creates the backend registered under name.
+func Make(name fission.ExecutorType, opts *Options) (ExecutorType, error) {
+	registryLock.Lock()
+	factory, ok := registry[name]
+	registryLock.Unlock()
+
+	if !ok {
+		return nil, fission.MakeError(fission.ErrorNotFound, fmt.Sprintf("executor type %v is not registered", name))
+	}
+	return factory(opts)
+}
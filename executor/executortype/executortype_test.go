@@ -0,0 +1,74 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executortype
+
+import (
+	"context"
+	"log"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/fscache"
+)
+
+type fakeExecutorType struct {
+	opts *Options
+}
+
+func (f *fakeExecutorType) Run(ctx context.Context) {}
+func (f *fakeExecutorType) GetFuncSvc(metadata *metav1.ObjectMeta, env *crd.Environment) (*fscache.FuncSvc, error) {
+	return &fscache.FuncSvc{Function: metadata, Environment: env, Executor: "fake"}, nil
+}
+func (f *fakeExecutorType) IsValid(fsvc *fscache.FuncSvc) bool        { return true }
+func (f *fakeExecutorType) DeleteFuncSvc(fsvc *fscache.FuncSvc) error { return nil }
+func (f *fakeExecutorType) ListFuncSvcs() ([]*fscache.FuncSvc, error) { return nil, nil }
+
+func TestRegistry(t *testing.T) {
+	Register("fake", func(opts *Options) (ExecutorType, error) {
+		return &fakeExecutorType{opts: opts}, nil
+	})
+
+	names := Registered()
+	if len(names) != 1 || names[0] != "fake" {
+		log.Panicf("unexpected registered executor types: %v", names)
+	}
+
+	opts := &Options{FunctionNamespace: "fission-function", InstanceID: "abcd"}
+	et, err := Make("fake", opts)
+	if err != nil {
+		log.Panicf("failed to make executor type: %v", err)
+	}
+	if et.(*fakeExecutorType).opts != opts {
+		log.Panicf("options weren't passed to the factory")
+	}
+
+	_, err = Make("missing", opts)
+	if err == nil {
+		log.Panicf("expected error for unregistered executor type")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				log.Panicf("expected panic on duplicate registration")
+			}
+		}()
+		Register("fake", func(opts *Options) (ExecutorType, error) { return nil, nil })
+	}()
+}
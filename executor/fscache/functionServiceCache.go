@@ -30,7 +30,6 @@ import (
 )
 
 type fscRequestType int
-type executorType int
 
 const (
 	TOUCH fscRequestType = iota
@@ -38,11 +37,6 @@ const (
 	LOG
 )
 
-const (
-	POOLMGR executorType = iota
-	NEWDEPLOY
-)
-
 type (
 	FuncSvc struct {
 		Name              string                // Name of object
@@ -50,7 +44,7 @@ type (
 		Environment       *crd.Environment      // function's environment
 		Address           string                // Host:Port or IP:Port that the function's service can be reached at.
 		KubernetesObjects []api.ObjectReference // Kubernetes Objects (within the function namespace)
-		Executor          executorType
+		Executor          fission.ExecutorType  // executor type that created this function service
 
 		Ctime time.Time
 		Atime time.Time
@@ -60,6 +54,7 @@ type (
 		byFunction    *cache.Cache // function-key -> funcSvc  : map[string]*funcSvc
 		byAddress     *cache.Cache // address      -> function : map[string]metav1.ObjectMeta
 		byFunctionUID *cache.Cache // function uid -> function : map[string]metav1.ObjectMeta
+		scaledOut     *cache.Cache // address      -> funcSvc  : map[string]*funcSvc, for functions with several function services
 
 		requestChannel chan *fscRequest
 	}
@@ -68,6 +63,7 @@ type (
 		address           string
 		kubernetesObjects []api.ObjectReference
 		age               time.Duration
+		idleTimeouts      map[types.UID]time.Duration
 		responseChannel   chan *fscResponse
 	}
 	fscResponse struct {
@@ -96,6 +92,7 @@ func MakeFunctionServiceCache() *FunctionServiceCache {
 		byFunction:     cache.MakeCache(0, 0),
 		byAddress:      cache.MakeCache(0, 0),
 		byFunctionUID:  cache.MakeCache(0, 0),
+		scaledOut:      cache.MakeCache(0, 0),
 		requestChannel: make(chan *fscRequest),
 	}
 	go fsc.service()
@@ -111,23 +108,28 @@ func (fsc *FunctionServiceCache) service() {
 			// update atime for this function svc
 			resp.error = fsc._touchByAddress(req.address)
 		case LISTOLD:
-			// get svcs idle for > req.age
-			fscs := fsc.byFunction.Copy()
+			// get svcs idle for > req.age, or for longer than their
+			// function's own idle timeout
 			funcObjects := make([]*FuncSvc, 0)
-			for _, funcSvc := range fscs {
-				fsvc := funcSvc.(*FuncSvc)
-				if time.Since(fsvc.Atime) > req.age {
+			for _, fsvc := range fsc.all() {
+				age := req.age
+				if timeout, ok := req.idleTimeouts[fsvc.Function.UID]; ok {
+					age = timeout
+				}
+				if age < 0 {
+					continue
+				}
+				if time.Since(fsvc.Atime) > age {
 					funcObjects = append(funcObjects, fsvc)
 				}
 			}
 			resp.objects = funcObjects
 		case LOG:
-			funcCopy := fsc.byFunction.Copy()
-			log.Printf("Cache has %v entries", len(funcCopy))
-			for key, fsvcI := range funcCopy {
-				fsvc := fsvcI.(*FuncSvc)
+			fsvcs := fsc.all()
+			log.Printf("Cache has %v entries", len(fsvcs))
+			for _, fsvc := range fsvcs {
 				for _, kubeObj := range fsvc.KubernetesObjects {
-					log.Printf("%v\t%v\t%v", key, kubeObj.Kind, kubeObj.Name)
+					log.Printf("%v\t%v\t%v", crd.CacheKey(fsvc.Function), kubeObj.Kind, kubeObj.Name)
 				}
 			}
 		}
@@ -219,6 +221,74 @@ func (fsc *FunctionServiceCache) Add(fsvc FuncSvc) (*FuncSvc, error) {
 	return nil, nil
 }
 
+// AddScaledOut adds another function service for a function that already
+// has one. Scaled out function services are listed by ListByFunction,
+// and take the place of the function's first function service when
+// that's deleted.
+func (fsc *FunctionServiceCache) AddScaledOut(fsvc FuncSvc) error {
+	now := time.Now()
+	fsvc.Ctime = now
+	fsvc.Atime = now
+
+	err, _ := fsc.scaledOut.Set(fsvc.Address, &fsvc)
+	if err != nil {
+		return err
+	}
+	err, _ = fsc.byAddress.Set(fsvc.Address, *fsvc.Function)
+	if err != nil {
+		fsc.scaledOut.Delete(fsvc.Address)
+		return err
+	}
+	return nil
+}
+
+// ListByFunction returns all function services of the function. The
+// first one is the one returned by GetByFunction.
+func (fsc *FunctionServiceCache) ListByFunction(m *metav1.ObjectMeta) []*FuncSvc {
+	key := crd.CacheKey(m)
+	funcSvcs := make([]*FuncSvc, 0)
+	if fsvcI, err := fsc.byFunction.Get(key); err == nil {
+		fsvcCopy := *fsvcI.(*FuncSvc)
+		funcSvcs = append(funcSvcs, &fsvcCopy)
+	}
+	for _, fsvcI := range fsc.scaledOut.Copy() {
+		fsvc := fsvcI.(*FuncSvc)
+		if crd.CacheKey(fsvc.Function) == key {
+			fsvcCopy := *fsvc
+			funcSvcs = append(funcSvcs, &fsvcCopy)
+		}
+	}
+	return funcSvcs
+}
+
+// getByAddress returns the cached function service at the address.
+func (fsc *FunctionServiceCache) getByAddress(address string) (*FuncSvc, error) {
+	if fsvcI, err := fsc.scaledOut.Get(address); err == nil {
+		return fsvcI.(*FuncSvc), nil
+	}
+	mI, err := fsc.byAddress.Get(address)
+	if err != nil {
+		return nil, err
+	}
+	m := mI.(metav1.ObjectMeta)
+	fsvcI, err := fsc.byFunction.Get(crd.CacheKey(&m))
+	if err != nil {
+		return nil, err
+	}
+	return fsvcI.(*FuncSvc), nil
+}
+
+// GetByAddress returns the function service at the address, without
+// updating its atime.
+func (fsc *FunctionServiceCache) GetByAddress(address string) (*FuncSvc, error) {
+	fsvc, err := fsc.getByAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	fsvcCopy := *fsvc
+	return &fsvcCopy, nil
+}
+
 func (fsc *FunctionServiceCache) TouchByAddress(address string) error {
 	responseChannel := make(chan *fscResponse)
 	fsc.requestChannel <- &fscRequest{
@@ -231,23 +301,42 @@ func (fsc *FunctionServiceCache) TouchByAddress(address string) error {
 }
 
 func (fsc *FunctionServiceCache) _touchByAddress(address string) error {
-	mI, err := fsc.byAddress.Get(address)
-	if err != nil {
-		return err
-	}
-	m := mI.(metav1.ObjectMeta)
-	fsvcI, err := fsc.byFunction.Get(crd.CacheKey(&m))
+	fsvc, err := fsc.getByAddress(address)
 	if err != nil {
 		return err
 	}
-	fsvc := fsvcI.(*FuncSvc)
 	fsvc.Atime = time.Now()
 	return nil
 }
 
 func (fsc *FunctionServiceCache) DeleteEntry(fsvc *FuncSvc) {
-	fsc.byFunction.Delete(crd.CacheKey(fsvc.Function))
+	if _, err := fsc.scaledOut.Get(fsvc.Address); err == nil {
+		fsc.scaledOut.Delete(fsvc.Address)
+		fsc.byAddress.Delete(fsvc.Address)
+		return
+	}
+
 	fsc.byAddress.Delete(fsvc.Address)
+	if fsvcI, err := fsc.byFunction.Get(crd.CacheKey(fsvc.Function)); err == nil &&
+		fsvcI.(*FuncSvc).Address != fsvc.Address {
+		// already taken over by a scaled out function service
+		return
+	}
+	fsc.byFunction.Delete(crd.CacheKey(fsvc.Function))
+
+	// a scaled out function service of the function, if any, takes its
+	// place
+	for address, fsvcI := range fsc.scaledOut.Copy() {
+		other := fsvcI.(*FuncSvc)
+		if crd.CacheKey(other.Function) != crd.CacheKey(fsvc.Function) {
+			continue
+		}
+		err, _ := fsc.byFunction.Set(crd.CacheKey(other.Function), other)
+		if err == nil {
+			fsc.scaledOut.Delete(address.(string))
+			return
+		}
+	}
 	fsc.byFunctionUID.Delete(fsvc.Function.UID)
 }
 
@@ -261,17 +350,62 @@ func (fsc *FunctionServiceCache) DeleteOld(fsvc *FuncSvc, minAge time.Duration)
 	return true, nil
 }
 
-func (fsc *FunctionServiceCache) ListOld(age time.Duration) ([]*FuncSvc, error) {
+// ListOld returns the function services that have been idle for longer
+// than age. idleTimeouts, keyed by function UID, overrides age for
+// individual functions; a negative timeout means the function is never
+// listed.
+func (fsc *FunctionServiceCache) ListOld(age time.Duration, idleTimeouts map[types.UID]time.Duration) ([]*FuncSvc, error) {
 	responseChannel := make(chan *fscResponse)
 	fsc.requestChannel <- &fscRequest{
 		requestType:     LISTOLD,
 		age:             age,
+		idleTimeouts:    idleTimeouts,
 		responseChannel: responseChannel,
 	}
 	resp := <-responseChannel
 	return resp.objects, resp.error
 }
 
+// List returns all cached function services.
+func (fsc *FunctionServiceCache) List() []*FuncSvc {
+	funcSvcs := make([]*FuncSvc, 0)
+	for _, fsvc := range fsc.all() {
+		fsvcCopy := *fsvc
+		funcSvcs = append(funcSvcs, &fsvcCopy)
+	}
+	return funcSvcs
+}
+
+// ListByExecutor returns the function services created by the given
+// executor type.
+func (fsc *FunctionServiceCache) ListByExecutor(executor fission.ExecutorType) []*FuncSvc {
+	funcSvcs := make([]*FuncSvc, 0)
+	for _, fsvc := range fsc.all() {
+		if fsvc.Executor == executor {
+			fsvcCopy := *fsvc
+			funcSvcs = append(funcSvcs, &fsvcCopy)
+		}
+	}
+	return funcSvcs
+}
+
+// Len returns the number of function services in the cache.
+func (fsc *FunctionServiceCache) Len() int {
+	return len(fsc.byFunction.Copy()) + len(fsc.scaledOut.Copy())
+}
+
+// all returns the cached function services, including scaled out ones.
+func (fsc *FunctionServiceCache) all() []*FuncSvc {
+	funcSvcs := make([]*FuncSvc, 0)
+	for _, fsvcI := range fsc.byFunction.Copy() {
+		funcSvcs = append(funcSvcs, fsvcI.(*FuncSvc))
+	}
+	for _, fsvcI := range fsc.scaledOut.Copy() {
+		funcSvcs = append(funcSvcs, fsvcI.(*FuncSvc))
+	}
+	return funcSvcs
+}
+
 func (fsc *FunctionServiceCache) Log() {
 	log.Printf("--- FunctionService Cache Contents")
 	responseChannel := make(chan *fscResponse)
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/pkg/api"
 
 	"github.com/fission/fission"
@@ -82,6 +83,11 @@ func TestFunctionServiceCache(t *testing.T) {
 		log.Panicf("Incorrect fsvc \n(expected: %#v)\n (found: %#v)", fsvc, f)
 	}
 
+	if fsc.Len() != 1 {
+		fsc.Log()
+		log.Panicf("expected 1 cache entry, found %v", fsc.Len())
+	}
+
 	err = fsc.TouchByAddress(fsvc.Address)
 	if err != nil {
 		fsc.Log()
@@ -109,4 +115,103 @@ func TestFunctionServiceCache(t *testing.T) {
 		fsc.Log()
 		log.Panicf("found fsvc by function uid while expecting empty cache: %v", err)
 	}
+
+	if fsc.Len() != 0 {
+		fsc.Log()
+		log.Panicf("expected empty cache, found %v entries", fsc.Len())
+	}
+}
+
+func TestListOldIdleTimeouts(t *testing.T) {
+	fsc := MakeFunctionServiceCache()
+
+	for _, name := range []string{"default", "short", "long", "forever"} {
+		_, err := fsc.Add(FuncSvc{
+			Function: &metav1.ObjectMeta{
+				Name: name,
+				UID:  types.UID(name),
+			},
+			Environment: &crd.Environment{},
+			Address:     name,
+		})
+		if err != nil {
+			log.Panicf("Failed to add fsvc: %v", err)
+		}
+	}
+
+	// Add sets atime to now, so only functions with a tiny timeout are old
+	time.Sleep(10 * time.Millisecond)
+	idleTimeouts := map[types.UID]time.Duration{
+		"short":   time.Millisecond,
+		"long":    time.Hour,
+		"forever": -1,
+	}
+	funcSvcs, err := fsc.ListOld(0, idleTimeouts)
+	if err != nil {
+		log.Panicf("Failed to list old fsvcs: %v", err)
+	}
+
+	found := make(map[string]bool)
+	for _, fsvc := range funcSvcs {
+		found[fsvc.Function.Name] = true
+	}
+	if len(found) != 2 || !found["default"] || !found["short"] {
+		log.Panicf("unexpected old fsvcs: %v", found)
+	}
+}
+
+func TestScaledOut(t *testing.T) {
+	fsc := MakeFunctionServiceCache()
+	fn := &metav1.ObjectMeta{Name: "foo", UID: "1212", ResourceVersion: "1"}
+
+	_, err := fsc.Add(FuncSvc{Function: fn, Environment: &crd.Environment{}, Address: "a:8888"})
+	if err != nil {
+		log.Panicf("Failed to add fsvc: %v", err)
+	}
+	for _, address := range []string{"b:8888", "c:8888"} {
+		err = fsc.AddScaledOut(FuncSvc{Function: fn, Environment: &crd.Environment{}, Address: address})
+		if err != nil {
+			log.Panicf("Failed to add scaled out fsvc: %v", err)
+		}
+	}
+
+	fsvcs := fsc.ListByFunction(fn)
+	if len(fsvcs) != 3 || fsvcs[0].Address != "a:8888" || fsc.Len() != 3 || len(fsc.List()) != 3 {
+		log.Panicf("unexpected fsvcs for function: %v", fsvcs)
+	}
+
+	fsvc, err := fsc.GetByAddress("b:8888")
+	if err != nil || fsvc.Address != "b:8888" {
+		log.Panicf("failed to get scaled out fsvc by address: %v", err)
+	}
+
+	// deleting a scaled out fsvc leaves the first one in place
+	fsc.DeleteEntry(fsvc)
+	first, err := fsc.GetByFunction(fn)
+	if err != nil || first.Address != "a:8888" {
+		log.Panicf("expected first fsvc to stay, got %v", first)
+	}
+
+	// deleting the first one promotes the remaining scaled out fsvc
+	deleted := first
+	fsc.DeleteEntry(deleted)
+	first, err = fsc.GetByFunction(fn)
+	if err != nil || first.Address != "c:8888" {
+		log.Panicf("expected scaled out fsvc to be promoted, got %v", first)
+	}
+	if _, err = fsc.GetByFunctionUID(fn.UID); err != nil {
+		log.Panicf("expected function uid to stay cached: %v", err)
+	}
+
+	// deleting the first one again, as the idle reaper does, leaves the
+	// promoted one in place
+	fsc.DeleteEntry(deleted)
+	if _, err = fsc.GetByFunction(fn); err != nil {
+		log.Panicf("expected promoted fsvc to stay: %v", err)
+	}
+
+	fsc.DeleteEntry(first)
+	if fsc.Len() != 0 {
+		log.Panicf("expected empty cache, found %v entries", fsc.Len())
+	}
 }
@@ -0,0 +1,216 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/pkg/api"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/fission/fission/executor/executortype"
+)
+
+const (
+	// Executor replicas hold an election on this endpoints object. The
+	// leader manages function services: it creates them, reaps them and
+	// runs the pools. The other replicas answer lookups of existing
+	// function services, and forward everything else to the leader.
+	leaderLockName = "fission-executor"
+
+	leaderLeaseDuration = 15 * time.Second
+	leaderRenewDeadline = 10 * time.Second
+	leaderRetryPeriod   = 2 * time.Second
+)
+
+// leadership tracks the outcome of the leader election. Replicas are
+// known in the election by the URL of their API.
+type leadership struct {
+	lock     sync.RWMutex
+	identity string
+	leader   string
+	leading  bool
+}
+
+func makeLeadership(identity string) *leadership {
+	return &leadership{
+		identity: identity,
+	}
+}
+
+// get returns the URL of the leader, if one is known, and whether it's
+// this replica.
+func (l *leadership) get() (string, bool) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.leader, l.leading
+}
+
+func (l *leadership) setLeader(identity string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.leader = identity
+	if identity == l.identity {
+		l.leading = true
+	}
+}
+
+func (l *leadership) setLeading() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.leader = l.identity
+	l.leading = true
+}
+
+// replicaIdentity returns the URL that other replicas reach this one at.
+// The pod's IP is passed in through the downward API.
+func replicaIdentity(port int) (string, error) {
+	podIP := os.Getenv("POD_IP")
+	if len(podIP) == 0 {
+		return "", errors.New("POD_IP must be set to run the executor with leader election")
+	}
+	return fmt.Sprintf("http://%v:%v", podIP, port), nil
+}
+
+// runLeaderElection joins the leader election, and calls lead once this
+// replica wins it. A leader that loses the election exits, and comes
+// back as a follower.
+func (executor *Executor) runLeaderElection(kubeClient *kubernetes.Clientset, namespace string, lead func()) error {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: kubeClient.CoreV1().Events(namespace)})
+	recorder := broadcaster.NewRecorder(api.Scheme, apiv1.EventSource{Component: "fission-executor"})
+
+	// Until this replica leads, the backends keep its function service
+	// cache in step with the objects the leader creates.
+	stopFollowing := make(chan struct{})
+	var following sync.WaitGroup
+
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.EndpointsLock{
+			EndpointsMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      leaderLockName,
+			},
+			Client: kubeClient.CoreV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity:      executor.leadership.identity,
+				EventRecorder: recorder,
+			},
+		},
+		LeaseDuration: leaderLeaseDuration,
+		RenewDeadline: leaderRenewDeadline,
+		RetryPeriod:   leaderRetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(stop <-chan struct{}) {
+				log.Printf("Became the executor leader")
+				executor.leadership.setLeading()
+				close(stopFollowing)
+				following.Wait()
+				executor.resetFuncSvcs()
+				lead()
+			},
+			OnStoppedLeading: func() {
+				// Another replica may be managing the function
+				// services already. The pools, reapers and
+				// controllers started by lead can't all be
+				// stopped, so rather than following alongside
+				// them, exit and come back as a follower.
+				log.Fatalf("Lost the executor leader election, exiting")
+			},
+			OnNewLeader: func(identity string) {
+				log.Printf("Executor leader is %v", identity)
+				executor.leadership.setLeader(identity)
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	for name, et := range executor.executorTypes {
+		follower, ok := et.(executortype.Follower)
+		if !ok {
+			continue
+		}
+		log.Printf("Following function services of executor type %v", name)
+		following.Add(1)
+		go func(follower executortype.Follower) {
+			defer following.Done()
+			follower.Follow(stopFollowing)
+		}(follower)
+	}
+	go le.Run()
+	return nil
+}
+
+// following checks whether this replica is a follower, which leaves
+// managing function services to the leader.
+func (executor *Executor) following() bool {
+	if executor.leadership == nil {
+		return false
+	}
+	_, leading := executor.leadership.get()
+	return !leading
+}
+
+// proxyToLeader passes the request on to the leader.
+func (executor *Executor) proxyToLeader(w http.ResponseWriter, r *http.Request) {
+	leader, _ := executor.leadership.get()
+	if len(leader) == 0 {
+		http.Error(w, "no executor leader elected yet", http.StatusServiceUnavailable)
+		return
+	}
+	target, err := url.Parse(leader)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid executor leader %v", leader), http.StatusInternalServerError)
+		return
+	}
+	httputil.NewSingleHostReverseProxy(target).ServeHTTP(w, r)
+}
+
+// leaderOnly wraps the handler of a request that only the leader can
+// serve; followers forward it.
+func (executor *Executor) leaderOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if executor.following() {
+			executor.proxyToLeader(w, r)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// resetFuncSvcs empties the function service cache of a new leader, so
+// that it adopts all function services of the previous one.
+func (executor *Executor) resetFuncSvcs() {
+	for _, fsvc := range executor.fsCache.List() {
+		executor.fsCache.DeleteEntry(fsvc)
+	}
+}
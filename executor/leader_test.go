@@ -0,0 +1,59 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLeaderOnly(t *testing.T) {
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("leader"))
+	}))
+	defer leader.Close()
+
+	executor := &Executor{
+		leadership: makeLeadership("http://follower:8888"),
+	}
+	handler := executor.leaderOnly(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("local"))
+	})
+	serve := func() (int, string) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/v2/funcsvcs", nil))
+		body, _ := ioutil.ReadAll(w.Body)
+		return w.Code, string(body)
+	}
+
+	if code, _ := serve(); code != http.StatusServiceUnavailable {
+		log.Panicf("request served without a leader: %v", code)
+	}
+
+	executor.leadership.setLeader(leader.URL)
+	if _, body := serve(); body != "leader" {
+		log.Panicf("follower served the request: %v", body)
+	}
+
+	executor.leadership.setLeader("http://follower:8888")
+	if _, body := serve(); body != "local" {
+		log.Panicf("leader forwarded the request: %v", body)
+	}
+}
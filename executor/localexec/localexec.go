@@ -0,0 +1,390 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package localexec implements the "local" executor type, which runs
+// environment servers as processes on the executor's host instead of
+// as pods. It's meant for development machines and CI: functions run
+// without any nodes, though Fission's resources still live in a
+// Kubernetes API server (which may be a bare kube-apiserver and etcd,
+// reached through KUBECONFIG).
+package localexec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dchest/uniuri"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/environments/fetcher"
+	"github.com/fission/fission/executor/executortype"
+	"github.com/fission/fission/executor/fscache"
+)
+
+type (
+	LocalExecutor struct {
+		fissionClient    *crd.FissionClient
+		kubernetesClient *kubernetes.Clientset
+		fsCache          *fscache.FunctionServiceCache
+		baseDir          string
+
+		lock      sync.Mutex
+		processes map[string]*process // function service name -> process
+	}
+
+	process struct {
+		dir    string
+		cmd    *exec.Cmd
+		exited chan struct{}
+	}
+)
+
+// Register makes the local executor type available. It isn't registered
+// by default: the environment's local command runs inside the executor,
+// with the executor's permissions, so it's only meant for development
+// machines and CI where whoever creates environments may do that.
+func Register() {
+	executortype.Register(fission.ExecutorTypeLocal, func(opts *executortype.Options) (executortype.ExecutorType, error) {
+		baseDir := os.Getenv("LOCAL_EXECUTOR_DIR")
+		if len(baseDir) == 0 {
+			baseDir = filepath.Join(os.TempDir(), "fission-local-executor")
+		}
+		return MakeLocalExecutor(opts.FissionClient, opts.KubernetesClient, opts.FsCache,
+			filepath.Join(baseDir, opts.InstanceID))
+	})
+}
+
+func MakeLocalExecutor(
+	fissionClient *crd.FissionClient,
+	kubernetesClient *kubernetes.Clientset,
+	fsCache *fscache.FunctionServiceCache,
+	baseDir string) (*LocalExecutor, error) {
+
+	err := os.MkdirAll(baseDir, os.ModeDir|0700)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalExecutor{
+		fissionClient:    fissionClient,
+		kubernetesClient: kubernetesClient,
+		fsCache:          fsCache,
+		baseDir:          baseDir,
+		processes:        make(map[string]*process),
+	}, nil
+}
+
+// Run stops all processes once ctx is done.
+func (le *LocalExecutor) Run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		le.lock.Lock()
+		defer le.lock.Unlock()
+		for name, p := range le.processes {
+			p.stop()
+			delete(le.processes, name)
+		}
+	}()
+}
+
+// GetFuncSvc starts an environment server process, fetches the function
+// into its directory and specializes it.
+func (le *LocalExecutor) GetFuncSvc(metadata *metav1.ObjectMeta, env *crd.Environment) (*fscache.FuncSvc, error) {
+	if len(env.Spec.Runtime.LocalCommand) == 0 {
+		return nil, fission.MakeError(fission.ErrorInvalidArgument,
+			fmt.Sprintf("environment %v has no local command", env.Metadata.Name))
+	}
+
+	fn, err := le.fissionClient.Functions(metadata.Namespace).Get(metadata.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// v1 environment servers can't set the function's environment
+	// variables; the controller rejects such functions, unless the
+	// environment changed since
+	err = fn.Spec.ValidateEnvSupport(&env.Spec)
+	if err != nil {
+		return nil, err
+	}
+	fnEnv, err := fetcher.ResolveEnv(le.kubernetesClient, fn.Metadata.Namespace, fn.Spec.Env)
+	if err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("%v-%v", metadata.Name, strings.ToLower(uniuri.NewLen(8)))
+	dir := filepath.Join(le.baseDir, name)
+	userfuncDir := filepath.Join(dir, "userfunc")
+	secretsDir := filepath.Join(dir, "secrets")
+	configMapsDir := filepath.Join(dir, "configs")
+
+	f, err := fetcher.MakeFetcherWithClients(userfuncDir, secretsDir, configMapsDir, le.fissionClient, le.kubernetesClient)
+	if err != nil {
+		return nil, err
+	}
+
+	// same file naming as the pool manager's specialization
+	targetFilename := "user"
+	if env.Spec.Version == 2 {
+		targetFilename = string(fn.Metadata.UID)
+	}
+
+	log.Printf("[%v] fetching function into %v", metadata.Name, userfuncDir)
+	_, err = f.Fetch(fetcher.FetchRequest{
+		FetchType: fetcher.FETCH_DEPLOYMENT,
+		Package: metav1.ObjectMeta{
+			Namespace: fn.Spec.Package.PackageRef.Namespace,
+			Name:      fn.Spec.Package.PackageRef.Name,
+		},
+		Filename: targetFilename,
+	})
+	if err == nil {
+		_, err = f.FetchSecretsAndCfgMaps(fn.Spec.Secrets, fn.Spec.ConfigMaps)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	port, err := findFreePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	vars := map[string]string{
+		"PORT":           fmt.Sprintf("%v", port),
+		"USERFUNC_DIR":   userfuncDir,
+		"CODE_PATH":      filepath.Join(userfuncDir, targetFilename),
+		"SECRETS_DIR":    secretsDir,
+		"CONFIGMAPS_DIR": configMapsDir,
+	}
+	p, err := startProcess(dir, env.Spec.Runtime.LocalCommand, vars)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	address := fmt.Sprintf("127.0.0.1:%v", port)
+	err = specialize(address, env, fn, vars["CODE_PATH"], fnEnv, p.exited)
+	if err != nil {
+		log.Printf("[%v] failed to specialize local process: %v", metadata.Name, err)
+		p.stop()
+		return nil, err
+	}
+	log.Printf("[%v] specialized local process %v at %v", metadata.Name, name, address)
+
+	fsvc := &fscache.FuncSvc{
+		Name:        name,
+		Function:    metadata,
+		Environment: env,
+		Address:     address,
+		Executor:    fission.ExecutorTypeLocal,
+		Ctime:       time.Now(),
+		Atime:       time.Now(),
+	}
+	le.lock.Lock()
+	le.processes[name] = p
+	le.lock.Unlock()
+
+	_, err = le.fsCache.Add(*fsvc)
+	if err != nil {
+		le.lock.Lock()
+		delete(le.processes, name)
+		le.lock.Unlock()
+		p.stop()
+		return nil, err
+	}
+	return fsvc, nil
+}
+
+// IsValid checks that the function service's process is still running.
+func (le *LocalExecutor) IsValid(fsvc *fscache.FuncSvc) bool {
+	le.lock.Lock()
+	p, ok := le.processes[fsvc.Name]
+	le.lock.Unlock()
+	if !ok {
+		return false
+	}
+	select {
+	case <-p.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// DeleteFuncSvc stops the function service's process and removes its
+// directory.
+func (le *LocalExecutor) DeleteFuncSvc(fsvc *fscache.FuncSvc) error {
+	le.fsCache.DeleteEntry(fsvc)
+
+	le.lock.Lock()
+	p, ok := le.processes[fsvc.Name]
+	delete(le.processes, fsvc.Name)
+	le.lock.Unlock()
+
+	if !ok {
+		return fission.MakeError(fission.ErrorNotFound, fmt.Sprintf("no process for function service %v", fsvc.Name))
+	}
+	p.stop()
+	return nil
+}
+
+func (le *LocalExecutor) ListFuncSvcs() ([]*fscache.FuncSvc, error) {
+	return le.fsCache.ListByExecutor(fission.ExecutorTypeLocal), nil
+}
+
+// findFreePort asks the kernel for an unused port on the loopback
+// interface.
+func findFreePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+func startProcess(dir string, command []string, vars map[string]string) (*process, error) {
+	expand := func(s string) string {
+		return os.Expand(s, func(v string) string {
+			if val, ok := vars[v]; ok {
+				return val
+			}
+			return os.Getenv(v)
+		})
+	}
+
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = expand(arg)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	for k, v := range vars {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%v", k, v))
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &process{
+		dir:    dir,
+		cmd:    cmd,
+		exited: make(chan struct{}),
+	}
+	go func() {
+		err := cmd.Wait()
+		log.Printf("Local process %v (pid %v) exited: %v", filepath.Base(dir), cmd.Process.Pid, err)
+		close(p.exited)
+	}()
+	return p, nil
+}
+
+// stop terminates the process, killing it if it doesn't exit within a
+// few seconds, and removes its directory.
+func (p *process) stop() {
+	p.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-p.exited:
+	case <-time.After(5 * time.Second):
+		p.cmd.Process.Kill()
+		<-p.exited
+	}
+	err := os.RemoveAll(p.dir)
+	if err != nil {
+		log.Printf("Error removing %v: %v", p.dir, err)
+	}
+}
+
+// specialize calls the environment server's load endpoint, retrying while
+// the server is starting up.
+func specialize(address string, env *crd.Environment, fn *crd.Function, codePath string, fnEnv map[string]string,
+	exited chan struct{}) error {
+
+	var specializeUrl, contentType string
+	var body []byte
+	if env.Spec.Version == 2 {
+		loadReq := fission.FunctionLoadRequest{
+			FilePath:         codePath,
+			FunctionName:     fn.Spec.Package.FunctionName,
+			FunctionMetadata: &fn.Metadata,
+			Env:              fnEnv,
+		}
+		var err error
+		body, err = json.Marshal(loadReq)
+		if err != nil {
+			return err
+		}
+		specializeUrl = fmt.Sprintf("http://%v/v2/specialize", address)
+		contentType = "application/json"
+	} else {
+		specializeUrl = fmt.Sprintf("http://%v/specialize", address)
+		contentType = "text/plain"
+	}
+
+	maxRetries := 20
+	for i := 0; i < maxRetries; i++ {
+		resp, err := http.Post(specializeUrl, contentType, bytes.NewReader(body))
+		if err == nil {
+			defer resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return nil
+			}
+			return fission.MakeErrorFromHTTP(resp)
+		}
+		if !isDialError(err) {
+			return err
+		}
+
+		select {
+		case <-exited:
+			return errors.New("environment process exited before it was specialized")
+		case <-time.After(100 * time.Duration(i+1) * time.Millisecond):
+		}
+	}
+	return fmt.Errorf("environment process didn't start listening on %v", address)
+}
+
+func isDialError(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	return fission.IsNetworkDialError(err)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package localexec
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/executortype"
+)
+
+func TestStartProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localexec")
+	if err != nil {
+		log.Panicf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	vars := map[string]string{
+		"PORT":      "1234",
+		"CODE_PATH": "/tmp/code",
+	}
+	// $CODE_PATH is expanded by startProcess, PORT is read from the
+	// process environment
+	p, err := startProcess(dir, []string{"sh", "-c", "echo $CODE_PATH > out; printenv PORT >> out"}, vars)
+	if err != nil {
+		log.Panicf("failed to start process: %v", err)
+	}
+	<-p.exited
+
+	out, err := ioutil.ReadFile(filepath.Join(dir, "out"))
+	if err != nil {
+		log.Panicf("failed to read process output: %v", err)
+	}
+	if string(out) != "/tmp/code\n1234\n" {
+		log.Panicf("unexpected process output %q", string(out))
+	}
+
+	p.stop()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		log.Panicf("process directory wasn't removed")
+	}
+}
+
+func TestSpecialize(t *testing.T) {
+	var loadReq fission.FunctionLoadRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/specialize" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&loadReq)
+	}))
+	defer ts.Close()
+
+	env := &crd.Environment{Spec: fission.EnvironmentSpec{Version: 2}}
+	fn := &crd.Function{}
+	fn.Spec.Package.FunctionName = "main"
+
+	fnEnv := map[string]string{"FOO": "bar"}
+	err := specialize(strings.TrimPrefix(ts.URL, "http://"), env, fn, "/tmp/code", fnEnv, make(chan struct{}))
+	if err != nil {
+		log.Panicf("failed to specialize: %v", err)
+	}
+	if loadReq.FilePath != "/tmp/code" || loadReq.FunctionName != "main" || loadReq.Env["FOO"] != "bar" {
+		log.Panicf("unexpected load request %#v", loadReq)
+	}
+
+	// v1 environments are loaded at /specialize
+	env.Spec.Version = 1
+	err = specialize(strings.TrimPrefix(ts.URL, "http://"), env, fn, "/tmp/code", nil, make(chan struct{}))
+	if err == nil {
+		log.Panicf("expected error from v1 specialize")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	isRegistered := func() bool {
+		for _, name := range executortype.Registered() {
+			if name == fission.ExecutorTypeLocal {
+				return true
+			}
+		}
+		return false
+	}
+	if isRegistered() {
+		log.Panicf("local executor type registered without Register")
+	}
+	Register()
+	if !isRegistered() {
+		log.Panicf("local executor type not registered")
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/fission/fission/executor/fscache"
+)
+
+var (
+	idleReaps = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "fission",
+			Subsystem: "executor",
+			Name:      "idle_reaps_total",
+			Help:      "Number of idle function services reaped, by executor type.",
+		},
+		[]string{"executortype"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(idleReaps)
+}
+
+// registerCacheMetrics exports the number of entries in the function
+// service cache.
+func registerCacheMetrics(fsCache *fscache.FunctionServiceCache) error {
+	return prometheus.Register(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: "fission",
+			Subsystem: "executor",
+			Name:      "function_service_cache_entries",
+			Help:      "Number of function services in the executor's cache.",
+		},
+		func() float64 { return float64(fsCache.Len()) },
+	))
+}
@@ -0,0 +1,397 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netpolicy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	k8s_err "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+)
+
+const (
+	syncInterval = 30 * time.Second
+
+	// name of the policy applying to all pods in the function namespace
+	defaultPolicyName = "fission-function-default"
+
+	// hash of the spec the executor last wrote, so that fields the
+	// API server doesn't know don't cause endless updates
+	specHashAnnotation = "fission.io/network-policy-spec"
+)
+
+type (
+	// networkPolicy is a networking.k8s.io/v1 NetworkPolicy. The client
+	// library predates egress rules, so policies are sent as JSON.
+	networkPolicy struct {
+		metav1.TypeMeta `json:",inline"`
+		Metadata        metav1.ObjectMeta `json:"metadata"`
+		Spec            networkPolicySpec `json:"spec"`
+	}
+
+	networkPolicyList struct {
+		metav1.TypeMeta `json:",inline"`
+		Metadata        metav1.ListMeta `json:"metadata"`
+		Items           []networkPolicy `json:"items"`
+	}
+
+	networkPolicySpec struct {
+		PodSelector metav1.LabelSelector `json:"podSelector"`
+		Ingress     []ingressRule        `json:"ingress,omitempty"`
+		Egress      []egressRule         `json:"egress,omitempty"`
+		PolicyTypes []string             `json:"policyTypes,omitempty"`
+	}
+
+	ingressRule struct {
+		From []policyPeer `json:"from,omitempty"`
+	}
+
+	egressRule struct {
+		Ports []policyPort `json:"ports,omitempty"`
+		To    []policyPeer `json:"to,omitempty"`
+	}
+
+	// A peer with both a namespace and a pod selector selects the
+	// matching pods of the matching namespaces (Kubernetes 1.11+).
+	policyPeer struct {
+		NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+		PodSelector       *metav1.LabelSelector `json:"podSelector,omitempty"`
+		IPBlock           *ipBlock              `json:"ipBlock,omitempty"`
+	}
+
+	ipBlock struct {
+		CIDR string `json:"cidr"`
+	}
+
+	policyPort struct {
+		Protocol string              `json:"protocol"`
+		Port     *intstr.IntOrString `json:"port,omitempty"`
+	}
+
+	// Manager keeps the network policies of the function namespace in
+	// step with functions and environments. Function pods only accept
+	// connections from the router and the executor, and only connect to
+	// DNS, the Kubernetes API, the storage service and the destinations
+	// their function allows.
+	Manager struct {
+		kubernetesClient  *kubernetes.Clientset
+		fissionClient     *crd.FissionClient
+		fissionNamespace  string
+		functionNamespace string
+	}
+)
+
+func MakeManager(kubernetesClient *kubernetes.Clientset, fissionClient *crd.FissionClient,
+	fissionNamespace string, functionNamespace string) *Manager {
+	return &Manager{
+		kubernetesClient:  kubernetesClient,
+		fissionClient:     fissionClient,
+		fissionNamespace:  fissionNamespace,
+		functionNamespace: functionNamespace,
+	}
+}
+
+// Run syncs the network policies periodically. It doesn't block.
+func (m *Manager) Run(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(syncInterval)
+		defer ticker.Stop()
+		for {
+			err := m.sync()
+			if err != nil {
+				log.Printf("Error syncing network policies: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func namespaceSelector(namespace string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{fission.NAMESPACE_NAME_LABEL: namespace},
+	}
+}
+
+// fissionComponent selects the pods of a Fission component, by the svc
+// label of its deployment, e.g. "router".
+func fissionComponent(fissionNamespace string, svc string) policyPeer {
+	return policyPeer{
+		NamespaceSelector: namespaceSelector(fissionNamespace),
+		PodSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"svc": svc},
+		},
+	}
+}
+
+// defaultPolicy isolates all pods of the function namespace. The router
+// sends them requests, and the executor specializes them. The fetcher
+// needs the Kubernetes API, at apiServers, and the storage service.
+func defaultPolicy(fissionNamespace string, apiServers []egressRule) networkPolicySpec {
+	dns := intstr.FromInt(53)
+	return networkPolicySpec{
+		Ingress: []ingressRule{
+			{From: []policyPeer{
+				fissionComponent(fissionNamespace, "router"),
+				fissionComponent(fissionNamespace, "executor"),
+			}},
+		},
+		Egress: append([]egressRule{
+			{Ports: []policyPort{{Protocol: "UDP", Port: &dns}, {Protocol: "TCP", Port: &dns}}},
+			{To: []policyPeer{fissionComponent(fissionNamespace, "storagesvc")}},
+		}, apiServers...),
+		PolicyTypes: []string{"Ingress", "Egress"},
+	}
+}
+
+// functionPolicy returns the egress the function's pods are allowed on
+// top of the default policy, if any.
+func functionPolicy(fn *crd.Function, env *crd.Environment) (networkPolicySpec, bool) {
+	spec := networkPolicySpec{
+		PodSelector: metav1.LabelSelector{
+			MatchLabels: map[string]string{"functionUid": string(fn.Metadata.UID)},
+		},
+		PolicyTypes: []string{"Egress"},
+	}
+	if env != nil && env.Spec.AllowAccessToExternalNetwork {
+		// a rule without destinations or ports allows all
+		spec.Egress = []egressRule{{}}
+		return spec, true
+	}
+
+	access := fn.Spec.Network
+	if access == nil || (len(access.EgressCIDRs) == 0 && len(access.EgressNamespaces) == 0) {
+		return spec, false
+	}
+	rule := egressRule{}
+	for _, cidr := range access.EgressCIDRs {
+		ipNet, err := fission.ParseCIDROrIP(cidr)
+		if err != nil {
+			log.Printf("Ignoring invalid egress CIDR %v of function %v: %v", cidr, fn.Metadata.Name, err)
+			continue
+		}
+		rule.To = append(rule.To, policyPeer{IPBlock: &ipBlock{CIDR: ipNet.String()}})
+	}
+	for _, ns := range access.EgressNamespaces {
+		rule.To = append(rule.To, policyPeer{NamespaceSelector: namespaceSelector(ns)})
+	}
+	if len(rule.To) == 0 {
+		return spec, false
+	}
+	spec.Egress = []egressRule{rule}
+	return spec, true
+}
+
+func functionPolicyName(uid types.UID) string {
+	return fmt.Sprintf("fission-function-%v", uid)
+}
+
+// apiServerRules allows connections to the endpoints of the Kubernetes
+// API. Policies apply after services are resolved, so the service's
+// address wouldn't do.
+func (m *Manager) apiServerRules() ([]egressRule, error) {
+	ep, err := m.kubernetesClient.CoreV1().Endpoints(metav1.NamespaceDefault).Get("kubernetes", metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]egressRule, 0, len(ep.Subsets))
+	for _, subset := range ep.Subsets {
+		rule := egressRule{}
+		for _, addr := range subset.Addresses {
+			rule.To = append(rule.To, policyPeer{IPBlock: &ipBlock{CIDR: addr.IP + "/32"}})
+		}
+		for _, p := range subset.Ports {
+			port := intstr.FromInt(int(p.Port))
+			rule.Ports = append(rule.Ports, policyPort{Protocol: string(p.Protocol), Port: &port})
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// labelNamespace labels the namespace with its name, for policies to
+// select it by. Only the Fission namespace is labeled by the executor;
+// the namespaces functions may connect to must be labeled when they're
+// set up, since function authors needn't be allowed to change them.
+func (m *Manager) labelNamespace(name string) error {
+	ns, err := m.kubernetesClient.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if ns.ObjectMeta.Labels[fission.NAMESPACE_NAME_LABEL] == name {
+		return nil
+	}
+	if ns.ObjectMeta.Labels == nil {
+		ns.ObjectMeta.Labels = make(map[string]string)
+	}
+	ns.ObjectMeta.Labels[fission.NAMESPACE_NAME_LABEL] = name
+	_, err = m.kubernetesClient.CoreV1().Namespaces().Update(ns)
+	return err
+}
+
+func (m *Manager) sync() error {
+	apiServers, err := m.apiServerRules()
+	if err != nil {
+		return err
+	}
+	err = m.labelNamespace(m.fissionNamespace)
+	if err != nil {
+		log.Printf("Error labeling namespace %v for network policies: %v", m.fissionNamespace, err)
+	}
+
+	desired := map[string]networkPolicySpec{
+		defaultPolicyName: defaultPolicy(m.fissionNamespace, apiServers),
+	}
+
+	fns, err := m.fissionClient.Functions(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	envs, err := m.fissionClient.Environments(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	envsByName := make(map[string]*crd.Environment)
+	for i := range envs.Items {
+		env := &envs.Items[i]
+		envsByName[env.Metadata.Namespace+"/"+env.Metadata.Name] = env
+	}
+	for i := range fns.Items {
+		fn := &fns.Items[i]
+		env := envsByName[fn.Spec.Environment.Namespace+"/"+fn.Spec.Environment.Name]
+		spec, ok := functionPolicy(fn, env)
+		if !ok {
+			continue
+		}
+		desired[functionPolicyName(fn.Metadata.UID)] = spec
+	}
+
+	existing, err := m.list()
+	if err != nil {
+		return err
+	}
+	for name, spec := range desired {
+		err := m.apply(name, spec, existing[name])
+		if err != nil {
+			log.Printf("Error applying network policy %v: %v", name, err)
+		}
+		delete(existing, name)
+	}
+	for name := range existing {
+		log.Printf("Deleting network policy %v", name)
+		err := m.kubernetesClient.NetworkingV1().RESTClient().Delete().
+			Namespace(m.functionNamespace).Resource("networkpolicies").Name(name).
+			Do().Error()
+		if err != nil && !k8s_err.IsNotFound(err) {
+			log.Printf("Error deleting network policy %v: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// list returns the network policies managed by the executor, by name.
+func (m *Manager) list() (map[string]*networkPolicy, error) {
+	raw, err := m.kubernetesClient.NetworkingV1().RESTClient().Get().
+		Namespace(m.functionNamespace).Resource("networkpolicies").
+		Param("labelSelector", labels.Set(map[string]string{fission.NETWORK_POLICY_LABEL: "true"}).AsSelector().String()).
+		Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+	var list networkPolicyList
+	err = json.Unmarshal(raw, &list)
+	if err != nil {
+		return nil, err
+	}
+	policies := make(map[string]*networkPolicy)
+	for i := range list.Items {
+		policies[list.Items[i].Metadata.Name] = &list.Items[i]
+	}
+	return policies, nil
+}
+
+func specHash(spec networkPolicySpec) (string, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// apply creates the network policy, or updates the existing one if its
+// spec changed.
+func (m *Manager) apply(name string, spec networkPolicySpec, existing *networkPolicy) error {
+	hash, err := specHash(spec)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Metadata.Annotations[specHashAnnotation] == hash {
+		return nil
+	}
+
+	policy := networkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "NetworkPolicy",
+			APIVersion: "networking.k8s.io/v1",
+		},
+		Metadata: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   m.functionNamespace,
+			Labels:      map[string]string{fission.NETWORK_POLICY_LABEL: "true"},
+			Annotations: map[string]string{specHashAnnotation: hash},
+		},
+		Spec: spec,
+	}
+	if existing != nil {
+		policy.Metadata.ResourceVersion = existing.Metadata.ResourceVersion
+	}
+	body, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	client := m.kubernetesClient.NetworkingV1().RESTClient()
+	if existing == nil {
+		log.Printf("Creating network policy %v", name)
+		return client.Post().
+			Namespace(m.functionNamespace).Resource("networkpolicies").
+			Body(body).
+			Do().Error()
+	}
+	log.Printf("Updating network policy %v", name)
+	return client.Put().
+		Namespace(m.functionNamespace).Resource("networkpolicies").Name(name).
+		Body(body).
+		Do().Error()
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netpolicy
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+)
+
+func TestFunctionPolicy(t *testing.T) {
+	fn := &crd.Function{
+		Metadata: metav1.ObjectMeta{Name: "foo", UID: "1234"},
+	}
+	env := &crd.Environment{}
+
+	if _, ok := functionPolicy(fn, env); ok {
+		log.Panicf("policy for a function without network access")
+	}
+
+	fn.Spec.Network = &fission.NetworkAccess{
+		EgressCIDRs:      []string{"10.1.2.3", "not-a-cidr"},
+		EgressNamespaces: []string{"db"},
+	}
+	spec, ok := functionPolicy(fn, env)
+	if !ok || len(spec.Egress) != 1 {
+		log.Panicf("no egress for the function: %v", spec)
+	}
+	to := spec.Egress[0].To
+	if len(to) != 2 || to[0].IPBlock.CIDR != "10.1.2.3/32" ||
+		to[1].NamespaceSelector.MatchLabels[fission.NAMESPACE_NAME_LABEL] != "db" {
+		log.Panicf("wrong egress destinations: %v", to)
+	}
+	if spec.PodSelector.MatchLabels["functionUid"] != "1234" {
+		log.Panicf("policy doesn't select the function's pods")
+	}
+
+	env.Spec.AllowAccessToExternalNetwork = true
+	spec, ok = functionPolicy(fn, env)
+	if !ok {
+		log.Panicf("no policy for a function with external network access")
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		log.Panicf("error encoding policy: %v", err)
+	}
+	if !strings.Contains(string(data), `"egress":[{}]`) {
+		log.Panicf("policy doesn't allow all egress: %s", data)
+	}
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	spec := defaultPolicy("fission", nil)
+
+	// only the router and executor connect to function pods
+	from := spec.Ingress[0].From
+	if len(from) != 2 {
+		log.Panicf("wrong ingress sources: %v", from)
+	}
+	for i, svc := range []string{"router", "executor"} {
+		if from[i].PodSelector == nil || from[i].PodSelector.MatchLabels["svc"] != svc ||
+			from[i].NamespaceSelector.MatchLabels[fission.NAMESPACE_NAME_LABEL] != "fission" {
+			log.Panicf("ingress source %v doesn't select the %v: %v", i, svc, from[i])
+		}
+	}
+
+	// and function pods only connect to the storage service of the
+	// Fission namespace, besides DNS
+	for _, rule := range spec.Egress {
+		for _, to := range rule.To {
+			if to.NamespaceSelector != nil &&
+				(to.PodSelector == nil || to.PodSelector.MatchLabels["svc"] != "storagesvc") {
+				log.Panicf("egress to pods other than the storage service: %v", to)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package newdeploy
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/pkg/api"
+	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/fscache"
+)
+
+// AdoptExistingResources adds the deployments of newdeploy functions
+// created by earlier executor instances to the function service cache,
+// along with their services and HPAs. It returns the UIDs of the adopted
+// objects.
+func (deploy *NewDeploy) AdoptExistingResources() ([]types.UID, error) {
+	deplList, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).List(metav1.ListOptions{
+		LabelSelector: labels.Set(map[string]string{
+			"executorType": fission.ExecutorTypeNewdeploy,
+		}).AsSelector().String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fnList, err := deploy.fissionClient.Functions(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	fns := make(map[types.UID]*crd.Function)
+	for i := range fnList.Items {
+		fns[fnList.Items[i].Metadata.UID] = &fnList.Items[i]
+	}
+
+	adopted := make([]types.UID, 0)
+	for i := range deplList.Items {
+		depl := &deplList.Items[i]
+		fn, ok := fns[types.UID(depl.ObjectMeta.Labels["functionUid"])]
+		if !ok {
+			continue
+		}
+		adopted = append(adopted, deploy.adoptDeployment(depl, fn)...)
+	}
+	return adopted, nil
+}
+
+// adoptDeployment adds the deployment of another executor instance to the
+// cache, with its service and HPA, if its function still uses newdeploy.
+// A deployment scaled down while idle is only tracked as idle. It returns
+// the UIDs of the adopted objects.
+func (deploy *NewDeploy) adoptDeployment(depl *v1beta1.Deployment, fn *crd.Function) []types.UID {
+	if depl.ObjectMeta.Labels[fission.EXECUTOR_INSTANCEID_LABEL] == deploy.instanceID ||
+		fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType != fission.ExecutorTypeNewdeploy {
+		return nil
+	}
+	if _, err := deploy.fsCache.GetByFunctionUID(fn.Metadata.UID); err == nil {
+		// the function already has a function service
+		return nil
+	}
+
+	env, err := deploy.fissionClient.Environments(fn.Spec.Environment.Namespace).Get(fn.Spec.Environment.Name)
+	if err != nil {
+		log.Printf("Not adopting deployment %v, error getting environment: %v", depl.ObjectMeta.Name, err)
+		return nil
+	}
+	svc, err := deploy.kubernetesClient.CoreV1().Services(deploy.namespace).Get(depl.ObjectMeta.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("Not adopting deployment %v, error getting service: %v", depl.ObjectMeta.Name, err)
+		return nil
+	}
+
+	kubeObjRefs := []api.ObjectReference{
+		{
+			Kind:            "deployment",
+			Name:            depl.ObjectMeta.Name,
+			APIVersion:      depl.TypeMeta.APIVersion,
+			Namespace:       depl.ObjectMeta.Namespace,
+			ResourceVersion: depl.ObjectMeta.ResourceVersion,
+			UID:             depl.ObjectMeta.UID,
+		},
+		{
+			Kind:            "service",
+			Name:            svc.ObjectMeta.Name,
+			APIVersion:      svc.TypeMeta.APIVersion,
+			Namespace:       svc.ObjectMeta.Namespace,
+			ResourceVersion: svc.ObjectMeta.ResourceVersion,
+			UID:             svc.ObjectMeta.UID,
+		},
+	}
+	uids := []types.UID{depl.ObjectMeta.UID, svc.ObjectMeta.UID}
+
+	hpa, err := deploy.kubernetesClient.AutoscalingV1().HorizontalPodAutoscalers(deploy.namespace).Get(depl.ObjectMeta.Name, metav1.GetOptions{})
+	if err == nil {
+		kubeObjRefs = append(kubeObjRefs, api.ObjectReference{
+			Kind:            "horizontalpodautoscaler",
+			Name:            hpa.ObjectMeta.Name,
+			APIVersion:      hpa.TypeMeta.APIVersion,
+			Namespace:       hpa.ObjectMeta.Namespace,
+			ResourceVersion: hpa.ObjectMeta.ResourceVersion,
+			UID:             hpa.ObjectMeta.UID,
+		})
+		uids = append(uids, hpa.ObjectMeta.UID)
+	}
+
+	fsvc := fscache.FuncSvc{
+		Name:              depl.ObjectMeta.Name,
+		Function:          &fn.Metadata,
+		Environment:       env,
+		Address:           fmt.Sprintf("%v.%v", svc.ObjectMeta.Name, svc.ObjectMeta.Namespace),
+		KubernetesObjects: kubeObjRefs,
+		Executor:          fission.ExecutorTypeNewdeploy,
+		Ctime:             time.Now(),
+		Atime:             time.Now(),
+	}
+	if isScaledToZero(depl) {
+		// scaled down while idle
+		deploy.idle.add(&fsvc)
+		log.Printf("Adopted idle deployment %v for function %v", depl.ObjectMeta.Name, fn.Metadata.Name)
+		return uids
+	}
+
+	_, err = deploy.fsCache.Add(fsvc)
+	if err != nil {
+		log.Printf("Not adopting deployment %v, error adding it to the cache: %v", depl.ObjectMeta.Name, err)
+		return nil
+	}
+	log.Printf("Adopted deployment %v for function %v", depl.ObjectMeta.Name, fn.Metadata.Name)
+	return uids
+}
+
+func isScaledToZero(depl *v1beta1.Deployment) bool {
+	return depl.Spec.Replicas != nil && *depl.Spec.Replicas == 0
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package newdeploy
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	k8s_err "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/fscache"
+)
+
+const (
+	concurrencyScaleInterval = 10 * time.Second
+
+	// Routers report every few seconds; a router that stopped reporting
+	// has no requests in flight.
+	concurrencyReportTTL = 15 * time.Second
+
+	// Replica recommendations are stabilised over these windows: the deployment
+	// scales up to the lowest recommendation of the last
+	// scaleUpStabilizationWindow, and down to the highest recommendation of
+	// the last scaleDownStabilizationWindow.
+	scaleUpStabilizationWindow   = 20 * time.Second
+	scaleDownStabilizationWindow = 5 * time.Minute
+)
+
+type (
+	// concurrencyScaler resizes the deployments of functions with a
+	// TargetConcurrency, from the in-flight requests reported by routers.
+	concurrencyScaler struct {
+		lock      sync.Mutex
+		functions map[types.UID]*functionConcurrency
+	}
+
+	functionConcurrency struct {
+		fsvc            *fscache.FuncSvc
+		reports         map[string]concurrencyReport // router -> latest report
+		recommendations []replicaRecommendation
+	}
+
+	concurrencyReport struct {
+		concurrency float64
+		time        time.Time
+	}
+
+	replicaRecommendation struct {
+		replicas int32
+		time     time.Time
+	}
+)
+
+func makeConcurrencyScaler() *concurrencyScaler {
+	return &concurrencyScaler{
+		functions: make(map[types.UID]*functionConcurrency),
+	}
+}
+
+func (cs *concurrencyScaler) observe(fsvc *fscache.FuncSvc, reporter string, concurrency float64, now time.Time) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	fc, ok := cs.functions[fsvc.Function.UID]
+	if !ok {
+		fc = &functionConcurrency{
+			reports: make(map[string]concurrencyReport),
+		}
+		cs.functions[fsvc.Function.UID] = fc
+	}
+	fc.fsvc = fsvc
+	fc.reports[reporter] = concurrencyReport{concurrency: concurrency, time: now}
+}
+
+func (cs *concurrencyScaler) forget(uid types.UID) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	delete(cs.functions, uid)
+}
+
+// list returns the function services that concurrency was reported for.
+func (cs *concurrencyScaler) list() []*fscache.FuncSvc {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	fsvcs := make([]*fscache.FuncSvc, 0, len(cs.functions))
+	for _, fc := range cs.functions {
+		fsvcs = append(fsvcs, fc.fsvc)
+	}
+	return fsvcs
+}
+
+// recommend returns the number of replicas the function should run,
+// given its current replicas.
+func (cs *concurrencyScaler) recommend(uid types.UID, es *fission.ExecutionStrategy, current int32, now time.Time) int32 {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	fc, ok := cs.functions[uid]
+	if !ok || es.TargetConcurrency <= 0 {
+		return current
+	}
+
+	// add up the reports of all routers
+	total := 0.0
+	for reporter, report := range fc.reports {
+		if now.Sub(report.time) > concurrencyReportTTL {
+			delete(fc.reports, reporter)
+			continue
+		}
+		total += report.concurrency
+	}
+
+	desired := int32(math.Ceil(total / float64(es.TargetConcurrency)))
+	floor := minReplicas(es, es.WarmInstances(now))
+	if desired > int32(es.MaxScale) {
+		desired = int32(es.MaxScale)
+	}
+	if desired < floor {
+		desired = floor
+	}
+
+	recommendations := []replicaRecommendation{{replicas: desired, time: now}}
+	for _, r := range fc.recommendations {
+		if now.Sub(r.time) < scaleDownStabilizationWindow {
+			recommendations = append(recommendations, r)
+		}
+	}
+	fc.recommendations = recommendations
+
+	upLimit, downLimit := desired, desired
+	for _, r := range recommendations {
+		if now.Sub(r.time) < scaleUpStabilizationWindow && r.replicas < upLimit {
+			upLimit = r.replicas
+		}
+		if r.replicas > downLimit {
+			downLimit = r.replicas
+		}
+	}
+
+	if current < upLimit {
+		return upLimit
+	}
+	if current > downLimit {
+		return downLimit
+	}
+	return current
+}
+
+// ObserveConcurrency records the concurrency that a router reported for
+// the function service.
+func (deploy *NewDeploy) ObserveConcurrency(fsvc *fscache.FuncSvc, reporter string, concurrency float64) {
+	deploy.concurrencyScaler.observe(fsvc, reporter, concurrency, time.Now())
+}
+
+func (deploy *NewDeploy) runConcurrencyScaler(ctx context.Context) {
+	ticker := time.NewTicker(concurrencyScaleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, fsvc := range deploy.concurrencyScaler.list() {
+				err := deploy.scaleOnConcurrency(fsvc, now)
+				if err != nil {
+					log.Printf("Error scaling function %v on concurrency: %v", fsvc.Function.Name, err)
+				}
+			}
+		}
+	}
+}
+
+func (deploy *NewDeploy) scaleOnConcurrency(fsvc *fscache.FuncSvc, now time.Time) error {
+	fn, err := deploy.fissionClient.Functions(fsvc.Function.Namespace).Get(fsvc.Function.Name)
+	if err != nil {
+		if k8s_err.IsNotFound(err) {
+			deploy.concurrencyScaler.forget(fsvc.Function.UID)
+			return nil
+		}
+		return err
+	}
+	es := &fn.Spec.InvokeStrategy.ExecutionStrategy
+	if es.ExecutorType != fission.ExecutorTypeNewdeploy || es.TargetConcurrency <= 0 {
+		deploy.concurrencyScaler.forget(fsvc.Function.UID)
+		return nil
+	}
+
+	depl, err := deploy.getDeployment(fn)
+	if err != nil {
+		return err
+	}
+	current := int32(1)
+	if depl.Spec.Replicas != nil {
+		current = *depl.Spec.Replicas
+	}
+	if current == 0 {
+		// scaled down while idle; GetFuncSvc scales it back up
+		return nil
+	}
+
+	replicas := deploy.concurrencyScaler.recommend(fn.Metadata.UID, es, current, now)
+	if replicas == current {
+		return nil
+	}
+	log.Printf("Scaling function %v from %v to %v replicas on concurrency", fn.Metadata.Name, current, replicas)
+	depl.Spec.Replicas = &replicas
+	return deploy.updateDeployment(depl)
+}
+
+// updateScalingMode creates or deletes the function's HPA when it switches
+// between CPU and concurrency based scaling.
+func (deploy *NewDeploy) updateScalingMode(oldFn *crd.Function, newFn *crd.Function) error {
+	oldConcurrency := oldFn.Spec.InvokeStrategy.ExecutionStrategy.TargetConcurrency
+	newConcurrency := newFn.Spec.InvokeStrategy.ExecutionStrategy.TargetConcurrency
+
+	switch {
+	case oldConcurrency == 0 && newConcurrency > 0:
+		err := deploy.deleteHpa(deploy.namespace, deploy.getObjName(newFn))
+		if err != nil && !k8s_err.IsNotFound(err) {
+			return err
+		}
+	case oldConcurrency > 0 && newConcurrency == 0:
+		deploy.concurrencyScaler.forget(newFn.Metadata.UID)
+		depl, err := deploy.getDeployment(newFn)
+		if err != nil {
+			return err
+		}
+		_, err = deploy.createOrGetHpa(deploy.getObjName(newFn), &newFn.Spec.InvokeStrategy.ExecutionStrategy, depl)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package newdeploy
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/executor/fscache"
+)
+
+func TestConcurrencyScaler(t *testing.T) {
+	cs := makeConcurrencyScaler()
+	fsvc := &fscache.FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "foo", UID: "1234"},
+	}
+	es := &fission.ExecutionStrategy{
+		ExecutorType:      fission.ExecutorTypeNewdeploy,
+		MinScale:          1,
+		MaxScale:          10,
+		TargetConcurrency: 4,
+	}
+	now := time.Now()
+
+	// unknown functions keep their replicas
+	if r := cs.recommend("5678", es, 3, now); r != 3 {
+		log.Panicf("expected 3 replicas for unknown function, got %v", r)
+	}
+
+	// 18 requests in flight across two routers need 5 pods; the first
+	// recommendation scales up right away
+	cs.observe(fsvc, "router-a", 10, now)
+	cs.observe(fsvc, "router-b", 8, now)
+	if r := cs.recommend("1234", es, 1, now); r != 5 {
+		log.Panicf("expected scale up to 5, got %v", r)
+	}
+
+	// a higher recommendation within the scale up window is capped by the
+	// lower recent one
+	now = now.Add(10 * time.Second)
+	cs.observe(fsvc, "router-a", 30, now)
+	cs.observe(fsvc, "router-b", 8, now)
+	if r := cs.recommend("1234", es, 5, now); r != 5 {
+		log.Panicf("expected to stay at 5 within the scale up window, got %v", r)
+	}
+
+	// once the window has passed, the higher demand is followed, capped
+	// at MaxScale
+	now = now.Add(scaleUpStabilizationWindow)
+	cs.observe(fsvc, "router-a", 40, now)
+	cs.observe(fsvc, "router-b", 8, now)
+	if r := cs.recommend("1234", es, 5, now); r != 10 {
+		log.Panicf("expected scale up to 10, got %v", r)
+	}
+
+	// the routers stop reporting; the deployment isn't scaled down until
+	// the scale down window has passed
+	now = now.Add(concurrencyReportTTL + time.Second)
+	if r := cs.recommend("1234", es, 10, now); r != 10 {
+		log.Panicf("expected to stay at 10 within the scale down window, got %v", r)
+	}
+	now = now.Add(scaleDownStabilizationWindow)
+	if r := cs.recommend("1234", es, 10, now); r != 1 {
+		log.Panicf("expected scale down to 1, got %v", r)
+	}
+
+	// an open window of the warm schedule keeps more replicas
+	es.WarmSchedule = []fission.WarmWindow{
+		{Cron: "0 * * * * *", Duration: 3600, MinInstances: 3},
+	}
+	now = now.Add(scaleUpStabilizationWindow)
+	if r := cs.recommend("1234", es, 1, now); r != 3 {
+		log.Panicf("expected scale up to 3 for the warm schedule, got %v", r)
+	}
+
+	cs.forget("1234")
+	if len(cs.list()) != 0 {
+		log.Panicf("expected no functions after forget")
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package newdeploy
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	k8sCache "k8s.io/client-go/tools/cache"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/util"
+)
+
+// Follow keeps the cache of a follower replica in step with the
+// deployments of the leader. Deployments scaled down while idle are
+// dropped from the cache, so that requests for their function go to the
+// leader, which scales them up again.
+func (deploy *NewDeploy) Follow(stop <-chan struct{}) {
+	var deplStore k8sCache.Store
+	var fnIndex k8sCache.Indexer
+
+	followDeployment := func(obj interface{}) {
+		depl := obj.(*v1beta1.Deployment)
+		if depl.ObjectMeta.DeletionTimestamp != nil || isScaledToZero(depl) {
+			deploy.unfollowDeployment(depl.ObjectMeta.Name)
+			return
+		}
+		fn, ok := util.FunctionByUID(fnIndex, types.UID(depl.ObjectMeta.Labels["functionUid"]))
+		if !ok {
+			return
+		}
+		deploy.adoptDeployment(depl, fn)
+	}
+
+	fnIndex, fnController := util.MakeFunctionIndex(deploy.crdClient, k8sCache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+			// the function service is cached by function version
+			fn := newObj.(*crd.Function)
+			deploy.unfollowFunction(fn.Metadata.UID)
+			for _, obj := range deplStore.List() {
+				if obj.(*v1beta1.Deployment).ObjectMeta.Labels["functionUid"] == string(fn.Metadata.UID) {
+					followDeployment(obj)
+				}
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if fn, ok := util.DeletedObject(obj).(*crd.Function); ok {
+				deploy.unfollowFunction(fn.Metadata.UID)
+			}
+		},
+	})
+
+	// Resyncs from the informer's store pick up deployments seen before
+	// their function.
+	selector := labels.Set(map[string]string{
+		"executorType": fission.ExecutorTypeNewdeploy,
+	}).AsSelector().String()
+	deplStore, deplController := k8sCache.NewInformer(&k8sCache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = selector
+			return deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = selector
+			return deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Watch(options)
+		},
+	}, &v1beta1.Deployment{}, util.FollowerResyncPeriod, k8sCache.ResourceEventHandlerFuncs{
+		AddFunc: followDeployment,
+		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+			followDeployment(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if depl, ok := util.DeletedObject(obj).(*v1beta1.Deployment); ok {
+				deploy.unfollowDeployment(depl.ObjectMeta.Name)
+			}
+		},
+	})
+
+	util.RunControllers(stop, fnController, deplController)
+}
+
+// unfollowDeployment removes the function service of the deployment from
+// the cache.
+func (deploy *NewDeploy) unfollowDeployment(name string) {
+	for _, fsvc := range deploy.fsCache.ListByExecutor(fission.ExecutorTypeNewdeploy) {
+		if fsvc.Name == name {
+			deploy.fsCache.DeleteEntry(fsvc)
+		}
+	}
+}
+
+// unfollowFunction removes the function service of the function from the
+// cache.
+func (deploy *NewDeploy) unfollowFunction(uid types.UID) {
+	for _, fsvc := range deploy.fsCache.ListByExecutor(fission.ExecutorTypeNewdeploy) {
+		if fsvc.Function.UID == uid {
+			deploy.fsCache.DeleteEntry(fsvc)
+		}
+	}
+}
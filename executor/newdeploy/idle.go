@@ -0,0 +1,188 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package newdeploy
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	k8s_err "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/fscache"
+)
+
+type (
+	// idleFuncSvcs keeps the function services whose deployments were
+	// scaled down to zero replicas. They're not in the function service
+	// cache, so the next request for the function goes through
+	// GetFuncSvc, which scales the deployment back up.
+	idleFuncSvcs struct {
+		lock     sync.Mutex
+		fsvcs    map[types.UID]*fscache.FuncSvc
+		scalings map[types.UID]*idleScaling
+	}
+
+	// idleScaling is a scale-up of an idle function in progress, which
+	// concurrent requests for the function wait for.
+	idleScaling struct {
+		done chan struct{}
+		err  error
+	}
+)
+
+func makeIdleFuncSvcs() *idleFuncSvcs {
+	return &idleFuncSvcs{
+		fsvcs:    make(map[types.UID]*fscache.FuncSvc),
+		scalings: make(map[types.UID]*idleScaling),
+	}
+}
+
+func (idle *idleFuncSvcs) add(fsvc *fscache.FuncSvc) {
+	idle.lock.Lock()
+	defer idle.lock.Unlock()
+	idle.fsvcs[fsvc.Function.UID] = fsvc
+}
+
+func (idle *idleFuncSvcs) get(uid types.UID) (*fscache.FuncSvc, bool) {
+	idle.lock.Lock()
+	defer idle.lock.Unlock()
+	fsvc, ok := idle.fsvcs[uid]
+	return fsvc, ok
+}
+
+func (idle *idleFuncSvcs) remove(uid types.UID) {
+	idle.lock.Lock()
+	defer idle.lock.Unlock()
+	delete(idle.fsvcs, uid)
+}
+
+// scaleUp runs scale for the function, unless a scale-up of the function
+// is already in progress, in which case it waits for that one instead.
+func (idle *idleFuncSvcs) scaleUp(uid types.UID, scale func() error) error {
+	idle.lock.Lock()
+	if s, ok := idle.scalings[uid]; ok {
+		idle.lock.Unlock()
+		<-s.done
+		return s.err
+	}
+	s := &idleScaling{done: make(chan struct{})}
+	idle.scalings[uid] = s
+	idle.lock.Unlock()
+
+	s.err = scale()
+
+	idle.lock.Lock()
+	delete(idle.scalings, uid)
+	idle.lock.Unlock()
+	close(s.done)
+	return s.err
+}
+
+// ScaleDownIdle scales the deployment of a function with a MinScale of 0
+// down to zero replicas once it has been idle for minAge. The service and
+// HPA are kept; the HPA leaves deployments with zero replicas alone.
+func (deploy *NewDeploy) ScaleDownIdle(fsvc *fscache.FuncSvc, minAge time.Duration) (bool, error) {
+	c := make(chan *fnResponse)
+	deploy.requestChannel <- &fnRequest{
+		reqType:         FnScaleDown,
+		fsvc:            fsvc,
+		minAge:          minAge,
+		responseChannel: c,
+	}
+	resp := <-c
+	return resp.fSvc != nil, resp.error
+}
+
+// fnScaleDown runs in the request loop, so it doesn't race with GetFuncSvc
+// scaling the same deployment up.
+func (deploy *NewDeploy) fnScaleDown(fsvc *fscache.FuncSvc, minAge time.Duration) (*fscache.FuncSvc, error) {
+	// the function may have been called since it was listed
+	if time.Since(fsvc.Atime) < minAge {
+		return nil, nil
+	}
+
+	fn, err := deploy.fissionClient.Functions(fsvc.Function.Namespace).Get(fsvc.Function.Name)
+	if err != nil {
+		if k8s_err.IsNotFound(err) {
+			// the function's delete event cleans up
+			return nil, nil
+		}
+		return nil, err
+	}
+	if fn.Spec.InvokeStrategy.ExecutionStrategy.MinScale > 0 {
+		return nil, nil
+	}
+
+	depl, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Get(fsvc.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Take the function service out of the cache first, so no new
+	// requests are sent to it.
+	deploy.fsCache.DeleteEntry(fsvc)
+	deploy.idle.add(fsvc)
+
+	replicas := int32(0)
+	depl.Spec.Replicas = &replicas
+	err = deploy.updateDeployment(depl)
+	if err != nil {
+		deploy.idle.remove(fsvc.Function.UID)
+		_, addErr := deploy.fsCache.Add(*fsvc)
+		if addErr != nil {
+			log.Printf("Error adding function service %v back to the cache: %v", fsvc.Name, addErr)
+		}
+		return nil, err
+	}
+	deploy.concurrencyScaler.forget(fsvc.Function.UID)
+
+	log.Printf("Scaled down deployment %v of idle function %v", fsvc.Name, fsvc.Function.Name)
+	return fsvc, nil
+}
+
+// scaleUpIdle scales a deployment that was scaled down to zero back up
+// to the function's MinScale, or one replica, and waits for its pods to
+// be ready. It runs outside the request loop, which it would hold up for
+// as long as the pods take to start; concurrent requests for the same
+// function share one scale-up.
+func (deploy *NewDeploy) scaleUpIdle(fn *crd.Function, objName string) error {
+	return deploy.idle.scaleUp(fn.Metadata.UID, func() error {
+		depl, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Get(objName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		replicas := int32(fn.Spec.InvokeStrategy.ExecutionStrategy.MinScale)
+		if replicas == 0 {
+			replicas = 1
+		}
+		if depl.Spec.Replicas != nil && *depl.Spec.Replicas == 0 {
+			log.Printf("Scaling up deployment %v of idle function %v", objName, fn.Metadata.Name)
+			depl.Spec.Replicas = &replicas
+			err = deploy.updateDeployment(depl)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = deploy.waitForDeploy(depl, replicas, readyTimeout(fn, deployTimeout))
+		return err
+	})
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package newdeploy
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdleScaleUp(t *testing.T) {
+	idle := makeIdleFuncSvcs()
+	release := make(chan struct{})
+	var scalings int32
+	scale := func() error {
+		atomic.AddInt32(&scalings, 1)
+		<-release
+		return errors.New("pods not ready")
+	}
+
+	// concurrent requests for a function share its scale-up
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- idle.scaleUp("1234", scale)
+		}()
+	}
+
+	// while another function's scale-up goes ahead
+	other := make(chan error)
+	go func() {
+		other <- idle.scaleUp("5678", func() error { return nil })
+	}()
+	select {
+	case err := <-other:
+		if err != nil {
+			log.Panicf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		log.Panicf("scale-up blocked by another function's")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err == nil {
+			log.Panicf("expected the shared scale-up's error")
+		}
+	}
+	if atomic.LoadInt32(&scalings) != 1 {
+		log.Panicf("expected 1 scale-up, got %v", scalings)
+	}
+
+	// a later request scales up again
+	err := idle.scaleUp("1234", func() error { return nil })
+	if err != nil {
+		log.Panicf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package newdeploy
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	deploymentOpCreate = "create"
+	deploymentOpUpdate = "update"
+)
+
+var (
+	// create includes waiting for the deployment's pods to be ready
+	deploymentDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "fission",
+			Subsystem: "executor",
+			Name:      "newdeploy_deployment_duration_seconds",
+			Help:      "Time taken to create or update a function's deployment.",
+			Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+		},
+		[]string{"operation"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(deploymentDuration)
+}
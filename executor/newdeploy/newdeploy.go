@@ -23,11 +23,13 @@ import (
 	"log"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	k8s_err "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/pkg/api/v1"
 	apiv1 "k8s.io/client-go/pkg/api/v1"
@@ -47,6 +49,10 @@ const (
 
 const (
 	envVersion = "ENV_VERSION"
+
+	// Deployments of functions without a readiness probe must be ready
+	// within this time.
+	deployTimeout = 120 * time.Second
 )
 
 func (deploy *NewDeploy) createOrGetDeployment(fn *crd.Function, env *crd.Environment,
@@ -60,7 +66,7 @@ func (deploy *NewDeploy) createOrGetDeployment(fn *crd.Function, env *crd.Enviro
 	existingDepl, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Get(deployName, metav1.GetOptions{})
 	if err == nil {
 		if existingDepl.Status.ReadyReplicas < replicas {
-			existingDepl, err = deploy.waitForDeploy(existingDepl, replicas)
+			existingDepl, err = deploy.waitForDeploy(existingDepl, replicas, readyTimeout(fn, deployTimeout))
 		}
 		return existingDepl, err
 	}
@@ -72,13 +78,18 @@ func (deploy *NewDeploy) createOrGetDeployment(fn *crd.Function, env *crd.Enviro
 			return nil, err
 		}
 
+		start := time.Now()
 		depl, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Create(deployment)
 		if err != nil {
 			log.Printf("Error while creating deployment: %v", err)
 			return nil, err
 		}
 
-		return deploy.waitForDeploy(depl, replicas)
+		depl, err = deploy.waitForDeploy(depl, replicas, readyTimeout(fn, deployTimeout))
+		if err == nil {
+			deploymentDuration.WithLabelValues(deploymentOpCreate).Observe(time.Since(start).Seconds())
+		}
+		return depl, err
 	}
 
 	return nil, err
@@ -91,7 +102,11 @@ func (deploy *NewDeploy) getDeployment(fn *crd.Function) (*v1beta1.Deployment, e
 }
 
 func (deploy *NewDeploy) updateDeployment(deployment *v1beta1.Deployment) error {
+	start := time.Now()
 	_, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Update(deployment)
+	if err == nil {
+		deploymentDuration.WithLabelValues(deploymentOpUpdate).Observe(time.Since(start).Seconds())
+	}
 	return err
 }
 
@@ -111,6 +126,11 @@ func (deploy *NewDeploy) deleteDeployment(ns string, name string) error {
 func (deploy *NewDeploy) getDeploymentSpec(fn *crd.Function, env *crd.Environment,
 	deployName string, deployLabels map[string]string) (*v1beta1.Deployment, error) {
 
+	err := fn.Spec.ValidateEnvSupport(&env.Spec)
+	if err != nil {
+		return nil, err
+	}
+
 	replicas := int32(fn.Spec.InvokeStrategy.ExecutionStrategy.MinScale)
 	if replicas == 0 {
 		replicas = 1
@@ -130,6 +150,7 @@ func (deploy *NewDeploy) getDeploymentSpec(fn *crd.Function, env *crd.Environmen
 		Filename:   targetFilename,
 		Secrets:    fn.Spec.Secrets,
 		ConfigMaps: fn.Spec.ConfigMaps,
+		Env:        fn.Spec.Env,
 	}
 
 	loadReq := fission.FunctionLoadRequest{
@@ -158,6 +179,13 @@ func (deploy *NewDeploy) getDeploymentSpec(fn *crd.Function, env *crd.Environmen
 		podAnnotation["sidecar.istio.io/inject"] = "false"
 	}
 	resources := deploy.getResources(env, fn)
+	var readinessProbe, livenessProbe *apiv1.Probe
+	if fn.Spec.Probes != nil {
+		readinessProbe = functionProbe(fn.Spec.Probes.Readiness)
+		livenessProbe = functionProbe(fn.Spec.Probes.Liveness)
+	}
+	maxUnavailable := intstr.FromInt(0)
+	maxSurge := intstr.FromString("25%")
 
 	deployment := &v1beta1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -169,6 +197,16 @@ func (deploy *NewDeploy) getDeploymentSpec(fn *crd.Function, env *crd.Environmen
 			Selector: &metav1.LabelSelector{
 				MatchLabels: deployLabels,
 			},
+			// keep the pods of the previous revision until the new
+			// ones are ready, so a bad update doesn't take the
+			// function down
+			Strategy: v1beta1.DeploymentStrategy{
+				Type: v1beta1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &v1beta1.RollingUpdateDeployment{
+					MaxUnavailable: &maxUnavailable,
+					MaxSurge:       &maxSurge,
+				},
+			},
 			Template: apiv1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels:      deployLabels,
@@ -225,7 +263,10 @@ func (deploy *NewDeploy) getDeploymentSpec(fn *crd.Function, env *crd.Environmen
 									},
 								},
 							},
-							Resources: resources,
+							Resources:      resources,
+							Env:            literalEnv(fn.Spec.Env),
+							ReadinessProbe: readinessProbe,
+							LivenessProbe:  livenessProbe,
 						}, env.Spec.Runtime.Container),
 						{
 							Name:                   "fetcher",
@@ -298,16 +339,36 @@ func (deploy *NewDeploy) getDeploymentSpec(fn *crd.Function, env *crd.Environmen
 							},
 						},
 					},
-					ServiceAccountName:            "fission-fetcher",
+					ServiceAccountName:            util.FetcherServiceAccount,
 					TerminationGracePeriodSeconds: &gracePeriodSeconds,
 				},
 			},
 		},
 	}
+	fission.ApplyScheduling(&deployment.Spec.Template, fn.Spec.Scheduling, env.Spec.Scheduling)
+	fission.ApplyPodSecurity(&deployment.Spec.Template, fn.Metadata.Name, fn.Spec.Security, env.Spec.Security)
+	err = util.MountFetcherToken(deploy.kubernetesClient, deploy.namespace, &deployment.Spec.Template.Spec)
+	if err != nil {
+		return nil, err
+	}
 
 	return deployment, nil
 }
 
+// literalEnv returns the environment variables with literal values. The
+// others reference secrets and configmaps in the function's namespace,
+// which the pod can't read; the fetcher passes their values to the
+// environment server instead.
+func literalEnv(vars []apiv1.EnvVar) []apiv1.EnvVar {
+	var literal []apiv1.EnvVar
+	for _, v := range vars {
+		if v.ValueFrom == nil {
+			literal = append(literal, v)
+		}
+	}
+	return literal
+}
+
 // getResources overrides only the resources which are overridden at function level otherwise
 // default to resources specified at environment level
 func (deploy *NewDeploy) getResources(env *crd.Environment, fn *crd.Function) v1.ResourceRequirements {
@@ -455,8 +516,105 @@ func (deploy *NewDeploy) deleteSvc(ns string, name string) error {
 	return nil
 }
 
-func (deploy *NewDeploy) waitForDeploy(depl *v1beta1.Deployment, replicas int32) (*v1beta1.Deployment, error) {
-	for i := 0; i < 120; i++ {
+// recordSpecialization records the outcome of starting the function's
+// deployment on the function. On failure, the output of the fetcher of a
+// pod that didn't become ready tells why.
+func (deploy *NewDeploy) recordSpecialization(fn *crd.Function, err error) {
+	status := &fission.SpecializationStatus{
+		Succeeded:    err == nil,
+		ExecutorType: fission.ExecutorTypeNewdeploy,
+	}
+	if err != nil {
+		status.Error = err.Error()
+		podList, listErr := deploy.kubernetesClient.CoreV1().Pods(deploy.namespace).List(metav1.ListOptions{
+			LabelSelector: labels.Set(map[string]string{
+				"functionUid":  string(fn.Metadata.UID),
+				"executorType": fission.ExecutorTypeNewdeploy,
+			}).AsSelector().String(),
+		})
+		if listErr != nil {
+			log.Printf("Error listing pods of function %v: %v", fn.Metadata.Name, listErr)
+		} else {
+			for i := range podList.Items {
+				pod := &podList.Items[i]
+				if fission.IsReadyPod(pod) {
+					continue
+				}
+				status.PodName = pod.ObjectMeta.Name
+				status.FetcherResponse = deploy.fetcherOutput(pod)
+				break
+			}
+		}
+	}
+	go util.RecordSpecialization(deploy.kubernetesClient, fn, status)
+}
+
+// fetcherOutput returns the last lines logged by the fetcher of pod. The
+// fetcher keeps running after a failed specialization, so its logs, not
+// its termination message, tell why.
+func (deploy *NewDeploy) fetcherOutput(pod *apiv1.Pod) string {
+	tailLines := int64(10)
+	out, err := deploy.kubernetesClient.CoreV1().Pods(pod.ObjectMeta.Namespace).GetLogs(pod.ObjectMeta.Name, &apiv1.PodLogOptions{
+		Container: "fetcher",
+		TailLines: &tailLines,
+	}).Do().Raw()
+	if err != nil {
+		log.Printf("Error getting fetcher logs of pod %v: %v", pod.ObjectMeta.Name, err)
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// functionProbe returns the probe of the function container set by the
+// function, if any.
+func functionProbe(probe *fission.HTTPProbe) *apiv1.Probe {
+	if probe == nil {
+		return nil
+	}
+	path := probe.Path
+	if len(path) == 0 {
+		path = "/"
+	}
+	return &apiv1.Probe{
+		InitialDelaySeconds: probe.InitialDelaySeconds,
+		PeriodSeconds:       probe.PeriodSeconds,
+		TimeoutSeconds:      probe.TimeoutSeconds,
+		SuccessThreshold:    probe.SuccessThreshold,
+		FailureThreshold:    probe.FailureThreshold,
+		Handler: apiv1.Handler{
+			HTTPGet: &apiv1.HTTPGetAction{
+				Path: path,
+				Port: intstr.FromInt(8888),
+			},
+		},
+	}
+}
+
+// readyTimeout returns how long the function's pods may take to become
+// ready: min, or longer if the function's readiness probe allows for it.
+func readyTimeout(fn *crd.Function, min time.Duration) time.Duration {
+	if fn.Spec.Probes == nil || fn.Spec.Probes.Readiness == nil {
+		return min
+	}
+	probe := fn.Spec.Probes.Readiness
+
+	// Kubernetes defaults
+	period, failures := probe.PeriodSeconds, probe.FailureThreshold
+	if period == 0 {
+		period = 10
+	}
+	if failures == 0 {
+		failures = 3
+	}
+	timeout := time.Duration(probe.InitialDelaySeconds+period*failures) * time.Second
+	if timeout < min {
+		return min
+	}
+	return timeout
+}
+
+func (deploy *NewDeploy) waitForDeploy(depl *v1beta1.Deployment, replicas int32, timeout time.Duration) (*v1beta1.Deployment, error) {
+	for i := 0; i < int(timeout/time.Second); i++ {
 		latestDepl, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Get(depl.Name, metav1.GetOptions{})
 		if err != nil {
 			return nil, err
@@ -21,11 +21,13 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	k8s_err "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
@@ -36,7 +38,9 @@ import (
 
 	"github.com/fission/fission"
 	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/executortype"
 	"github.com/fission/fission/executor/fscache"
+	"github.com/fission/fission/executor/util"
 )
 
 type (
@@ -59,6 +63,10 @@ type (
 		fsCache        *fscache.FunctionServiceCache // cache funcSvc's by function, address and podname
 		requestChannel chan *fnRequest
 
+		concurrencyScaler *concurrencyScaler
+		idle              *idleFuncSvcs
+		rollbacks         *rollbackSet
+
 		functions      []crd.Function
 		funcStore      k8sCache.Store
 		funcController k8sCache.Controller
@@ -67,6 +75,8 @@ type (
 	fnRequest struct {
 		reqType         requestType
 		fn              *crd.Function
+		fsvc            *fscache.FuncSvc // for FnScaleDown
+		minAge          time.Duration    // for FnScaleDown
 		responseChannel chan *fnResponse
 	}
 
@@ -80,8 +90,16 @@ const (
 	FnCreate requestType = iota
 	FnUpdate
 	FnDelete
+	FnScaleDown
 )
 
+func init() {
+	executortype.Register(fission.ExecutorTypeNewdeploy, func(opts *executortype.Options) (executortype.ExecutorType, error) {
+		return MakeNewDeploy(opts.FissionClient, opts.KubernetesClient, opts.CrdClient,
+			opts.FunctionNamespace, opts.FsCache, opts.InstanceID), nil
+	})
+}
+
 func MakeNewDeploy(
 	fissionClient *crd.FissionClient,
 	kubernetesClient *kubernetes.Clientset,
@@ -127,7 +145,10 @@ func MakeNewDeploy(
 		sharedCfgMapPath:       "/configs",
 		useIstio:               enableIstio,
 
-		requestChannel: make(chan *fnRequest),
+		requestChannel:    make(chan *fnRequest),
+		concurrencyScaler: makeConcurrencyScaler(),
+		idle:              makeIdleFuncSvcs(),
+		rollbacks:         makeRollbackSet(),
 	}
 
 	if nd.crdClient != nil {
@@ -141,6 +162,7 @@ func MakeNewDeploy(
 
 func (deploy *NewDeploy) Run(ctx context.Context) {
 	go deploy.funcController.Run(ctx.Done())
+	go deploy.runConcurrencyScaler(ctx)
 }
 
 func (deploy *NewDeploy) initFuncController() (k8sCache.Store, k8sCache.Controller) {
@@ -182,12 +204,21 @@ func (deploy *NewDeploy) service() {
 				fSvc:  nil,
 			}
 			continue
+		case FnScaleDown:
+			fsvc, err := deploy.fnScaleDown(req.fsvc, req.minAge)
+			req.responseChannel <- &fnResponse{
+				error: err,
+				fSvc:  fsvc,
+			}
+			continue
 			// Update needs two inputs and will be called directly by controller
 		}
 	}
 }
 
-func (deploy *NewDeploy) GetFuncSvc(metadata *metav1.ObjectMeta) (*fscache.FuncSvc, error) {
+// GetFuncSvc returns the function's service, creating the deployment,
+// service and HPA for the function if they don't exist.
+func (deploy *NewDeploy) GetFuncSvc(metadata *metav1.ObjectMeta, env *crd.Environment) (*fscache.FuncSvc, error) {
 	c := make(chan *fnResponse)
 	fn, err := deploy.fissionClient.Functions(metadata.Namespace).Get(metadata.Name)
 	if err != nil {
@@ -208,6 +239,16 @@ func (deploy *NewDeploy) GetFuncSvc(metadata *metav1.ObjectMeta) (*fscache.FuncS
 		return nil, err
 	}
 
+	// The deployment of an idle function was scaled down to zero. It's
+	// scaled up here rather than in fnCreate, so the request loop
+	// doesn't wait for its pods.
+	if idleFsvc, ok := deploy.idle.get(fn.Metadata.UID); ok {
+		err = deploy.scaleUpIdle(fn, idleFsvc.Name)
+		if err != nil && !k8s_err.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
 	deploy.requestChannel <- &fnRequest{
 		fn:              fn,
 		reqType:         FnCreate,
@@ -302,14 +343,10 @@ func (deploy *NewDeploy) fnCreate(fn *crd.Function) (*fscache.FuncSvc, error) {
 	depl, err := deploy.createOrGetDeployment(fn, env, objName, deployLabels)
 	if err != nil {
 		log.Printf("Error creating the deployment %v: %v", objName, err)
+		deploy.recordSpecialization(fn, err)
 		return fsvc, err
 	}
 
-	hpa, err := deploy.createOrGetHpa(objName, &fn.Spec.InvokeStrategy.ExecutionStrategy, depl)
-	if err != nil {
-		return fsvc, errors.Wrap(err, fmt.Sprintf("error creating the HPA %v:", objName))
-	}
-
 	kubeObjRefs := []api.ObjectReference{
 		{
 			//obj.TypeMeta.Kind does not work hence this, needs investigationa and a fix
@@ -328,14 +365,23 @@ func (deploy *NewDeploy) fnCreate(fn *crd.Function) (*fscache.FuncSvc, error) {
 			ResourceVersion: svc.ObjectMeta.ResourceVersion,
 			UID:             svc.ObjectMeta.UID,
 		},
-		{
+	}
+
+	// Functions scaled on concurrency are resized by the concurrency
+	// scaler instead of an HPA.
+	if fn.Spec.InvokeStrategy.ExecutionStrategy.TargetConcurrency == 0 {
+		hpa, err := deploy.createOrGetHpa(objName, &fn.Spec.InvokeStrategy.ExecutionStrategy, depl)
+		if err != nil {
+			return fsvc, errors.Wrap(err, fmt.Sprintf("error creating the HPA %v:", objName))
+		}
+		kubeObjRefs = append(kubeObjRefs, api.ObjectReference{
 			Kind:            "horizontalpodautoscaler",
 			Name:            hpa.ObjectMeta.Name,
 			APIVersion:      hpa.TypeMeta.APIVersion,
 			Namespace:       hpa.ObjectMeta.Namespace,
 			ResourceVersion: hpa.ObjectMeta.ResourceVersion,
 			UID:             hpa.ObjectMeta.UID,
-		},
+		})
 	}
 
 	fsvc = &fscache.FuncSvc{
@@ -344,7 +390,7 @@ func (deploy *NewDeploy) fnCreate(fn *crd.Function) (*fscache.FuncSvc, error) {
 		Environment:       env,
 		Address:           svcAddress,
 		KubernetesObjects: kubeObjRefs,
-		Executor:          fscache.NEWDEPLOY,
+		Executor:          fission.ExecutorTypeNewdeploy,
 	}
 
 	_, err = deploy.fsCache.Add(*fsvc)
@@ -352,6 +398,8 @@ func (deploy *NewDeploy) fnCreate(fn *crd.Function) (*fscache.FuncSvc, error) {
 		log.Printf("Error adding the function to cache: %v", err)
 		return fsvc, err
 	}
+	deploy.idle.remove(fn.Metadata.UID)
+	deploy.recordSpecialization(fn, nil)
 	return fsvc, nil
 }
 
@@ -369,7 +417,7 @@ func (deploy *NewDeploy) fnUpdate(oldFn *crd.Function, newFn *crd.Function) {
 
 	deployChanged := false
 
-	if oldFn.Spec.InvokeStrategy != newFn.Spec.InvokeStrategy {
+	if !reflect.DeepEqual(oldFn.Spec.InvokeStrategy, newFn.Spec.InvokeStrategy) {
 
 		// Executor type is no longer New Deployment
 		if newFn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType != fission.ExecutorTypeNewdeploy &&
@@ -386,48 +434,66 @@ func (deploy *NewDeploy) fnUpdate(oldFn *crd.Function, newFn *crd.Function) {
 			log.Printf("function type changed to new deployment, creating resources: %v", newFn)
 			_, err := deploy.fnCreate(newFn)
 			if err != nil {
-				updateStatus(oldFn, err, "error changing the function's type to newdeploy")
+				deploy.updateStatus(oldFn, err, "error changing the function's type to newdeploy")
 			}
 			return
 		}
 
-		hpa, err := deploy.getHpa(newFn)
-		if err != nil {
-			updateStatus(oldFn, err, "error getting HPA while updating function")
-			return
-		}
+		oldConcurrency := oldFn.Spec.InvokeStrategy.ExecutionStrategy.TargetConcurrency
+		newConcurrency := newFn.Spec.InvokeStrategy.ExecutionStrategy.TargetConcurrency
+		if oldConcurrency > 0 || newConcurrency > 0 {
+			// switch between the HPA and the concurrency scaler
+			err := deploy.updateScalingMode(oldFn, newFn)
+			if err != nil {
+				deploy.updateStatus(oldFn, err, "error changing the scaling mode while updating function")
+				return
+			}
+			if newFn.Spec.InvokeStrategy.ExecutionStrategy.MinScale != oldFn.Spec.InvokeStrategy.ExecutionStrategy.MinScale {
+				deployChanged = true
+			}
+		} else {
+			hpa, err := deploy.getHpa(newFn)
+			if err != nil {
+				deploy.updateStatus(oldFn, err, "error getting HPA while updating function")
+				return
+			}
 
-		hpaChanged := false
+			hpaChanged := false
 
-		if newFn.Spec.InvokeStrategy.ExecutionStrategy.MinScale != oldFn.Spec.InvokeStrategy.ExecutionStrategy.MinScale {
-			replicas := int32(newFn.Spec.InvokeStrategy.ExecutionStrategy.MinScale)
-			hpa.Spec.MinReplicas = &replicas
-			deployChanged = true
-			hpaChanged = true
-		}
+			if newFn.Spec.InvokeStrategy.ExecutionStrategy.MinScale != oldFn.Spec.InvokeStrategy.ExecutionStrategy.MinScale {
+				replicas := int32(newFn.Spec.InvokeStrategy.ExecutionStrategy.MinScale)
+				hpa.Spec.MinReplicas = &replicas
+				deployChanged = true
+				hpaChanged = true
+			}
 
-		if newFn.Spec.InvokeStrategy.ExecutionStrategy.MaxScale != oldFn.Spec.InvokeStrategy.ExecutionStrategy.MaxScale {
-			hpa.Spec.MaxReplicas = int32(newFn.Spec.InvokeStrategy.ExecutionStrategy.MaxScale)
-			hpaChanged = true
-		}
+			if newFn.Spec.InvokeStrategy.ExecutionStrategy.MaxScale != oldFn.Spec.InvokeStrategy.ExecutionStrategy.MaxScale {
+				hpa.Spec.MaxReplicas = int32(newFn.Spec.InvokeStrategy.ExecutionStrategy.MaxScale)
+				hpaChanged = true
+			}
 
-		if newFn.Spec.InvokeStrategy.ExecutionStrategy.TargetCPUPercent != oldFn.Spec.InvokeStrategy.ExecutionStrategy.TargetCPUPercent {
-			targetCpupercent := int32(newFn.Spec.InvokeStrategy.ExecutionStrategy.TargetCPUPercent)
-			hpa.Spec.TargetCPUUtilizationPercentage = &targetCpupercent
-			hpaChanged = true
-		}
+			if newFn.Spec.InvokeStrategy.ExecutionStrategy.TargetCPUPercent != oldFn.Spec.InvokeStrategy.ExecutionStrategy.TargetCPUPercent {
+				targetCpupercent := int32(newFn.Spec.InvokeStrategy.ExecutionStrategy.TargetCPUPercent)
+				hpa.Spec.TargetCPUUtilizationPercentage = &targetCpupercent
+				hpaChanged = true
+			}
 
-		if hpaChanged {
-			err := deploy.updateHpa(hpa)
-			if err != nil {
-				updateStatus(oldFn, err, "error updating HPA while updating function")
-				return
+			if hpaChanged {
+				err := deploy.updateHpa(hpa)
+				if err != nil {
+					deploy.updateStatus(oldFn, err, "error updating HPA while updating function")
+					return
+				}
 			}
 		}
 	}
 
 	if oldFn.Spec.Environment != newFn.Spec.Environment ||
-		oldFn.Spec.Package.PackageRef != newFn.Spec.Package.PackageRef {
+		oldFn.Spec.Package.PackageRef != newFn.Spec.Package.PackageRef ||
+		!reflect.DeepEqual(oldFn.Spec.Scheduling, newFn.Spec.Scheduling) ||
+		!reflect.DeepEqual(oldFn.Spec.Security, newFn.Spec.Security) ||
+		!reflect.DeepEqual(oldFn.Spec.Probes, newFn.Spec.Probes) ||
+		!reflect.DeepEqual(oldFn.Spec.Env, newFn.Spec.Env) {
 		deployChanged = true
 	}
 
@@ -457,37 +523,58 @@ func (deploy *NewDeploy) fnUpdate(oldFn *crd.Function, newFn *crd.Function) {
 		env, err := deploy.fissionClient.Environments(newFn.Spec.Environment.Namespace).
 			Get(newFn.Spec.Environment.Name)
 		if err != nil {
-			updateStatus(oldFn, err, "failed to get environment while updating function")
+			deploy.updateStatus(oldFn, err, "failed to get environment while updating function")
 			return
 		}
 		deployName := deploy.getObjName(oldFn)
 		deployLabels := deploy.getDeployLabels(oldFn, env)
+		// keep the selector of deployments adopted from an earlier
+		// executor instance
+		existingDepl, err := deploy.getDeployment(oldFn)
+		if err == nil && existingDepl.Spec.Selector != nil {
+			deployLabels = existingDepl.Spec.Selector.MatchLabels
+		}
 		log.Printf("updating deployment due to function update")
 		newDeployment, err := deploy.getDeploymentSpec(newFn, env, deployName, deployLabels)
 		if err != nil {
-			updateStatus(oldFn, err, "failed to get new deployment spec while updating function")
+			deploy.updateStatus(oldFn, err, "failed to get new deployment spec while updating function")
 			return
 		}
+		// an idle function stays scaled down, unless it must now
+		// always run
+		if _, ok := deploy.idle.get(oldFn.Metadata.UID); ok &&
+			newFn.Spec.InvokeStrategy.ExecutionStrategy.MinScale <= 0 {
+			replicas := int32(0)
+			newDeployment.Spec.Replicas = &replicas
+		}
 		err = deploy.updateDeployment(newDeployment)
 		if err != nil {
-			updateStatus(oldFn, err, "failed to update deployment while updating function")
+			deploy.updateStatus(oldFn, err, "failed to update deployment while updating function")
 			return
 		}
+		go deploy.watchRollout(oldFn, newFn, deployName)
 	}
 }
 
 func (deploy *NewDeploy) fnDelete(fn *crd.Function) (*fscache.FuncSvc, error) {
-
-	var delError error
-
 	fsvc, err := deploy.fsCache.GetByFunction(&fn.Metadata)
 	if err != nil {
+		if idleFsvc, ok := deploy.idle.get(fn.Metadata.UID); ok {
+			return nil, deploy.DeleteFuncSvc(idleFsvc)
+		}
 		log.Printf("fsvc not fonud in cache: %v", fn.Metadata)
-		delError = err
 		return nil, err
 	}
+	return nil, deploy.DeleteFuncSvc(fsvc)
+}
+
+// DeleteFuncSvc removes the function service from the cache, and deletes
+// its deployment, service and HPA.
+func (deploy *NewDeploy) DeleteFuncSvc(fsvc *fscache.FuncSvc) error {
+
+	var delError error
 
-	_, err = deploy.fsCache.DeleteOld(fsvc, time.Second*0)
+	_, err := deploy.fsCache.DeleteOld(fsvc, time.Second*0)
 	if err != nil {
 		log.Printf("Error deleting the function from cache: %v", fsvc)
 		delError = err
@@ -506,19 +593,44 @@ func (deploy *NewDeploy) fnDelete(fn *crd.Function) (*fscache.FuncSvc, error) {
 		delError = err
 	}
 
+	// functions scaled on concurrency have no HPA
 	err = deploy.deleteHpa(deploy.namespace, objName)
-	if err != nil {
+	if err != nil && !k8s_err.IsNotFound(err) {
 		log.Printf("Error deleting the HPA: %v", objName)
 		delError = err
 	}
 
-	if delError != nil {
-		return nil, delError
+	deploy.concurrencyScaler.forget(fsvc.Function.UID)
+	deploy.idle.remove(fsvc.Function.UID)
+
+	return delError
+}
+
+// IsValid checks that the function service's service exists and that its
+// deployment wasn't scaled down while idle; requests to it would wait for
+// pods that aren't coming.
+func (deploy *NewDeploy) IsValid(fsvc *fscache.FuncSvc) bool {
+	depl, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Get(fsvc.Name, metav1.GetOptions{})
+	if err != nil || isScaledToZero(depl) {
+		return false
 	}
-	return nil, nil
+	return deploy.IsValidService(fsvc.Address)
+}
+
+func (deploy *NewDeploy) ListFuncSvcs() ([]*fscache.FuncSvc, error) {
+	return deploy.fsCache.ListByExecutor(fission.ExecutorTypeNewdeploy), nil
 }
 
 func (deploy *NewDeploy) getObjName(fn *crd.Function) string {
+	// Objects adopted from an earlier executor instance keep the name
+	// that instance gave them.
+	fsvc, err := deploy.fsCache.GetByFunctionUID(fn.Metadata.UID)
+	if err == nil {
+		return fsvc.Name
+	}
+	if fsvc, ok := deploy.idle.get(fn.Metadata.UID); ok {
+		return fsvc.Name
+	}
 	return fmt.Sprintf("%v-%v",
 		fn.Metadata.Name,
 		deploy.instanceID)
@@ -549,10 +661,12 @@ func (deploy *NewDeploy) updateKubeObjRefRV(fsvc *fscache.FuncSvc, objKind strin
 	return errors.New(fmt.Sprintf("error finding kubernetes object reference with kind: %v", objKind))
 }
 
-// updateStatus is a function which updates status of update.
-// Current implementation only logs messages, in future it will update function status
-func updateStatus(fn *crd.Function, err error, message string) {
-	log.Printf(message, err)
+// updateStatus logs an error updating the objects of the function, and
+// records it as an event on the function.
+func (deploy *NewDeploy) updateStatus(fn *crd.Function, err error, message string) {
+	log.Printf("%v: %v", message, err)
+	util.RecordFunctionEvent(deploy.kubernetesClient, fn, apiv1.EventTypeWarning, "UpdateFailed",
+		fmt.Sprintf("%v: %v", message, err))
 }
 
 // IsValidService does a get on the service address to ensure it's a valid service. returns true if it is, else false.
@@ -0,0 +1,257 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package newdeploy
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	k8s_err "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/util"
+)
+
+const (
+	// A function update must be rolled out within this time, or it's
+	// rolled back.
+	rolloutDeadline     = 5 * time.Minute
+	rolloutPollInterval = 2 * time.Second
+
+	// New pods whose containers restarted this many times failed to
+	// specialize; the fetcher exits when it can't load the function.
+	rolloutMaxRestarts = 3
+
+	revisionAnnotation = "deployment.kubernetes.io/revision"
+)
+
+// podFailure checks a pod of a rollout for containers that won't become
+// ready, and returns the reason.
+func podFailure(pod *apiv1.Pod) (string, bool) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting != nil {
+			switch status.State.Waiting.Reason {
+			case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerConfigError":
+				return fmt.Sprintf("container %v of pod %v: %v", status.Name, pod.ObjectMeta.Name, status.State.Waiting.Reason), true
+			}
+		}
+		if status.RestartCount >= rolloutMaxRestarts {
+			return fmt.Sprintf("container %v of pod %v restarted %v times", status.Name, pod.ObjectMeta.Name, status.RestartCount), true
+		}
+	}
+	return "", false
+}
+
+// rolloutDone checks whether all replicas of the deployment run its
+// latest spec and are available.
+func rolloutDone(depl *v1beta1.Deployment) bool {
+	if depl.Status.ObservedGeneration < depl.ObjectMeta.Generation {
+		return false
+	}
+	replicas := int32(1)
+	if depl.Spec.Replicas != nil {
+		replicas = *depl.Spec.Replicas
+	}
+	return depl.Status.UpdatedReplicas >= replicas &&
+		depl.Status.AvailableReplicas >= replicas &&
+		depl.Status.Replicas == depl.Status.UpdatedReplicas
+}
+
+// newReplicaSetPods returns the pods of the deployment's latest revision.
+func (deploy *NewDeploy) newReplicaSetPods(depl *v1beta1.Deployment) ([]apiv1.Pod, error) {
+	selector := labels.Set(depl.Spec.Selector.MatchLabels).AsSelector().String()
+	rsList, err := deploy.kubernetesClient.ExtensionsV1beta1().ReplicaSets(deploy.namespace).List(metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	revision := depl.ObjectMeta.Annotations[revisionAnnotation]
+	for _, rs := range rsList.Items {
+		if rs.ObjectMeta.Annotations[revisionAnnotation] != revision {
+			continue
+		}
+		podList, err := deploy.kubernetesClient.CoreV1().Pods(deploy.namespace).List(metav1.ListOptions{
+			LabelSelector: labels.Set(rs.Spec.Selector.MatchLabels).AsSelector().String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return podList.Items, nil
+	}
+	return nil, nil
+}
+
+// waitForRollout waits for the deployment's latest revision to become
+// available, and fails early if its pods can't start.
+func (deploy *NewDeploy) waitForRollout(name string, deadline time.Duration) error {
+	timeout := time.After(deadline)
+	for {
+		depl, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if rolloutDone(depl) {
+			return nil
+		}
+
+		pods, err := deploy.newReplicaSetPods(depl)
+		if err != nil {
+			log.Printf("Error listing pods of deployment %v: %v", name, err)
+		}
+		for i := range pods {
+			if reason, failed := podFailure(&pods[i]); failed {
+				return fmt.Errorf("rollout of deployment %v failed: %v", name, reason)
+			}
+		}
+
+		select {
+		case <-timeout:
+			return fmt.Errorf("rollout of deployment %v didn't finish within %v", name, deadline)
+		case <-time.After(rolloutPollInterval):
+		}
+	}
+}
+
+// watchRollout waits for the deployment update caused by a function
+// update. If the new pods don't become ready, the function is rolled back
+// to its previous package, if it was moved to a different one. The outcome
+// is recorded as an event on the function; the function itself is only
+// updated to roll it back.
+func (deploy *NewDeploy) watchRollout(oldFn *crd.Function, newFn *crd.Function, deployName string) {
+	rolloutErr := deploy.waitForRollout(deployName, readyTimeout(newFn, rolloutDeadline))
+
+	// A rollback is an update too; it's never rolled back itself.
+	isRollback := deploy.rollbacks.take(newFn.Metadata.UID, newFn.Spec.Package.PackageRef)
+
+	if rolloutErr == nil {
+		log.Printf("Rolled out update of function %v", newFn.Metadata.Name)
+		if !isRollback {
+			util.RecordFunctionEvent(deploy.kubernetesClient, newFn, apiv1.EventTypeNormal, "RolloutSucceeded",
+				fmt.Sprintf("Rolled out package %v", newFn.Spec.Package.PackageRef.Name))
+		}
+		return
+	}
+
+	log.Printf("Error rolling out update of function %v: %v", newFn.Metadata.Name, rolloutErr)
+	pkgRef, reason := rollbackPackage(oldFn, newFn)
+	if isRollback || pkgRef == nil {
+		// nothing to roll back to; the pods of the previous revision
+		// keep serving the function
+		message := rolloutErr.Error()
+		if !isRollback {
+			message = fmt.Sprintf("%v; not rolled back: %v", message, reason)
+		}
+		util.RecordFunctionEvent(deploy.kubernetesClient, newFn, apiv1.EventTypeWarning, "RolloutFailed", message)
+		return
+	}
+
+	log.Printf("Rolling back function %v to package %v", newFn.Metadata.Name, pkgRef.Name)
+	if deploy.rollBack(newFn, *pkgRef) {
+		util.RecordFunctionEvent(deploy.kubernetesClient, newFn, apiv1.EventTypeWarning, "RolledBack",
+			fmt.Sprintf("Rolled back from package %v to %v: %v", newFn.Spec.Package.PackageRef.Name, pkgRef.Name, rolloutErr))
+	}
+}
+
+// rollbackPackage returns the package a function can be rolled back to
+// after a failed update, or why there's none. Packages are loaded by name,
+// so a package updated in place (as with "fission fn update --code") has
+// lost its previous contents, and going back to it would load the failed
+// code again.
+func rollbackPackage(oldFn *crd.Function, newFn *crd.Function) (*fission.PackageRef, string) {
+	oldRef := oldFn.Spec.Package.PackageRef
+	newRef := newFn.Spec.Package.PackageRef
+	if oldRef == newRef {
+		return nil, "the package didn't change"
+	}
+	if oldRef.Namespace == newRef.Namespace && oldRef.Name == newRef.Name {
+		return nil, fmt.Sprintf("package %v was updated in place", newRef.Name)
+	}
+	return &oldRef, ""
+}
+
+// rollBack moves the latest version of the function back to the given
+// package, unless the function was updated to another package since the
+// rollout started. It returns whether the function was rolled back.
+func (deploy *NewDeploy) rollBack(newFn *crd.Function, pkgRef fission.PackageRef) bool {
+	// The update starts another rollout, which must know it's a rollback.
+	deploy.rollbacks.add(newFn.Metadata.UID, pkgRef)
+	for i := 0; i < 3; i++ {
+		fn, err := deploy.fissionClient.Functions(newFn.Metadata.Namespace).Get(newFn.Metadata.Name)
+		if err != nil {
+			log.Printf("Error getting function %v to roll it back: %v", newFn.Metadata.Name, err)
+			break
+		}
+		if fn.Spec.Package.PackageRef != newFn.Spec.Package.PackageRef {
+			// superseded by a later update
+			break
+		}
+		fn.Spec.Package.PackageRef = pkgRef
+		_, err = deploy.fissionClient.Functions(fn.Metadata.Namespace).Update(fn)
+		if err == nil {
+			return true
+		}
+		if !k8s_err.IsConflict(err) {
+			log.Printf("Error rolling back function %v: %v", newFn.Metadata.Name, err)
+			break
+		}
+	}
+	deploy.rollbacks.take(newFn.Metadata.UID, pkgRef)
+	return false
+}
+
+type (
+	// rollbackSet keeps the packages functions are being rolled back
+	// to, so that the rollouts of rollbacks can be told apart from those
+	// of updates.
+	rollbackSet struct {
+		lock     sync.Mutex
+		packages map[types.UID]fission.PackageRef
+	}
+)
+
+func makeRollbackSet() *rollbackSet {
+	return &rollbackSet{
+		packages: make(map[types.UID]fission.PackageRef),
+	}
+}
+
+func (rs *rollbackSet) add(uid types.UID, pkgRef fission.PackageRef) {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	rs.packages[uid] = pkgRef
+}
+
+// take forgets the rollback of the function, and returns whether it was
+// to the given package. The next rollout of a function after a rollback
+// is either the rollback's, or that of an update superseding it.
+func (rs *rollbackSet) take(uid types.UID, pkgRef fission.PackageRef) bool {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	rolledBackTo, ok := rs.packages[uid]
+	delete(rs.packages, uid)
+	return ok && rolledBackTo == pkgRef
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package newdeploy
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+)
+
+func TestRolloutDone(t *testing.T) {
+	replicas := int32(2)
+	depl := &v1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Generation: 2},
+		Spec:       v1beta1.DeploymentSpec{Replicas: &replicas},
+		Status: v1beta1.DeploymentStatus{
+			ObservedGeneration: 1,
+			Replicas:           2,
+			UpdatedReplicas:    2,
+			AvailableReplicas:  2,
+		},
+	}
+	if rolloutDone(depl) {
+		log.Panicf("rollout done before the deployment controller saw the update")
+	}
+
+	depl.Status.ObservedGeneration = 2
+	depl.Status.Replicas = 3
+	depl.Status.UpdatedReplicas = 1
+	if rolloutDone(depl) {
+		log.Panicf("rollout done with a pod of the old revision left")
+	}
+
+	depl.Status.Replicas = 2
+	depl.Status.UpdatedReplicas = 2
+	if !rolloutDone(depl) {
+		log.Panicf("rollout not done")
+	}
+}
+
+func TestPodFailure(t *testing.T) {
+	pod := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+		Status: apiv1.PodStatus{
+			ContainerStatuses: []apiv1.ContainerStatus{
+				{Name: "fetcher", RestartCount: 1},
+			},
+		},
+	}
+	if _, failed := podFailure(pod); failed {
+		log.Panicf("pod failed after a single restart")
+	}
+
+	pod.Status.ContainerStatuses[0].RestartCount = rolloutMaxRestarts
+	if _, failed := podFailure(pod); !failed {
+		log.Panicf("crashing pod didn't fail")
+	}
+
+	pod.Status.ContainerStatuses[0].RestartCount = 0
+	pod.Status.ContainerStatuses[0].State.Waiting = &apiv1.ContainerStateWaiting{Reason: "ImagePullBackOff"}
+	if _, failed := podFailure(pod); !failed {
+		log.Panicf("pod without an image didn't fail")
+	}
+}
+
+func TestRollbackSet(t *testing.T) {
+	rs := makeRollbackSet()
+	uid := types.UID("fn")
+	pkg1 := fission.PackageRef{Namespace: metav1.NamespaceDefault, Name: "pkg-1", ResourceVersion: "1"}
+	pkg2 := fission.PackageRef{Namespace: metav1.NamespaceDefault, Name: "pkg-2", ResourceVersion: "5"}
+
+	if rs.take(uid, pkg1) {
+		log.Panicf("rollout taken for a rollback that didn't happen")
+	}
+
+	rs.add(uid, pkg1)
+	if !rs.take(uid, pkg1) {
+		log.Panicf("rollout of the rollback not recognized")
+	}
+	if rs.take(uid, pkg1) {
+		log.Panicf("rollback recognized twice")
+	}
+
+	// an update superseding the rollback isn't a rollback
+	rs.add(uid, pkg1)
+	if rs.take(uid, pkg2) {
+		log.Panicf("rollout of a later update taken for the rollback")
+	}
+	if rs.take(uid, pkg1) {
+		log.Panicf("superseded rollback kept")
+	}
+}
+
+func TestReadyTimeout(t *testing.T) {
+	fn := &crd.Function{}
+	if readyTimeout(fn, deployTimeout) != deployTimeout {
+		log.Panicf("timeout changed without a readiness probe")
+	}
+
+	fn.Spec.Probes = &fission.FunctionProbes{
+		Readiness: &fission.HTTPProbe{Path: "/ready", InitialDelaySeconds: 60},
+	}
+	// 60s initial delay, plus 3 failures 10s apart
+	if timeout := readyTimeout(fn, deployTimeout); timeout != deployTimeout {
+		log.Panicf("timeout shorter than the default: %v", timeout)
+	}
+
+	fn.Spec.Probes.Readiness.InitialDelaySeconds = 300
+	if timeout := readyTimeout(fn, deployTimeout); timeout != 330*time.Second {
+		log.Panicf("timeout doesn't allow for the readiness probe: %v", timeout)
+	}
+}
+
+func TestRollbackPackage(t *testing.T) {
+	makeFn := func(name string, resourceVersion string) *crd.Function {
+		fn := &crd.Function{}
+		fn.Spec.Package.PackageRef = fission.PackageRef{
+			Namespace:       metav1.NamespaceDefault,
+			Name:            name,
+			ResourceVersion: resourceVersion,
+		}
+		return fn
+	}
+
+	// a new package can be rolled back to the previous one
+	ref, _ := rollbackPackage(makeFn("pkg-1", "1"), makeFn("pkg-2", "5"))
+	if ref == nil || ref.Name != "pkg-1" {
+		log.Panicf("expected rollback to pkg-1, got %v", ref)
+	}
+
+	// a package updated in place has lost its previous contents
+	ref, reason := rollbackPackage(makeFn("pkg-1", "1"), makeFn("pkg-1", "2"))
+	if ref != nil || len(reason) == 0 {
+		log.Panicf("expected no rollback for a package updated in place, got %v", ref)
+	}
+
+	ref, _ = rollbackPackage(makeFn("pkg-1", "1"), makeFn("pkg-1", "1"))
+	if ref != nil {
+		log.Panicf("expected no rollback for an unchanged package, got %v", ref)
+	}
+}
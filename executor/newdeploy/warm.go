@@ -0,0 +1,85 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package newdeploy
+
+import (
+	"log"
+
+	k8s_err "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+)
+
+// minReplicas returns the fewest replicas the function may run, given the
+// number of instances its warm schedule asks for: its MinScale, at least
+// one, raised to the warm instances but not beyond its MaxScale.
+func minReplicas(es *fission.ExecutionStrategy, warmInstances int) int32 {
+	replicas := es.MinScale
+	if replicas < 1 {
+		replicas = 1
+	}
+	if warmInstances > replicas {
+		replicas = warmInstances
+		if es.MaxScale > 0 && replicas > es.MaxScale {
+			replicas = es.MaxScale
+		}
+	}
+	return int32(replicas)
+}
+
+// KeepWarm raises the minimum replicas of the function's HPA to the given
+// number of instances, and scales the deployment up to them. The HPA goes
+// back to the function's MinScale when no window is open.
+func (deploy *NewDeploy) KeepWarm(fn *crd.Function, instances int) error {
+	es := &fn.Spec.InvokeStrategy.ExecutionStrategy
+	replicas := minReplicas(es, instances)
+
+	// functions scaled on concurrency have no HPA; the concurrency
+	// scaler follows the warm schedule itself
+	if es.TargetConcurrency <= 0 {
+		hpa, err := deploy.getHpa(fn)
+		if err != nil {
+			if k8s_err.IsNotFound(err) {
+				// the function has no deployment yet
+				return nil
+			}
+			return err
+		}
+		if hpa.Spec.MinReplicas == nil || *hpa.Spec.MinReplicas != replicas {
+			hpa.Spec.MinReplicas = &replicas
+			err = deploy.updateHpa(hpa)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if instances == 0 {
+		return nil
+	}
+	depl, err := deploy.getDeployment(fn)
+	if err != nil {
+		return err
+	}
+	if depl.Spec.Replicas != nil && *depl.Spec.Replicas >= replicas {
+		return nil
+	}
+	log.Printf("Scaling function %v up to %v replicas for its warm schedule", fn.Metadata.Name, replicas)
+	depl.Spec.Replicas = &replicas
+	return deploy.updateDeployment(depl)
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolmgr
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/pkg/api"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/fscache"
+)
+
+// AdoptExistingResources adds the specialized pods left by earlier
+// executor instances to the function service cache, if their function
+// still exists and still uses the pool manager. It returns the UIDs of
+// the adopted pods.
+func (gpm *GenericPoolManager) AdoptExistingResources() ([]types.UID, error) {
+	// specialized pods are relabeled with the function's labels, and
+	// "unmanaged" so that the pool deployment lets go of them
+	podList, err := gpm.kubernetesClient.CoreV1().Pods(gpm.namespace).List(metav1.ListOptions{
+		LabelSelector: labels.Set(map[string]string{"unmanaged": "true"}).AsSelector().String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fnList, err := gpm.fissionClient.Functions(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	fns := make(map[types.UID]*crd.Function)
+	for i := range fnList.Items {
+		fns[fnList.Items[i].Metadata.UID] = &fnList.Items[i]
+	}
+
+	adopted := make([]types.UID, 0)
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		fn, ok := fns[types.UID(pod.ObjectMeta.Labels["functionUid"])]
+		if !ok {
+			continue
+		}
+		if gpm.adoptPod(pod, fn) {
+			adopted = append(adopted, pod.ObjectMeta.UID)
+		}
+	}
+	return adopted, nil
+}
+
+// adoptPod adds a specialized pod of another executor instance to the
+// cache, if it's ready and its function still uses the pool manager. The
+// first pod of a function is its function service; any others are
+// scaled out.
+func (gpm *GenericPoolManager) adoptPod(pod *apiv1.Pod, fn *crd.Function) bool {
+	if pod.ObjectMeta.Labels[fission.EXECUTOR_INSTANCEID_LABEL] == gpm.instanceId ||
+		pod.ObjectMeta.DeletionTimestamp != nil || !fission.IsReadyPod(pod) {
+		return false
+	}
+	executorType := fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType
+	if len(executorType) > 0 && executorType != fission.ExecutorTypePoolmgr {
+		return false
+	}
+	if _, ok := gpm.cachedPod(pod.ObjectMeta.Name); ok {
+		return false
+	}
+
+	env, err := gpm.fissionClient.Environments(fn.Spec.Environment.Namespace).Get(fn.Spec.Environment.Name)
+	if err != nil {
+		log.Printf("Not adopting pod %v, error getting environment: %v", pod.ObjectMeta.Name, err)
+		return false
+	}
+	if version, ok := pod.ObjectMeta.Labels["environmentVersion"]; ok && version != crd.EnvironmentVersion(env) {
+		// the environment changed since the pod was specialized
+		return false
+	}
+
+	// same addressing as GenericPool.GetFuncSvc
+	svcHost := fmt.Sprintf("%v:8888", pod.Status.PodIP)
+	if gpm.enableIstio {
+		svc := fission.GetFunctionIstioServiceName(fn.Metadata.Name, fn.Metadata.Namespace)
+		svcHost = fmt.Sprintf("%v.%v:8888", svc, gpm.namespace)
+	}
+
+	fsvc := fscache.FuncSvc{
+		Name:        pod.ObjectMeta.Name,
+		Function:    &fn.Metadata,
+		Environment: env,
+		Address:     svcHost,
+		KubernetesObjects: []api.ObjectReference{
+			{
+				Kind:            "pod",
+				Name:            pod.ObjectMeta.Name,
+				APIVersion:      pod.TypeMeta.APIVersion,
+				Namespace:       pod.ObjectMeta.Namespace,
+				ResourceVersion: pod.ObjectMeta.ResourceVersion,
+				UID:             pod.ObjectMeta.UID,
+			},
+		},
+		Executor: fission.ExecutorTypePoolmgr,
+		Ctime:    time.Now(),
+		Atime:    time.Now(),
+	}
+	if _, err := gpm.fsCache.GetByFunctionUID(fn.Metadata.UID); err == nil {
+		err = gpm.fsCache.AddScaledOut(fsvc)
+	} else {
+		_, err = gpm.fsCache.Add(fsvc)
+	}
+	if err != nil {
+		log.Printf("Not adopting pod %v, error adding it to the cache: %v", pod.ObjectMeta.Name, err)
+		return false
+	}
+	log.Printf("Adopted pod %v for function %v", pod.ObjectMeta.Name, fn.Metadata.Name)
+	return true
+}
+
+// cachedPod returns the function service of the pod, if it's cached.
+func (gpm *GenericPoolManager) cachedPod(name string) (*fscache.FuncSvc, bool) {
+	for _, fsvc := range gpm.fsCache.ListByExecutor(fission.ExecutorTypePoolmgr) {
+		if fsvc.Name == name {
+			return fsvc, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolmgr
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	k8sCache "k8s.io/client-go/tools/cache"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/util"
+)
+
+// Follow keeps the cache of a follower replica in step with the pods the
+// leader specializes, as they become ready and go away.
+func (gpm *GenericPoolManager) Follow(stop <-chan struct{}) {
+	fnIndex, fnController := util.MakeFunctionIndex(gpm.fissionClient.GetCrdClient(), k8sCache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+			// the pods of the previous version are replaced
+			gpm.unfollowFunction(oldObj.(*crd.Function).Metadata.UID)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if fn, ok := util.DeletedObject(obj).(*crd.Function); ok {
+				gpm.unfollowFunction(fn.Metadata.UID)
+			}
+		},
+	})
+
+	followPod := func(obj interface{}) {
+		pod := obj.(*apiv1.Pod)
+		if pod.ObjectMeta.DeletionTimestamp != nil || !fission.IsReadyPod(pod) {
+			gpm.unfollowPod(pod.ObjectMeta.Name)
+			return
+		}
+		fn, ok := util.FunctionByUID(fnIndex, types.UID(pod.ObjectMeta.Labels["functionUid"]))
+		if !ok {
+			return
+		}
+		gpm.adoptPod(pod, fn)
+	}
+
+	// specialized pods, as AdoptExistingResources finds them. Resyncs
+	// from the informer's store pick up pods seen before their function.
+	selector := labels.Set(map[string]string{"unmanaged": "true"}).AsSelector().String()
+	_, podController := k8sCache.NewInformer(&k8sCache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = selector
+			return gpm.kubernetesClient.CoreV1().Pods(gpm.namespace).List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = selector
+			return gpm.kubernetesClient.CoreV1().Pods(gpm.namespace).Watch(options)
+		},
+	}, &apiv1.Pod{}, util.FollowerResyncPeriod, k8sCache.ResourceEventHandlerFuncs{
+		AddFunc: followPod,
+		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+			followPod(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if pod, ok := util.DeletedObject(obj).(*apiv1.Pod); ok {
+				gpm.unfollowPod(pod.ObjectMeta.Name)
+			}
+		},
+	})
+
+	util.RunControllers(stop, fnController, podController)
+}
+
+// unfollowPod removes the function service of the pod from the cache.
+func (gpm *GenericPoolManager) unfollowPod(name string) {
+	if fsvc, ok := gpm.cachedPod(name); ok {
+		gpm.fsCache.DeleteEntry(fsvc)
+	}
+}
+
+// unfollowFunction removes the function services of the function from
+// the cache.
+func (gpm *GenericPoolManager) unfollowFunction(uid types.UID) {
+	for _, fsvc := range gpm.fsCache.ListByExecutor(fission.ExecutorTypePoolmgr) {
+		if fsvc.Function.UID == uid {
+			gpm.fsCache.DeleteEntry(fsvc)
+		}
+	}
+}
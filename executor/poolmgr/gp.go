@@ -29,16 +29,19 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/dchest/uniuri"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/pkg/api"
 	apiv1 "k8s.io/client-go/pkg/api/v1"
 	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	k8sCache "k8s.io/client-go/tools/cache"
 
 	"github.com/fission/fission"
 	"github.com/fission/fission/crd"
@@ -53,7 +56,9 @@ const POD_PHASE_RUNNING string = "Running"
 type (
 	GenericPool struct {
 		env                    *crd.Environment
+		version                string                        // crd.EnvironmentVersion of env
 		replicas               int32                         // num idle pods
+		readyReplicas          int32                         // ready pods of the deployment, as last seen
 		deployment             *v1beta1.Deployment           // kubernetes deployment
 		namespace              string                        // namespace to keep our resources
 		podReadyTimeout        time.Duration                 // timeout for generic pods to become ready
@@ -73,6 +78,8 @@ type (
 		sharedMountPath        string // used by generic pool when creating env deployment to specify the share volume path for fetcher & env
 		sharedSecretPath       string
 		sharedCfgMapPath       string
+		scaler                 *poolScaler // nil unless the env enables adaptive pool sizing
+		stopCh                 chan struct{}
 	}
 
 	// serialize the choosing of pods so that choices don't conflict
@@ -126,6 +133,7 @@ func MakeGenericPool(
 	// replicas, autoscaling params, various timeouts, etc.
 	gp := &GenericPool{
 		env:              env,
+		version:          crd.EnvironmentVersion(env),
 		replicas:         initialReplicas, // TODO make this an env param instead?
 		requestChannel:   make(chan *choosePodRequest),
 		fissionClient:    fissionClient,
@@ -142,6 +150,7 @@ func MakeGenericPool(
 		sharedMountPath:  "/userfunc", // change this may break v1 compatibility, since most of the v1 environments have hard-coded "/userfunc" in loading path
 		sharedSecretPath: "/secrets",
 		sharedCfgMapPath: "/configs",
+		stopCh:           make(chan struct{}),
 	}
 
 	gp.runtimeImagePullPolicy = getImagePullPolicy(runtimeImagePullPolicy)
@@ -153,6 +162,7 @@ func MakeGenericPool(
 	gp.labelsForPool = map[string]string{
 		"environmentName":                 gp.env.Metadata.Name,
 		"environmentUid":                  string(gp.env.Metadata.UID),
+		"environmentVersion":              gp.version,
 		fission.EXECUTOR_INSTANCEID_LABEL: gp.instanceId,
 		"executorType":                    fission.ExecutorTypePoolmgr,
 	}
@@ -165,10 +175,38 @@ func MakeGenericPool(
 	log.Printf("[%v] Deployment created", env.Metadata)
 
 	go gp.choosePodService()
+	go gp.watchDeployment()
+
+	if env.Spec.PoolScaling != nil &&
+		env.Spec.AllowedFunctionsPerContainer != fission.AllowedFunctionsPerContainerInfinite {
+		gp.scaler = makePoolScaler(env.Spec.PoolScaling)
+		go gp.scalePool()
+	}
 
 	return gp, nil
 }
 
+// watchDeployment keeps track of the ready pods of the pool's deployment
+// until the pool is destroyed.
+func (gp *GenericPool) watchDeployment() {
+	listWatch := k8sCache.NewListWatchFromClient(gp.kubernetesClient.ExtensionsV1beta1().RESTClient(), "deployments",
+		gp.namespace, fields.OneTermEqualSelector("metadata.name", gp.deployment.ObjectMeta.Name))
+	setReady := func(obj interface{}) {
+		depl := obj.(*v1beta1.Deployment)
+		atomic.StoreInt32(&gp.readyReplicas, depl.Status.ReadyReplicas)
+	}
+	_, controller := k8sCache.NewInformer(listWatch, &v1beta1.Deployment{}, 0, k8sCache.ResourceEventHandlerFuncs{
+		AddFunc: setReady,
+		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+			setReady(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			atomic.StoreInt32(&gp.readyReplicas, 0)
+		},
+	})
+	controller.Run(gp.stopCh)
+}
+
 // choosePodService serializes the choosing of pods
 func (gp *GenericPool) choosePodService() {
 	for {
@@ -264,14 +302,57 @@ func (gp *GenericPool) _choosePod(newLabels map[string]string) (*apiv1.Pod, erro
 			}
 		}
 		log.Printf("Chosen pod: %v (in %v)", chosenPod.ObjectMeta.Name, time.Since(startTime))
+		if gp.scaler != nil {
+			gp.scaler.podChosen()
+		}
 		return chosenPod, nil
 	}
 }
 
+// scalePool periodically resizes the pool deployment to follow the
+// demand for specialized pods, until the pool is destroyed.
+func (gp *GenericPool) scalePool() {
+	ticker := time.NewTicker(poolScalerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-gp.stopCh:
+			return
+		case now := <-ticker.C:
+			current := atomic.LoadInt32(&gp.replicas)
+			replicas, ok := gp.scaler.scale(current, poolScalerInterval, now)
+			if !ok {
+				continue
+			}
+			log.Printf("[%v] scaling pool from %v to %v pods", gp.env.Metadata.Name, current, replicas)
+			err := gp.setReplicas(replicas)
+			if err != nil {
+				log.Printf("[%v] error scaling pool: %v", gp.env.Metadata.Name, err)
+			}
+		}
+	}
+}
+
+func (gp *GenericPool) setReplicas(replicas int32) error {
+	depl, err := gp.kubernetesClient.ExtensionsV1beta1().Deployments(gp.namespace).Get(
+		gp.deployment.ObjectMeta.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	depl.Spec.Replicas = &replicas
+	_, err = gp.kubernetesClient.ExtensionsV1beta1().Deployments(gp.namespace).Update(depl)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&gp.replicas, replicas)
+	return nil
+}
+
 func (gp *GenericPool) labelsForFunction(metadata *metav1.ObjectMeta) map[string]string {
 	return map[string]string{
 		"functionName":                    metadata.Name,
 		"functionUid":                     string(metadata.UID),
+		"environmentVersion":              gp.version,
 		"unmanaged":                       "true", // this allows us to easily find pods not managed by the deployment
 		fission.EXECUTOR_INSTANCEID_LABEL: gp.instanceId,
 	}
@@ -363,6 +444,15 @@ func (gp *GenericPool) specializePod(pod *apiv1.Pod, metadata *metav1.ObjectMeta
 		return err
 	}
 
+	// v1 environment servers can't set the function's environment
+	// variables; the controller rejects such functions, unless the
+	// environment changed since
+	err = fn.Spec.ValidateEnvSupport(&gp.env.Spec)
+	if err != nil {
+		gp.recordSpecialization(fn, pod, "", err)
+		return err
+	}
+
 	// for backward compatibility, since most v1 env
 	// still try to load user function from hard coded
 	// path /userfunc/user
@@ -371,6 +461,8 @@ func (gp *GenericPool) specializePod(pod *apiv1.Pod, metadata *metav1.ObjectMeta
 		targetFilename = string(fn.Metadata.UID)
 	}
 
+	envName := gp.env.Metadata.Name
+	fetchStart := time.Now()
 	err = fetcherClient.MakeClient(fetcherUrl).Fetch(&fetcher.FetchRequest{
 		FetchType: fetcher.FETCH_DEPLOYMENT,
 		Package: metav1.ObjectMeta{
@@ -382,12 +474,24 @@ func (gp *GenericPool) specializePod(pod *apiv1.Pod, metadata *metav1.ObjectMeta
 		ConfigMaps: fn.Spec.ConfigMaps,
 	})
 	if err != nil {
+		specializeFailures.WithLabelValues(envName, specializePhaseFetch).Inc()
+		gp.recordSpecialization(fn, pod, specializePhaseFetch, err)
 		return err
 	}
+	specializeDuration.WithLabelValues(envName, specializePhaseFetch).Observe(time.Since(fetchStart).Seconds())
 
 	// get function run container to specialize
 	log.Printf("[%v] specializing pod", metadata.Name)
 
+	// the pod is already running, so the function's environment
+	// variables are set by the environment server
+	env, err := fetcher.ResolveEnv(gp.kubernetesClient, fn.Metadata.Namespace, fn.Spec.Env)
+	if err != nil {
+		specializeFailures.WithLabelValues(envName, specializePhaseSpecialize).Inc()
+		gp.recordSpecialization(fn, pod, "", err)
+		return err
+	}
+
 	// retry the specialize call a few times in case the env server hasn't come up yet
 	maxRetries := 20
 
@@ -395,6 +499,7 @@ func (gp *GenericPool) specializePod(pod *apiv1.Pod, metadata *metav1.ObjectMeta
 		FilePath:         filepath.Join(gp.sharedMountPath, targetFilename),
 		FunctionName:     fn.Spec.Package.FunctionName,
 		FunctionMetadata: &fn.Metadata,
+		Env:              env,
 	}
 
 	body, err := json.Marshal(loadReq)
@@ -402,6 +507,7 @@ func (gp *GenericPool) specializePod(pod *apiv1.Pod, metadata *metav1.ObjectMeta
 		return err
 	}
 
+	specializeStart := time.Now()
 	for i := 0; i < maxRetries; i++ {
 		var resp2 *http.Response
 		if gp.env.Spec.Version == 2 {
@@ -416,6 +522,8 @@ func (gp *GenericPool) specializePod(pod *apiv1.Pod, metadata *metav1.ObjectMeta
 		if err == nil && resp2.StatusCode < 300 {
 			// Success
 			resp2.Body.Close()
+			specializeDuration.WithLabelValues(envName, specializePhaseSpecialize).Observe(time.Since(specializeStart).Seconds())
+			gp.recordSpecialization(fn, pod, "", nil)
 			return nil
 		}
 
@@ -450,12 +558,34 @@ func (gp *GenericPool) specializePod(pod *apiv1.Pod, metadata *metav1.ObjectMeta
 		}
 
 		log.Printf("Failed to specialize pod: %v", err)
+		specializeFailures.WithLabelValues(envName, specializePhaseSpecialize).Inc()
+		gp.recordSpecialization(fn, pod, specializePhaseSpecialize, err)
 		return err
 	}
 
 	return nil
 }
 
+// recordSpecialization records the outcome of specializing the pod on
+// the function. The phase, if any, is the one whose response is in err.
+func (gp *GenericPool) recordSpecialization(fn *crd.Function, pod *apiv1.Pod, phase string, err error) {
+	status := &fission.SpecializationStatus{
+		Succeeded:    err == nil,
+		ExecutorType: fission.ExecutorTypePoolmgr,
+		PodName:      pod.ObjectMeta.Name,
+	}
+	if err != nil {
+		status.Error = err.Error()
+		switch phase {
+		case specializePhaseFetch:
+			status.FetcherResponse = util.ResponseOf(err)
+		case specializePhaseSpecialize:
+			status.SpecializeResponse = util.ResponseOf(err)
+		}
+	}
+	go util.RecordSpecialization(gp.kubernetesClient, fn, status)
+}
+
 // A pool is a deployment of generic containers for an env.  This
 // creates the pool but doesn't wait for any pods to be ready.
 func (gp *GenericPool) createPool() error {
@@ -623,7 +753,7 @@ func (gp *GenericPool) createPool() error {
 							},
 						},
 					},
-					ServiceAccountName: "fission-fetcher",
+					ServiceAccountName: util.FetcherServiceAccount,
 					// TerminationGracePeriodSeconds should be equal to the
 					// sleep time of preStop to make sure that SIGTERM is sent
 					// to pod after 6 mins.
@@ -632,6 +762,12 @@ func (gp *GenericPool) createPool() error {
 			},
 		},
 	}
+	fission.ApplyScheduling(&deployment.Spec.Template, gp.env.Spec.Scheduling)
+	fission.ApplyPodSecurity(&deployment.Spec.Template, gp.env.Metadata.Name, gp.env.Spec.Security)
+	err = util.MountFetcherToken(gp.kubernetesClient, gp.namespace, &deployment.Spec.Template.Spec)
+	if err != nil {
+		return err
+	}
 
 	depl, err := gp.kubernetesClient.ExtensionsV1beta1().Deployments(gp.namespace).Create(deployment)
 	if err != nil {
@@ -686,6 +822,43 @@ func (gp *GenericPool) createSvc(name string, labels map[string]string) (*apiv1.
 }
 
 func (gp *GenericPool) GetFuncSvc(m *metav1.ObjectMeta) (*fscache.FuncSvc, error) {
+	fsvc, err := gp.specializeFuncSvc(m)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = gp.fsCache.Add(*fsvc)
+	if err != nil {
+		return nil, err
+	}
+	return fsvc, nil
+}
+
+// scaleOutFuncSvc specializes another pod for a function that already
+// has one.
+func (gp *GenericPool) scaleOutFuncSvc(m *metav1.ObjectMeta) (*fscache.FuncSvc, error) {
+	if gp.useSvc || gp.useIstio {
+		// all pods of the function would share one address
+		return nil, fission.MakeError(fission.ErrorInvalidArgument,
+			"function services behind a kubernetes service can't be scaled out")
+	}
+
+	fsvc, err := gp.specializeFuncSvc(m)
+	if err != nil {
+		return nil, err
+	}
+
+	err = gp.fsCache.AddScaledOut(*fsvc)
+	if err != nil {
+		gp.kubernetesClient.CoreV1().Pods(gp.namespace).Delete(fsvc.Name, nil)
+		return nil, err
+	}
+	return fsvc, nil
+}
+
+// specializeFuncSvc chooses a pod from the pool and specializes it for
+// the function.
+func (gp *GenericPool) specializeFuncSvc(m *metav1.ObjectMeta) (*fscache.FuncSvc, error) {
 	log.Printf("[%v] Choosing pod from pool", m.Name)
 	newLabels := gp.labelsForFunction(m)
 
@@ -788,20 +961,17 @@ func (gp *GenericPool) GetFuncSvc(m *metav1.ObjectMeta) (*fscache.FuncSvc, error
 		Environment:       gp.env,
 		Address:           svcHost,
 		KubernetesObjects: kubeObjRefs,
-		Executor:          fscache.POOLMGR,
+		Executor:          fission.ExecutorTypePoolmgr,
 		Ctime:             time.Now(),
 		Atime:             time.Now(),
 	}
-
-	_, err = gp.fsCache.Add(*fsvc)
-	if err != nil {
-		return nil, err
-	}
 	return fsvc, nil
 }
 
 // destroys the pool -- the deployment, replicaset and pods
 func (gp *GenericPool) destroy() error {
+	close(gp.stopCh)
+
 	// Destroy deployment
 	err := gp.kubernetesClient.ExtensionsV1beta1().Deployments(gp.namespace).Delete(gp.deployment.ObjectMeta.Name, nil)
 	if err != nil {
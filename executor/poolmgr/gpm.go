@@ -18,12 +18,14 @@ package poolmgr
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/pkg/api"
@@ -31,6 +33,7 @@ import (
 
 	"github.com/fission/fission"
 	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/executortype"
 	"github.com/fission/fission/executor/fscache"
 )
 
@@ -39,11 +42,14 @@ type requestType int
 const (
 	GET_POOL requestType = iota
 	CLEANUP_POOLS
+	LIST_POOLS
+	FINISH_ROLLOUT
 )
 
 type (
 	GenericPoolManager struct {
-		pools            map[string]*GenericPool
+		pools            map[string]*GenericPool // by environment UID
+		rollouts         map[string]bool         // environments whose old pool is being replaced
 		kubernetesClient *kubernetes.Clientset
 		namespace        string
 
@@ -51,6 +57,7 @@ type (
 		fsCache        *fscache.FunctionServiceCache
 		instanceId     string
 		requestChannel chan *request
+		scaleOut       *scaleOutTracker
 
 		enableIstio          bool
 		istioServiceRegister k8sCache.Controller
@@ -59,14 +66,28 @@ type (
 		requestType
 		env             *crd.Environment
 		envList         []crd.Environment
+		envUID          string // for FINISH_ROLLOUT
 		responseChannel chan *response
 	}
 	response struct {
 		error
-		pool *GenericPool
+		pool  *GenericPool
+		pools []*GenericPool
 	}
 )
 
+func init() {
+	executortype.Register(fission.ExecutorTypePoolmgr, func(opts *executortype.Options) (executortype.ExecutorType, error) {
+		gpm := MakeGenericPoolManager(opts.FissionClient, opts.KubernetesClient,
+			opts.FunctionNamespace, opts.FsCache, opts.InstanceID)
+		err := prometheus.Register(&poolCollector{gpm: gpm})
+		if err != nil {
+			return nil, err
+		}
+		return gpm, nil
+	})
+}
+
 func MakeGenericPoolManager(
 	fissionClient *crd.FissionClient,
 	kubernetesClient *kubernetes.Clientset,
@@ -76,15 +97,16 @@ func MakeGenericPoolManager(
 
 	gpm := &GenericPoolManager{
 		pools:            make(map[string]*GenericPool),
+		rollouts:         make(map[string]bool),
 		kubernetesClient: kubernetesClient,
 		namespace:        functionNamespace,
 		fissionClient:    fissionClient,
 		fsCache:          fsCache,
 		instanceId:       instanceId,
 		requestChannel:   make(chan *request),
+		scaleOut:         makeScaleOutTracker(),
 	}
 	go gpm.service()
-	go gpm.eagerPoolCreator()
 
 	if len(os.Getenv("ENABLE_ISTIO")) > 0 {
 		istio, err := strconv.ParseBool(os.Getenv("ENABLE_ISTIO"))
@@ -103,46 +125,87 @@ func MakeGenericPoolManager(
 }
 
 func (gpm *GenericPoolManager) Run(ctx context.Context) {
+	// pools are only created by the executor that manages function
+	// services
+	go gpm.eagerPoolCreator()
+	go gpm.runScaleOut(ctx)
 	if gpm.enableIstio && gpm.istioServiceRegister != nil {
 		go gpm.istioServiceRegister.Run(ctx.Done())
 	}
 }
 
+// GetFuncSvc specializes a pod from the environment's pool for the function.
+func (gpm *GenericPoolManager) GetFuncSvc(metadata *metav1.ObjectMeta, env *crd.Environment) (*fscache.FuncSvc, error) {
+	pool, err := gpm.GetPool(env)
+	if err != nil {
+		return nil, err
+	}
+	// from GenericPool -> get one function container
+	// (this also adds to the cache)
+	log.Printf("[%v] getting function service from pool", metadata.Name)
+	return pool.GetFuncSvc(metadata)
+}
+
+func (gpm *GenericPoolManager) IsValid(fsvc *fscache.FuncSvc) bool {
+	return gpm.IsValidPod(fsvc.KubernetesObjects, fsvc.Address)
+}
+
+// DeleteFuncSvc deletes the specialized pod, and its service if any.
+func (gpm *GenericPoolManager) DeleteFuncSvc(fsvc *fscache.FuncSvc) error {
+	gpm.fsCache.DeleteEntry(fsvc)
+
+	var delError error
+	for _, obj := range fsvc.KubernetesObjects {
+		var err error
+		switch strings.ToLower(obj.Kind) {
+		case "pod":
+			err = gpm.kubernetesClient.CoreV1().Pods(obj.Namespace).Delete(obj.Name, nil)
+		case "service":
+			err = gpm.kubernetesClient.CoreV1().Services(obj.Namespace).Delete(obj.Name, nil)
+		default:
+			err = fmt.Errorf("unexpected object kind %v", obj.Kind)
+		}
+		if err != nil {
+			log.Printf("Error deleting %v %v: %v", obj.Kind, obj.Name, err)
+			delError = err
+		}
+	}
+	return delError
+}
+
+func (gpm *GenericPoolManager) ListFuncSvcs() ([]*fscache.FuncSvc, error) {
+	return gpm.fsCache.ListByExecutor(fission.ExecutorTypePoolmgr), nil
+}
+
 func (gpm *GenericPoolManager) service() {
 	for {
 		req := <-gpm.requestChannel
 		switch req.requestType {
 		case GET_POOL:
+			// Requests may come with an environment that's a few
+			// seconds old; the current pool is replaced by
+			// CLEANUP_POOLS, once the environment changes.
 			var err error
-			pool, ok := gpm.pools[crd.CacheKey(&req.env.Metadata)]
+			key := string(req.env.Metadata.UID)
+			pool, ok := gpm.pools[key]
 			if !ok {
-				poolsize := gpm.getEnvPoolsize(req.env)
-				switch req.env.Spec.AllowedFunctionsPerContainer {
-				case fission.AllowedFunctionsPerContainerInfinite:
-					poolsize = 1
-				}
-
-				pool, err = MakeGenericPool(
-					gpm.fissionClient, gpm.kubernetesClient, req.env, poolsize,
-					gpm.namespace, gpm.fsCache, gpm.instanceId, gpm.enableIstio)
+				pool, err = gpm.makePool(req.env)
 				if err != nil {
 					req.responseChannel <- &response{error: err}
 					continue
 				}
-				gpm.pools[crd.CacheKey(&req.env.Metadata)] = pool
+				gpm.pools[key] = pool
 			}
 			req.responseChannel <- &response{pool: pool}
 		case CLEANUP_POOLS:
-			latestEnvSet := make(map[string]bool)
-			latestEnvPoolsize := make(map[string]int)
-			for _, env := range req.envList {
-				latestEnvSet[crd.CacheKey(&env.Metadata)] = true
-				latestEnvPoolsize[crd.CacheKey(&env.Metadata)] = int(gpm.getEnvPoolsize(&env))
+			latestEnvs := make(map[string]*crd.Environment)
+			for i := range req.envList {
+				env := &req.envList[i]
+				latestEnvs[string(env.Metadata.UID)] = env
 			}
 			for key, pool := range gpm.pools {
-				_, ok := latestEnvSet[key]
-				poolsize := latestEnvPoolsize[key]
-				if !ok || poolsize == 0 {
+				env, ok := latestEnvs[key]
+				if !ok || gpm.getEnvPoolsize(env) == 0 {
 					// Env no longer exists or pool size changed to zero
 
 					log.Printf("Destroying generic pool for environment [%v]", key)
@@ -150,13 +213,48 @@ func (gpm *GenericPoolManager) service() {
 
 					// and delete the pool asynchronously.
 					go pool.destroy()
+					continue
 				}
+				if pool.version == crd.EnvironmentVersion(env) || gpm.rollouts[key] {
+					continue
+				}
+
+				// The environment changed. New function services
+				// come from a new pool, and the functions on the old
+				// one are moved over gradually.
+				newPool, err := gpm.makePool(env)
+				if err != nil {
+					log.Printf("Error creating new pool for environment %v: %v", env.Metadata.Name, err)
+					continue
+				}
+				gpm.pools[key] = newPool
+				gpm.rollouts[key] = true
+				go gpm.rolloutPool(pool, newPool)
 			}
 			// no response, caller doesn't wait
+		case FINISH_ROLLOUT:
+			delete(gpm.rollouts, req.envUID)
+		case LIST_POOLS:
+			pools := make([]*GenericPool, 0, len(gpm.pools))
+			for _, pool := range gpm.pools {
+				pools = append(pools, pool)
+			}
+			req.responseChannel <- &response{pools: pools}
 		}
 	}
 }
 
+func (gpm *GenericPoolManager) makePool(env *crd.Environment) (*GenericPool, error) {
+	poolsize := gpm.getEnvPoolsize(env)
+	switch env.Spec.AllowedFunctionsPerContainer {
+	case fission.AllowedFunctionsPerContainerInfinite:
+		poolsize = 1
+	}
+	return MakeGenericPool(
+		gpm.fissionClient, gpm.kubernetesClient, env, poolsize,
+		gpm.namespace, gpm.fsCache, gpm.instanceId, gpm.enableIstio)
+}
+
 func (gpm *GenericPoolManager) GetPool(env *crd.Environment) (*GenericPool, error) {
 	c := make(chan *response)
 	gpm.requestChannel <- &request{
@@ -168,6 +266,16 @@ func (gpm *GenericPoolManager) GetPool(env *crd.Environment) (*GenericPool, erro
 	return resp.pool, resp.error
 }
 
+func (gpm *GenericPoolManager) listPools() []*GenericPool {
+	c := make(chan *response)
+	gpm.requestChannel <- &request{
+		requestType:     LIST_POOLS,
+		responseChannel: c,
+	}
+	resp := <-c
+	return resp.pools
+}
+
 func (gpm *GenericPoolManager) CleanupPools(envs []crd.Environment) {
 	gpm.requestChannel <- &request{
 		requestType: CLEANUP_POOLS,
@@ -175,6 +283,14 @@ func (gpm *GenericPoolManager) CleanupPools(envs []crd.Environment) {
 	}
 }
 
+// finishRollout lets CLEANUP_POOLS replace the environment's pool again.
+func (gpm *GenericPoolManager) finishRollout(env *crd.Environment) {
+	gpm.requestChannel <- &request{
+		requestType: FINISH_ROLLOUT,
+		envUID:      string(env.Metadata.UID),
+	}
+}
+
 func (gpm *GenericPoolManager) eagerPoolCreator() {
 	pollSleep := time.Duration(2 * time.Second)
 	for {
@@ -204,7 +320,8 @@ func (gpm *GenericPoolManager) eagerPoolCreator() {
 			}
 		}
 
-		// Clean up pools whose env was deleted
+		// Clean up pools whose env was deleted, and replace the ones
+		// whose env changed
 		gpm.CleanupPools(envs.Items)
 		time.Sleep(pollSleep)
 	}
@@ -217,6 +334,14 @@ func (gpm *GenericPoolManager) getEnvPoolsize(env *crd.Environment) int32 {
 	} else {
 		poolsize = int32(env.Spec.Poolsize)
 	}
+	// start adaptive pools within their bounds
+	if ps := env.Spec.PoolScaling; ps != nil {
+		if poolsize < int32(ps.MinPoolsize) {
+			poolsize = int32(ps.MinPoolsize)
+		} else if poolsize > int32(ps.MaxPoolsize) {
+			poolsize = int32(ps.MaxPoolsize)
+		}
+	}
 	return poolsize
 }
 
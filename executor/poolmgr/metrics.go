@@ -0,0 +1,88 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolmgr
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	specializePhaseFetch      = "fetch"
+	specializePhaseSpecialize = "specialize"
+)
+
+var (
+	specializeDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "fission",
+			Subsystem: "executor",
+			Name:      "specialization_duration_seconds",
+			Help:      "Time taken to specialize a pod, by environment and phase (fetch or specialize).",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"environment", "phase"},
+	)
+	specializeFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "fission",
+			Subsystem: "executor",
+			Name:      "specialization_failures_total",
+			Help:      "Number of failed pod specializations, by environment and phase.",
+		},
+		[]string{"environment", "phase"},
+	)
+
+	poolSizeDesc = prometheus.NewDesc(
+		"fission_executor_pool_size",
+		"Target number of idle pods in the environment's generic pool.",
+		[]string{"environment", "environment_namespace"}, nil,
+	)
+	poolReadyPodsDesc = prometheus.NewDesc(
+		"fission_executor_pool_ready_pods",
+		"Number of ready idle pods in the environment's generic pool.",
+		[]string{"environment", "environment_namespace"}, nil,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(specializeDuration, specializeFailures)
+}
+
+// poolCollector reports the size of each generic pool. The ready pod
+// count is the one the pool last saw on its deployment, so scrapes don't
+// hit the API server.
+type poolCollector struct {
+	gpm *GenericPoolManager
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolSizeDesc
+	ch <- poolReadyPodsDesc
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, pool := range c.gpm.listPools() {
+		envName := pool.env.Metadata.Name
+		envNamespace := pool.env.Metadata.Namespace
+		ch <- prometheus.MustNewConstMetric(poolSizeDesc, prometheus.GaugeValue,
+			float64(atomic.LoadInt32(&pool.replicas)), envName, envNamespace)
+		ch <- prometheus.MustNewConstMetric(poolReadyPodsDesc, prometheus.GaugeValue,
+			float64(atomic.LoadInt32(&pool.readyReplicas)), envName, envNamespace)
+	}
+}
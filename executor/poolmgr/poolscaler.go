@@ -0,0 +1,98 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolmgr
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/fission/fission"
+)
+
+const (
+	poolScalerInterval = 10 * time.Second
+
+	// weight of the latest sample in the smoothed demand estimate
+	poolScalerSmoothing = 0.3
+
+	// roughly how long a new generic pod takes to become ready; the pool
+	// keeps enough idle pods to cover the demand over this window
+	poolScalerLeadTime = 30 * time.Second
+
+	defaultPoolScalingCooldown = 60 * time.Second
+)
+
+// poolScaler estimates the demand for specialized pods of a pool and
+// picks the pool size for it. Scaling up happens as soon as demand rises;
+// scaling down waits for the cooldown since the last resize, so that a
+// short lull in a bursty environment doesn't drain the pool.
+type poolScaler struct {
+	minSize  int32
+	maxSize  int32
+	cooldown time.Duration
+
+	lock      sync.Mutex
+	chosen    int       // pods chosen since the last sample
+	demand    float64   // smoothed pods chosen per second
+	lastScale time.Time // time of the last resize
+}
+
+func makePoolScaler(ps *fission.PoolScaling) *poolScaler {
+	cooldown := defaultPoolScalingCooldown
+	if ps.Cooldown > 0 {
+		cooldown = time.Duration(ps.Cooldown) * time.Second
+	}
+	return &poolScaler{
+		minSize:  int32(ps.MinPoolsize),
+		maxSize:  int32(ps.MaxPoolsize),
+		cooldown: cooldown,
+	}
+}
+
+// podChosen records that a pod was taken from the pool for specialization.
+func (ps *poolScaler) podChosen() {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	ps.chosen++
+}
+
+// scale folds the pods chosen during the last interval into the demand
+// estimate, and returns the size the pool should be resized to, or false
+// if it should stay at its current size.
+func (ps *poolScaler) scale(current int32, interval time.Duration, now time.Time) (int32, bool) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	rate := float64(ps.chosen) / interval.Seconds()
+	ps.chosen = 0
+	ps.demand = poolScalerSmoothing*rate + (1-poolScalerSmoothing)*ps.demand
+
+	desired := int32(math.Ceil(ps.demand * poolScalerLeadTime.Seconds()))
+	if desired < ps.minSize {
+		desired = ps.minSize
+	}
+	if desired > ps.maxSize {
+		desired = ps.maxSize
+	}
+
+	if desired > current || (desired < current && now.Sub(ps.lastScale) >= ps.cooldown) {
+		ps.lastScale = now
+		return desired, true
+	}
+	return current, false
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolmgr
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/fission/fission"
+)
+
+func TestPoolScaler(t *testing.T) {
+	ps := makePoolScaler(&fission.PoolScaling{MinPoolsize: 2, MaxPoolsize: 10, Cooldown: 60})
+	now := time.Now()
+	interval := 10 * time.Second
+
+	// no demand: the pool is brought to its minimum size
+	size, ok := ps.scale(3, interval, now)
+	if !ok || size != 2 {
+		log.Panicf("expected scale down to 2, got %v (%v)", size, ok)
+	}
+
+	// a burst of 10 pods in 10s scales the pool up right away
+	for i := 0; i < 10; i++ {
+		ps.podChosen()
+	}
+	now = now.Add(interval)
+	size, ok = ps.scale(2, interval, now)
+	if !ok || size != 9 {
+		log.Panicf("expected scale up to 9, got %v (%v)", size, ok)
+	}
+
+	// demand falls off, but the pool isn't scaled down during the cooldown
+	now = now.Add(interval)
+	size, ok = ps.scale(9, interval, now)
+	if ok || size != 9 {
+		log.Panicf("expected no scaling during cooldown, got %v (%v)", size, ok)
+	}
+
+	// after the cooldown the pool follows the smoothed demand down
+	now = now.Add(time.Minute)
+	size, ok = ps.scale(9, interval, now)
+	if !ok || size >= 9 || size < 2 {
+		log.Panicf("expected scale down after cooldown, got %v (%v)", size, ok)
+	}
+
+	// the maximum size caps the pool
+	for i := 0; i < 100; i++ {
+		ps.podChosen()
+	}
+	size, ok = ps.scale(size, interval, now.Add(interval))
+	if !ok || size != 10 {
+		log.Panicf("expected scale up to the maximum of 10, got %v (%v)", size, ok)
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolmgr
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	k8s_err "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/fscache"
+)
+
+const (
+	// Function services are moved to the new pool one at a time, with
+	// this pause in between, so that the functions of the environment
+	// don't all cold start at once.
+	poolRolloutPause = 2 * time.Second
+
+	// How often the progress of a rollout is saved on the environment
+	poolRolloutStatusInterval = 15 * time.Second
+)
+
+// rolloutPool moves the functions specialized on an earlier version of
+// the environment to the pool of the current one, and then destroys the
+// old pool. Progress is recorded on the environment's status.
+func (gpm *GenericPoolManager) rolloutPool(oldPool *GenericPool, newPool *GenericPool) {
+	env := newPool.env
+	defer gpm.finishRollout(env)
+
+	log.Printf("[%v] Replacing pool of environment version %v with version %v",
+		env.Metadata.Name, oldPool.version, newPool.version)
+
+	fsvcs := staleFuncSvcs(gpm.fsCache.ListByExecutor(fission.ExecutorTypePoolmgr), env)
+	status := &fission.PoolRolloutStatus{
+		Status:    fission.RolloutStatusProgressing,
+		StartTime: metav1.Now(),
+		Total:     len(fsvcs),
+	}
+	gpm.recordPoolRollout(env, status)
+
+	// the functions start cold on the new pool, so wait for its pods
+	err := newPool.waitForReadyPod()
+	if err != nil {
+		log.Printf("[%v] Error waiting for the new pool: %v", env.Metadata.Name, err)
+	}
+
+	var lastErr error
+	lastRecord := time.Now()
+	for _, fsvc := range fsvcs {
+		latest, err := gpm.fissionClient.Environments(env.Metadata.Namespace).Get(env.Metadata.Name)
+		if err != nil {
+			if k8s_err.IsNotFound(err) {
+				// nothing left to move the functions to
+				break
+			}
+			lastErr = err
+			continue
+		}
+		// the new pool may have been destroyed since, if the
+		// environment's pool size went down to zero
+		pool, err := gpm.GetPool(latest)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		err = gpm.replaceFuncSvc(pool, fsvc)
+		if err != nil {
+			log.Printf("[%v] Error moving function %v to the new pool: %v",
+				env.Metadata.Name, fsvc.Function.Name, err)
+			lastErr = err
+		} else {
+			status.Replaced++
+		}
+
+		if time.Since(lastRecord) > poolRolloutStatusInterval {
+			gpm.recordPoolRollout(env, status)
+			lastRecord = time.Now()
+		}
+		time.Sleep(poolRolloutPause)
+	}
+
+	// Pods of functions that couldn't be moved were relabeled when they
+	// were specialized, so they keep serving until they're reaped.
+	err = oldPool.destroy()
+	if err != nil {
+		log.Printf("[%v] Error destroying old pool: %v", env.Metadata.Name, err)
+	}
+
+	status.Status = fission.RolloutStatusSucceeded
+	status.Message = ""
+	if lastErr != nil {
+		status.Status = fission.RolloutStatusFailed
+		status.Message = fmt.Sprintf("%v of %v functions not moved to the new pool: %v",
+			status.Total-status.Replaced, status.Total, lastErr)
+	}
+	gpm.recordPoolRollout(env, status)
+	log.Printf("[%v] Pool rollout %v, moved %v of %v functions",
+		env.Metadata.Name, status.Status, status.Replaced, status.Total)
+}
+
+// staleFuncSvcs returns the function services specialized on an earlier
+// version of the environment, ordered by function so that the pods of a
+// function are replaced in a row.
+func staleFuncSvcs(fsvcs []*fscache.FuncSvc, env *crd.Environment) []*fscache.FuncSvc {
+	version := crd.EnvironmentVersion(env)
+	stale := make([]*fscache.FuncSvc, 0)
+	for _, fsvc := range fsvcs {
+		if fsvc.Environment == nil || fsvc.Environment.Metadata.UID != env.Metadata.UID {
+			continue
+		}
+		if crd.EnvironmentVersion(fsvc.Environment) == version {
+			continue
+		}
+		stale = append(stale, fsvc)
+	}
+	sort.SliceStable(stale, func(i, j int) bool {
+		return stale[i].Function.UID < stale[j].Function.UID
+	})
+	return stale
+}
+
+// replaceFuncSvc specializes a pod of the pool for the function of the
+// function service, and drains the old one.
+func (gpm *GenericPoolManager) replaceFuncSvc(pool *GenericPool, old *fscache.FuncSvc) error {
+	shared := old.Environment.Spec.AllowedFunctionsPerContainer == fission.AllowedFunctionsPerContainerInfinite
+	drain := func() error {
+		if shared {
+			// the pod serves other functions too; it goes with the
+			// old pool
+			gpm.fsCache.DeleteEntry(old)
+			return nil
+		}
+		// the pod has its termination grace period to finish the
+		// requests in flight
+		return gpm.DeleteFuncSvc(old)
+	}
+
+	cached, err := gpm.fsCache.GetByAddress(old.Address)
+	if err != nil || cached.Name != old.Name {
+		// reaped since the rollout started
+		return nil
+	}
+
+	_, err = gpm.fissionClient.Functions(old.Function.Namespace).Get(old.Function.Name)
+	if err != nil {
+		if k8s_err.IsNotFound(err) {
+			return drain()
+		}
+		return err
+	}
+
+	fsvc, err := pool.specializeFuncSvc(old.Function)
+	if err != nil {
+		return err
+	}
+
+	if !shared && !pool.useSvc && !pool.useIstio {
+		// both pods serve the function until the old one is gone
+		err = gpm.fsCache.AddScaledOut(*fsvc)
+		if err != nil {
+			gpm.kubernetesClient.CoreV1().Pods(pool.namespace).Delete(fsvc.Name, nil)
+			return err
+		}
+		return drain()
+	}
+
+	err = drain()
+	if err != nil {
+		log.Printf("Error draining function service %v: %v", old.Name, err)
+	}
+	_, err = gpm.fsCache.Add(*fsvc)
+	return err
+}
+
+// recordPoolRollout saves the progress of the rollout on the status of
+// the environment.
+func (gpm *GenericPoolManager) recordPoolRollout(env *crd.Environment, status *fission.PoolRolloutStatus) {
+	status.Time = metav1.Now()
+	for i := 0; i < 3; i++ {
+		latest, err := gpm.fissionClient.Environments(env.Metadata.Namespace).Get(env.Metadata.Name)
+		if err != nil {
+			log.Printf("Error getting environment %v to record its pool rollout: %v", env.Metadata.Name, err)
+			return
+		}
+		if latest.Metadata.UID != env.Metadata.UID {
+			// deleted and created again
+			return
+		}
+		statusCopy := *status
+		latest.Status.PoolRollout = &statusCopy
+		_, err = gpm.fissionClient.Environments(latest.Metadata.Namespace).Update(latest)
+		if err == nil {
+			return
+		}
+		if !k8s_err.IsConflict(err) {
+			log.Printf("Error recording pool rollout of environment %v: %v", env.Metadata.Name, err)
+			return
+		}
+	}
+}
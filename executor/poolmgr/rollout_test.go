@@ -0,0 +1,67 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolmgr
+
+import (
+	"log"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/fscache"
+)
+
+func TestStaleFuncSvcs(t *testing.T) {
+	oldEnv := &crd.Environment{
+		Metadata: metav1.ObjectMeta{Name: "python", UID: "1234", ResourceVersion: "1"},
+		Spec:     fission.EnvironmentSpec{Runtime: fission.Runtime{Image: "python:1"}},
+	}
+	newEnv := *oldEnv
+	newEnv.Metadata.ResourceVersion = "2"
+	newEnv.Spec.Runtime.Image = "python:2"
+	otherEnv := &crd.Environment{
+		Metadata: metav1.ObjectMeta{Name: "go", UID: "5678"},
+	}
+
+	// status updates don't change the version of the environment
+	updated := newEnv
+	updated.Metadata.ResourceVersion = "3"
+	updated.Status.PoolRollout = &fission.PoolRolloutStatus{Status: fission.RolloutStatusProgressing}
+	if crd.EnvironmentVersion(&updated) != crd.EnvironmentVersion(&newEnv) {
+		log.Panicf("status update changed the environment version")
+	}
+	if crd.EnvironmentVersion(oldEnv) == crd.EnvironmentVersion(&newEnv) {
+		log.Panicf("image change didn't change the environment version")
+	}
+
+	fsvcs := []*fscache.FuncSvc{
+		{Name: "b-old", Function: &metav1.ObjectMeta{UID: "b"}, Environment: oldEnv},
+		{Name: "a-new", Function: &metav1.ObjectMeta{UID: "a"}, Environment: &newEnv},
+		{Name: "a-old", Function: &metav1.ObjectMeta{UID: "a"}, Environment: oldEnv},
+		{Name: "other", Function: &metav1.ObjectMeta{UID: "c"}, Environment: otherEnv},
+	}
+	stale := staleFuncSvcs(fsvcs, &updated)
+	if len(stale) != 2 || stale[0].Name != "a-old" || stale[1].Name != "b-old" {
+		names := make([]string, 0, len(stale))
+		for _, fsvc := range stale {
+			names = append(names, fsvc.Name)
+		}
+		log.Panicf("expected a-old and b-old to be stale, got %v", names)
+	}
+}
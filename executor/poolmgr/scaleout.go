@@ -0,0 +1,341 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolmgr
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	k8s_err "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/fscache"
+)
+
+const (
+	scaleOutInterval = 10 * time.Second
+
+	// Routers report every few seconds; a router that stopped reporting
+	// has no requests in flight.
+	scaleOutReportTTL = 15 * time.Second
+
+	// Routers pick up the addresses of new pods when their cache entry
+	// for the function expires, after a minute. Until then the new pod
+	// gets no requests, so don't add another one.
+	scaleOutCooldown = time.Minute
+
+	// A function must have needed fewer pods for this long before one
+	// is removed.
+	scaleInWindow = 5 * time.Minute
+)
+
+type (
+	// scaleOutTracker keeps the in-flight requests reported by routers
+	// for each specialized pod, and decides when a function needs
+	// another pod, or can do with one less.
+	scaleOutTracker struct {
+		lock      sync.Mutex
+		functions map[types.UID]*functionLoad
+	}
+
+	functionLoad struct {
+		fsvc         *fscache.FuncSvc
+		reports      map[string]map[string]concurrencyReport // address -> router -> latest report
+		lastScaleOut time.Time
+		underSince   time.Time // since when the function could do with one pod less
+		scaling      bool      // a pod is being specialized
+	}
+
+	concurrencyReport struct {
+		concurrency float64
+		time        time.Time
+	}
+)
+
+func makeScaleOutTracker() *scaleOutTracker {
+	return &scaleOutTracker{
+		functions: make(map[types.UID]*functionLoad),
+	}
+}
+
+func (st *scaleOutTracker) observe(fsvc *fscache.FuncSvc, reporter string, concurrency float64, now time.Time) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	fl, ok := st.functions[fsvc.Function.UID]
+	if !ok {
+		fl = &functionLoad{
+			reports: make(map[string]map[string]concurrencyReport),
+		}
+		st.functions[fsvc.Function.UID] = fl
+	}
+	fl.fsvc = fsvc
+	if fl.reports[fsvc.Address] == nil {
+		fl.reports[fsvc.Address] = make(map[string]concurrencyReport)
+	}
+	fl.reports[fsvc.Address][reporter] = concurrencyReport{concurrency: concurrency, time: now}
+}
+
+func (st *scaleOutTracker) forget(uid types.UID) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	delete(st.functions, uid)
+}
+
+// list returns a function service of each function that concurrency was
+// reported for.
+func (st *scaleOutTracker) list() []*fscache.FuncSvc {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	fsvcs := make([]*fscache.FuncSvc, 0, len(st.functions))
+	for _, fl := range st.functions {
+		fsvcs = append(fsvcs, fl.fsvc)
+	}
+	return fsvcs
+}
+
+// setScaling marks a function as having a pod specialized for it. It
+// returns false if one already is.
+func (st *scaleOutTracker) setScaling(uid types.UID, scaling bool) bool {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	fl, ok := st.functions[uid]
+	if !ok {
+		return false
+	}
+	if scaling && fl.scaling {
+		return false
+	}
+	fl.scaling = scaling
+	return true
+}
+
+// decide looks at the load of the function's pods, given by address with
+// the function's first pod first. It returns whether another pod should
+// be added, or the address of a pod to remove, if any.
+func (st *scaleOutTracker) decide(uid types.UID, targetConcurrency int, maxPods int, addresses []string, now time.Time) (bool, string) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	fl, ok := st.functions[uid]
+	if !ok || len(addresses) == 0 {
+		return false, ""
+	}
+
+	// add up the reports of all routers, for the pods the function
+	// still has
+	load := make(map[string]float64)
+	for _, address := range addresses {
+		load[address] = 0
+	}
+	for address, reports := range fl.reports {
+		if _, ok := load[address]; !ok {
+			delete(fl.reports, address)
+			continue
+		}
+		for reporter, report := range reports {
+			if now.Sub(report.time) > scaleOutReportTTL {
+				delete(reports, reporter)
+				continue
+			}
+			load[address] += report.concurrency
+		}
+	}
+
+	n := len(addresses)
+	if n > maxPods {
+		// the cap was lowered
+		fl.underSince = time.Time{}
+		return false, leastLoaded(addresses[1:], load)
+	}
+
+	saturated := true
+	total := 0.0
+	for _, address := range addresses {
+		if load[address] < float64(targetConcurrency) {
+			saturated = false
+		}
+		total += load[address]
+	}
+
+	if saturated && n < maxPods {
+		fl.underSince = time.Time{}
+		if fl.scaling || now.Sub(fl.lastScaleOut) < scaleOutCooldown {
+			return false, ""
+		}
+		fl.lastScaleOut = now
+		return true, ""
+	}
+
+	if n > 1 && total <= float64(targetConcurrency*(n-1)) {
+		if fl.underSince.IsZero() {
+			fl.underSince = now
+			return false, ""
+		}
+		if now.Sub(fl.underSince) >= scaleInWindow {
+			// restart the window for the next pod
+			fl.underSince = now
+			return false, leastLoaded(addresses[1:], load)
+		}
+		return false, ""
+	}
+	fl.underSince = time.Time{}
+	return false, ""
+}
+
+func leastLoaded(addresses []string, load map[string]float64) string {
+	least := ""
+	for _, address := range addresses {
+		if len(least) == 0 || load[address] < load[least] {
+			least = address
+		}
+	}
+	return least
+}
+
+// ObserveConcurrency records the concurrency that a router reported for
+// the specialized pod.
+func (gpm *GenericPoolManager) ObserveConcurrency(fsvc *fscache.FuncSvc, reporter string, concurrency float64) {
+	gpm.scaleOut.observe(fsvc, reporter, concurrency, time.Now())
+}
+
+func (gpm *GenericPoolManager) runScaleOut(ctx context.Context) {
+	ticker := time.NewTicker(scaleOutInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, fsvc := range gpm.scaleOut.list() {
+				err := gpm.scaleOutFunction(fsvc, now)
+				if err != nil {
+					log.Printf("Error scaling out function %v: %v", fsvc.Function.Name, err)
+				}
+			}
+		}
+	}
+}
+
+// scaleOutFunction specializes another pod for the function when all its
+// pods are saturated, up to the function's MaxScale, and removes pods it
+// no longer needs. Only functions with a TargetConcurrency are scaled out;
+// others are scaled back in to one pod after a warm window.
+func (gpm *GenericPoolManager) scaleOutFunction(fsvc *fscache.FuncSvc, now time.Time) error {
+	fn, err := gpm.fissionClient.Functions(fsvc.Function.Namespace).Get(fsvc.Function.Name)
+	if err != nil {
+		if k8s_err.IsNotFound(err) {
+			gpm.scaleOut.forget(fsvc.Function.UID)
+			return nil
+		}
+		return err
+	}
+
+	fsvcs := gpm.fsCache.ListByFunction(fsvc.Function)
+	es := &fn.Spec.InvokeStrategy.ExecutionStrategy
+	maxPods := gpm.scaleOutLimit(es)
+	if len(fsvcs) == 0 || (maxPods == 1 && len(fsvcs) == 1) {
+		gpm.scaleOut.forget(fsvc.Function.UID)
+		return nil
+	}
+
+	addresses := make([]string, 0, len(fsvcs))
+	for _, f := range fsvcs {
+		addresses = append(addresses, f.Address)
+	}
+
+	add, remove := gpm.scaleOut.decide(fsvc.Function.UID, es.TargetConcurrency, maxPods, addresses, now)
+	if add && gpm.scaleOut.setScaling(fsvc.Function.UID, true) {
+		go func() {
+			defer gpm.scaleOut.setScaling(fsvc.Function.UID, false)
+			err := gpm.addFuncSvc(fsvcs[0].Function, fsvcs[0].Environment)
+			if err != nil {
+				log.Printf("Error scaling out function %v: %v", fsvc.Function.Name, err)
+			}
+		}()
+	}
+	// the warm schedule may need the pod
+	if len(remove) > 0 && len(fsvcs) > es.WarmInstances(now) {
+		for _, f := range fsvcs {
+			if f.Address == remove {
+				log.Printf("Scaling in function %v, removing pod %v", fn.Metadata.Name, f.Name)
+				return gpm.DeleteFuncSvc(f)
+			}
+		}
+	}
+	return nil
+}
+
+// maxPods returns the number of pods that may be specialized for a
+// function, by scale-out or its warm schedule.
+func (gpm *GenericPoolManager) maxPods(es *fission.ExecutionStrategy) int {
+	if es.MaxScale < 1 || gpm.enableIstio {
+		return 1
+	}
+	return es.MaxScale
+}
+
+// scaleOutLimit returns the number of pods a function may be scaled out
+// to on load. Scaling out is opt-in, by setting a TargetConcurrency.
+func (gpm *GenericPoolManager) scaleOutLimit(es *fission.ExecutionStrategy) int {
+	if es.TargetConcurrency <= 0 {
+		return 1
+	}
+	return gpm.maxPods(es)
+}
+
+// KeepWarm specializes pods for the function until it has the given
+// number of them, up to its MaxScale. Pods aren't removed here; scale-in
+// leaves the function at least as many as its warm schedule asks for.
+func (gpm *GenericPoolManager) KeepWarm(fn *crd.Function, instances int) error {
+	maxPods := gpm.maxPods(&fn.Spec.InvokeStrategy.ExecutionStrategy)
+	if instances > maxPods {
+		instances = maxPods
+	}
+	fsvcs := gpm.fsCache.ListByFunction(&fn.Metadata)
+	if len(fsvcs) == 0 {
+		return nil
+	}
+	for i := len(fsvcs); i < instances; i++ {
+		err := gpm.addFuncSvc(fsvcs[0].Function, fsvcs[0].Environment)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addFuncSvc specializes another pod for the function.
+func (gpm *GenericPoolManager) addFuncSvc(m *metav1.ObjectMeta, env *crd.Environment) error {
+	pool, err := gpm.GetPool(env)
+	if err != nil {
+		return err
+	}
+	fsvc, err := pool.scaleOutFuncSvc(m)
+	if err != nil {
+		return err
+	}
+	log.Printf("Scaled out function %v to pod %v", m.Name, fsvc.Name)
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolmgr
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/executor/fscache"
+)
+
+func TestScaleOutTracker(t *testing.T) {
+	st := makeScaleOutTracker()
+	fn := &metav1.ObjectMeta{Name: "foo", UID: "1234"}
+	podA := &fscache.FuncSvc{Function: fn, Address: "10.0.0.1:8888"}
+	podB := &fscache.FuncSvc{Function: fn, Address: "10.0.0.2:8888"}
+	now := time.Now()
+
+	// unknown functions are left alone
+	if add, remove := st.decide("5678", 4, 3, []string{podA.Address}, now); add || len(remove) > 0 {
+		log.Panicf("expected no decision for unknown function")
+	}
+
+	// a busy pod that isn't saturated yet
+	st.observe(podA, "router-a", 3, now)
+	if add, _ := st.decide("1234", 4, 3, []string{podA.Address}, now); add {
+		log.Panicf("expected no scale out below target concurrency")
+	}
+
+	// saturated across two routers
+	st.observe(podA, "router-b", 2, now)
+	if add, _ := st.decide("1234", 4, 3, []string{podA.Address}, now); !add {
+		log.Panicf("expected scale out of saturated pod")
+	}
+
+	// both pods saturated, but the routers may not know the new pod yet
+	now = now.Add(scaleOutInterval)
+	st.observe(podA, "router-a", 5, now)
+	st.observe(podB, "router-a", 5, now)
+	addresses := []string{podA.Address, podB.Address}
+	if add, _ := st.decide("1234", 4, 3, addresses, now); add {
+		log.Panicf("expected no scale out within the cooldown")
+	}
+	now = now.Add(scaleOutCooldown)
+	st.observe(podA, "router-a", 5, now)
+	st.observe(podB, "router-a", 5, now)
+	if add, _ := st.decide("1234", 4, 2, addresses, now); add {
+		log.Panicf("expected no scale out beyond the cap")
+	}
+
+	// the load drops and fits in one pod; the scaled out pod is removed
+	// once the scale in window has passed
+	now = now.Add(scaleOutInterval)
+	st.observe(podA, "router-a", 1, now)
+	st.observe(podB, "router-a", 1, now)
+	if _, remove := st.decide("1234", 4, 3, addresses, now); len(remove) > 0 {
+		log.Panicf("expected no scale in right away")
+	}
+	now = now.Add(scaleInWindow)
+	st.observe(podA, "router-a", 1, now)
+	st.observe(podB, "router-a", 1, now)
+	if _, remove := st.decide("1234", 4, 3, addresses, now); remove != podB.Address {
+		log.Panicf("expected scale in of %v, got %q", podB.Address, remove)
+	}
+
+	// only one specialization at a time
+	if !st.setScaling("1234", true) || st.setScaling("1234", true) {
+		log.Panicf("expected a single specialization for the function")
+	}
+	st.setScaling("1234", false)
+
+	st.forget("1234")
+	if len(st.list()) != 0 {
+		log.Panicf("expected no functions after forget")
+	}
+}
+
+func TestScaleOutLimit(t *testing.T) {
+	gpm := &GenericPoolManager{}
+
+	// scaling out is opt-in; MaxScale alone doesn't do it
+	es := &fission.ExecutionStrategy{ExecutorType: fission.ExecutorTypePoolmgr, MaxScale: 5}
+	if n := gpm.scaleOutLimit(es); n != 1 {
+		log.Panicf("expected no scale-out without a target concurrency, got %v pods", n)
+	}
+	if n := gpm.maxPods(es); n != 5 {
+		log.Panicf("expected warm schedules to go up to MaxScale, got %v pods", n)
+	}
+
+	es.TargetConcurrency = 4
+	if n := gpm.scaleOutLimit(es); n != 5 {
+		log.Panicf("expected scale-out up to MaxScale, got %v pods", n)
+	}
+}
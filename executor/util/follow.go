@@ -0,0 +1,83 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	k8sCache "k8s.io/client-go/tools/cache"
+
+	"github.com/fission/fission/crd"
+)
+
+const (
+	functionUIDIndex = "uid"
+
+	// How often followers go over the objects they watch again, from
+	// their informers' stores, e.g. to pick up objects seen before
+	// their function was
+	FollowerResyncPeriod = time.Minute
+)
+
+// MakeFunctionIndex returns an informer on the functions of all
+// namespaces, whose store is indexed by function UID.
+func MakeFunctionIndex(crdClient *rest.RESTClient, handlers k8sCache.ResourceEventHandlerFuncs) (k8sCache.Indexer, k8sCache.Controller) {
+	lw := k8sCache.NewListWatchFromClient(crdClient, "functions", metav1.NamespaceAll, fields.Everything())
+	return k8sCache.NewIndexerInformer(lw, &crd.Function{}, 0, handlers, k8sCache.Indexers{
+		functionUIDIndex: func(obj interface{}) ([]string, error) {
+			return []string{string(obj.(*crd.Function).Metadata.UID)}, nil
+		},
+	})
+}
+
+// FunctionByUID looks a function up in an index made by
+// MakeFunctionIndex.
+func FunctionByUID(index k8sCache.Indexer, uid types.UID) (*crd.Function, bool) {
+	objs, err := index.ByIndex(functionUIDIndex, string(uid))
+	if err != nil || len(objs) == 0 {
+		return nil, false
+	}
+	return objs[0].(*crd.Function), true
+}
+
+// DeletedObject returns the object of a delete notification, which is
+// wrapped when the informer missed the deletion.
+func DeletedObject(obj interface{}) interface{} {
+	if tombstone, ok := obj.(k8sCache.DeletedFinalStateUnknown); ok {
+		return tombstone.Obj
+	}
+	return obj
+}
+
+// RunControllers runs the controllers until stop is closed, and returns
+// once they're all done.
+func RunControllers(stop <-chan struct{}, controllers ...k8sCache.Controller) {
+	var wg sync.WaitGroup
+	for _, c := range controllers {
+		wg.Add(1)
+		go func(c k8sCache.Controller) {
+			defer wg.Done()
+			c.Run(stop)
+		}(c)
+	}
+	wg.Wait()
+}
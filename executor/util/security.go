@@ -0,0 +1,84 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	// The fetcher reads packages, secrets and configmaps as this
+	// service account.
+	FetcherServiceAccount = "fission-fetcher"
+
+	fetcherTokenVolume = "fetcher-token"
+
+	// where in-cluster clients find the service account token
+	serviceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// MountFetcherToken keeps the fetcher of a pod that runs as another
+// service account working: the token of the fetcher's service account is
+// mounted into the fetcher container, in place of the pod's token.
+func MountFetcherToken(kubeClient *kubernetes.Clientset, namespace string, podSpec *v1.PodSpec) error {
+	if len(podSpec.ServiceAccountName) == 0 || podSpec.ServiceAccountName == FetcherServiceAccount {
+		return nil
+	}
+
+	sa, err := kubeClient.CoreV1().ServiceAccounts(namespace).Get(FetcherServiceAccount, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	tokenSecret := ""
+	for _, ref := range sa.Secrets {
+		secret, err := kubeClient.CoreV1().Secrets(namespace).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if secret.Type == v1.SecretTypeServiceAccountToken {
+			tokenSecret = secret.ObjectMeta.Name
+			break
+		}
+	}
+	if len(tokenSecret) == 0 {
+		return fmt.Errorf("service account %v has no token", FetcherServiceAccount)
+	}
+
+	podSpec.Volumes = append(podSpec.Volumes, v1.Volume{
+		Name: fetcherTokenVolume,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{
+				SecretName: tokenSecret,
+			},
+		},
+	})
+	for i := range podSpec.Containers {
+		if podSpec.Containers[i].Name != "fetcher" {
+			continue
+		}
+		podSpec.Containers[i].VolumeMounts = append(podSpec.Containers[i].VolumeMounts, v1.VolumeMount{
+			Name:      fetcherTokenVolume,
+			MountPath: serviceAccountTokenPath,
+			ReadOnly:  true,
+		})
+	}
+	return nil
+}
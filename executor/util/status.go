@@ -0,0 +1,163 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+)
+
+// ResponseOf returns the response body carried by an error from the
+// fetcher or an environment server, or the error message.
+func ResponseOf(err error) string {
+	if fe, ok := err.(fission.Error); ok {
+		return fe.Message
+	}
+	return err.Error()
+}
+
+const (
+	// A function's specializations with the same outcome as the last
+	// one recorded are recorded at most this often.
+	specializationRecordInterval = 30 * time.Second
+)
+
+// specializationRecords rate-limits the specialization events of each
+// function; a function whose pods keep failing to specialize would
+// otherwise flood the API server with them.
+var specializationRecords = makeRecordLimiter(specializationRecordInterval)
+
+type (
+	recordLimiter struct {
+		lock     sync.Mutex
+		interval time.Duration
+		last     map[types.UID]lastRecord
+	}
+	lastRecord struct {
+		succeeded bool
+		time      time.Time
+	}
+)
+
+func makeRecordLimiter(interval time.Duration) *recordLimiter {
+	return &recordLimiter{
+		interval: interval,
+		last:     make(map[types.UID]lastRecord),
+	}
+}
+
+// allow checks whether a specialization of the function with the given
+// outcome should be recorded. Changes of the outcome always are.
+func (l *recordLimiter) allow(uid types.UID, succeeded bool, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	last, ok := l.last[uid]
+	if ok && last.succeeded == succeeded && now.Sub(last.time) < l.interval {
+		return false
+	}
+	for u, r := range l.last {
+		if now.Sub(r.time) >= l.interval {
+			delete(l.last, u)
+		}
+	}
+	l.last[uid] = lastRecord{succeeded: succeeded, time: now}
+	return true
+}
+
+// RecordSpecialization records an attempt to specialize a pod for the
+// function as an event on the function, which the controller reads the
+// function's status from. The function itself isn't updated, since that
+// would change the key its services are cached by.
+func RecordSpecialization(kubeClient *kubernetes.Clientset, fn *crd.Function, status *fission.SpecializationStatus) {
+	status.Time = metav1.Now()
+	if !specializationRecords.allow(fn.Metadata.UID, status.Succeeded, status.Time.Time) {
+		return
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		log.Printf("Error encoding specialization status of function %v: %v", fn.Metadata.Name, err)
+		return
+	}
+	annotations := map[string]string{
+		fission.ANNOTATION_SPECIALIZATION_STATUS: string(data),
+	}
+
+	if status.Succeeded {
+		message := "Specialized the function's pods"
+		if len(status.PodName) > 0 {
+			message = fmt.Sprintf("Specialized pod %v", status.PodName)
+		}
+		recordFunctionEvent(kubeClient, fn, v1.EventTypeNormal, "Specialized", message, annotations)
+		return
+	}
+	message := status.Error
+	if len(status.PodName) > 0 {
+		message = fmt.Sprintf("Failed to specialize pod %v: %v", status.PodName, status.Error)
+	}
+	recordFunctionEvent(kubeClient, fn, v1.EventTypeWarning, "SpecializationFailed", message, annotations)
+}
+
+// RecordFunctionEvent creates a Kubernetes event on the function, shown
+// by kubectl describe.
+func RecordFunctionEvent(kubeClient *kubernetes.Clientset, fn *crd.Function, eventType string, reason string, message string) {
+	recordFunctionEvent(kubeClient, fn, eventType, reason, message, nil)
+}
+
+func recordFunctionEvent(kubeClient *kubernetes.Clientset, fn *crd.Function, eventType string, reason string, message string,
+	annotations map[string]string) {
+
+	now := metav1.Now()
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fn.Metadata.Name + ".",
+			Namespace:    fn.Metadata.Namespace,
+			Annotations:  annotations,
+		},
+		InvolvedObject: v1.ObjectReference{
+			Kind:            "Function",
+			APIVersion:      "fission.io/v1",
+			Name:            fn.Metadata.Name,
+			Namespace:       fn.Metadata.Namespace,
+			UID:             fn.Metadata.UID,
+			ResourceVersion: fn.Metadata.ResourceVersion,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         v1.EventSource{Component: "fission-executor"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err := kubeClient.CoreV1().Events(fn.Metadata.Namespace).Create(event)
+	if err != nil {
+		log.Printf("Error creating event for function %v: %v", fn.Metadata.Name, err)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestRecordLimiter(t *testing.T) {
+	l := makeRecordLimiter(time.Minute)
+	now := time.Now()
+
+	if !l.allow("a", false, now) {
+		log.Panicf("expected the first failure to be recorded")
+	}
+	if l.allow("a", false, now.Add(time.Second)) {
+		log.Panicf("expected a repeated failure to be rate-limited")
+	}
+	if !l.allow("b", false, now.Add(time.Second)) {
+		log.Panicf("expected another function's failure to be recorded")
+	}
+	if !l.allow("a", true, now.Add(2*time.Second)) {
+		log.Panicf("expected a success after a failure to be recorded")
+	}
+	if l.allow("a", true, now.Add(3*time.Second)) {
+		log.Panicf("expected a repeated success to be rate-limited")
+	}
+	if !l.allow("a", true, now.Add(2*time.Minute)) {
+		log.Panicf("expected a success to be recorded again after the interval")
+	}
+	if len(l.last) != 1 {
+		log.Panicf("expected stale records to be dropped, got %v", len(l.last))
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/executortype"
+)
+
+// How often the warm schedules of functions are checked. Windows open at
+// most this late.
+const warmScheduleInterval = 30 * time.Second
+
+// keepWarm follows the warm schedules of functions: while a window is
+// open, the function gets a function service, and its backend keeps it
+// at the window's number of instances.
+func (executor *Executor) keepWarm(ctx context.Context) {
+	ticker := time.NewTicker(warmScheduleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			fns, err := executor.fissionClient.Functions(metav1.NamespaceAll).List(metav1.ListOptions{})
+			if err != nil {
+				log.Printf("Error listing functions to keep warm: %v", err)
+				continue
+			}
+			for i := range fns.Items {
+				fn := &fns.Items[i]
+				if len(fn.Spec.InvokeStrategy.ExecutionStrategy.WarmSchedule) == 0 {
+					continue
+				}
+				err := executor.keepFunctionWarm(fn, now)
+				if err != nil {
+					log.Printf("Error keeping function %v warm: %v", fn.Metadata.Name, err)
+				}
+			}
+		}
+	}
+}
+
+func (executor *Executor) keepFunctionWarm(fn *crd.Function, now time.Time) error {
+	es := &fn.Spec.InvokeStrategy.ExecutionStrategy
+	instances := es.WarmInstances(now)
+	if instances > 0 {
+		_, err := executor.getServiceForFunction(&fn.Metadata)
+		if err != nil {
+			return err
+		}
+	}
+
+	executorType := es.ExecutorType
+	if len(executorType) == 0 {
+		executorType = fission.ExecutorTypePoolmgr
+	}
+	et, err := executor.getExecutorType(executorType)
+	if err != nil {
+		return err
+	}
+	keeper, ok := et.(executortype.WarmKeeper)
+	if !ok {
+		return nil
+	}
+	return keeper.KeepWarm(fn, instances)
+}
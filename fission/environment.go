@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/urfave/cli"
@@ -47,7 +48,8 @@ func envCreate(c *cli.Context) error {
 	}
 
 	envImg := c.String("image")
-	if len(envImg) == 0 {
+	envLocalCommand := strings.Fields(c.String("localcommand"))
+	if len(envImg) == 0 && len(envLocalCommand) == 0 {
 		fatal("Need an image, use --image.")
 	}
 
@@ -76,6 +78,7 @@ func envCreate(c *cli.Context) error {
 	}
 
 	resourceReq := getResourceReq(c)
+	poolScaling := getPoolScaling(c, nil)
 
 	env := &crd.Environment{
 		Metadata: metav1.ObjectMeta{
@@ -85,13 +88,15 @@ func envCreate(c *cli.Context) error {
 		Spec: fission.EnvironmentSpec{
 			Version: envVersion,
 			Runtime: fission.Runtime{
-				Image: envImg,
+				Image:        envImg,
+				LocalCommand: envLocalCommand,
 			},
 			Builder: fission.Builder{
 				Image:   envBuilderImg,
 				Command: envBuildCmd,
 			},
 			Poolsize:                     poolsize,
+			PoolScaling:                  poolScaling,
 			Resources:                    resourceReq,
 			AllowAccessToExternalNetwork: envExternalNetwork,
 			TerminationGracePeriod:       envGracePeriod,
@@ -133,6 +138,14 @@ func envGet(c *cli.Context) error {
 	fmt.Fprintf(w, "%v\t%v\t%v\n",
 		env.Metadata.Name, env.Metadata.UID, env.Spec.Runtime.Image)
 	w.Flush()
+
+	if rollout := env.Status.PoolRollout; rollout != nil {
+		fmt.Printf("\nPool rollout: %v, %v of %v functions moved (started %v, updated %v)\n",
+			rollout.Status, rollout.Replaced, rollout.Total, rollout.StartTime, rollout.Time)
+		if len(rollout.Message) > 0 {
+			fmt.Printf("Message: %v\n", rollout.Message)
+		}
+	}
 	return nil
 }
 
@@ -149,7 +162,8 @@ func envUpdate(c *cli.Context) error {
 	envBuildCmd := c.String("buildcmd")
 	envExternalNetwork := c.Bool("externalnetwork")
 
-	if len(envImg) == 0 && len(envBuilderImg) == 0 && len(envBuildCmd) == 0 {
+	if len(envImg) == 0 && len(envBuilderImg) == 0 && len(envBuildCmd) == 0 &&
+		!c.IsSet("minpoolsize") && !c.IsSet("maxpoolsize") {
 		fatal("Need --image to specify env image, or use --builder to specify env builder, or use --buildcmd to specify new build command.")
 	}
 
@@ -178,6 +192,8 @@ func envUpdate(c *cli.Context) error {
 		env.Spec.Poolsize = c.Int("poolsize")
 	}
 
+	env.Spec.PoolScaling = getPoolScaling(c, env.Spec.PoolScaling)
+
 	if c.IsSet("period") {
 		env.Spec.TerminationGracePeriod = c.Int64("period")
 	}
@@ -230,6 +246,29 @@ func envList(c *cli.Context) error {
 	return nil
 }
 
+// getPoolScaling applies the --minpoolsize and --maxpoolsize flags to the
+// existing adaptive pool sizing settings, if any.
+func getPoolScaling(c *cli.Context, existing *fission.PoolScaling) *fission.PoolScaling {
+	if !c.IsSet("minpoolsize") && !c.IsSet("maxpoolsize") {
+		return existing
+	}
+
+	poolScaling := &fission.PoolScaling{}
+	if existing != nil {
+		*poolScaling = *existing
+	}
+	if c.IsSet("minpoolsize") {
+		poolScaling.MinPoolsize = c.Int("minpoolsize")
+	}
+	if c.IsSet("maxpoolsize") {
+		poolScaling.MaxPoolsize = c.Int("maxpoolsize")
+	}
+	if poolScaling.MinPoolsize < 1 || poolScaling.MaxPoolsize < poolScaling.MinPoolsize {
+		fatal("Adaptive pool sizing needs --minpoolsize of at least 1 and --maxpoolsize no less than --minpoolsize")
+	}
+	return poolScaling
+}
+
 func getResourceReq(c *cli.Context) v1.ResourceRequirements {
 	if c.IsSet("mincpu") || c.IsSet("maxcpu") || c.IsSet("minmemory") || c.IsSet("maxmemory") {
 		mincpu := c.Int("mincpu")
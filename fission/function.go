@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -33,6 +34,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/fission/fission"
+	"github.com/fission/fission/controller/client"
 	"github.com/fission/fission/crd"
 	"github.com/fission/fission/fission/logdb"
 )
@@ -65,7 +67,31 @@ func printPodLogs(c *cli.Context) error {
 	return nil
 }
 
-func getInvokeStrategy(minScale int, maxScale int, executorType string, targetcpu int) fission.InvokeStrategy {
+// getExecutorType checks that the executor runs the given executor type,
+// which defaults to poolmgr.
+func getExecutorType(client *client.Client, executorType string) fission.ExecutorType {
+	switch executorType {
+	case "":
+		return fission.ExecutorTypePoolmgr
+	case fission.ExecutorTypePoolmgr, fission.ExecutorTypeNewdeploy, fission.ExecutorTypeLocal:
+		return fission.ExecutorType(executorType)
+	}
+
+	// executor types registered by executor plugins
+	registered, err := client.ExecutorTypeList()
+	checkErr(err, "list the executor types")
+	names := make([]string, 0, len(registered))
+	for _, name := range registered {
+		if string(name) == executorType {
+			return name
+		}
+		names = append(names, fmt.Sprintf("'%v'", name))
+	}
+	fatal(fmt.Sprintf("Executor type must be one of %v, defaults to 'poolmgr'", strings.Join(names, ", ")))
+	return ""
+}
+
+func getInvokeStrategy(minScale int, maxScale int, fnExecutor fission.ExecutorType, targetcpu int) fission.InvokeStrategy {
 
 	if maxScale == 0 {
 		maxScale = 1
@@ -75,18 +101,6 @@ func getInvokeStrategy(minScale int, maxScale int, executorType string, targetcp
 		fatal("Maxscale must be higher than or equal to minscale")
 	}
 
-	var fnExecutor fission.ExecutorType
-	switch executorType {
-	case "":
-		fnExecutor = fission.ExecutorTypePoolmgr
-	case fission.ExecutorTypePoolmgr:
-		fnExecutor = fission.ExecutorTypePoolmgr
-	case fission.ExecutorTypeNewdeploy:
-		fnExecutor = fission.ExecutorTypeNewdeploy
-	default:
-		fatal("Executor type must be one of 'poolmgr' or 'newdeploy', defaults to 'poolmgr'")
-	}
-
 	// Right now a simple single case strategy implementation
 	// This will potentially get more sophisticated once we have more strategies in place
 	strategy := fission.InvokeStrategy{
@@ -114,6 +128,52 @@ func getTargetCPU(c *cli.Context) int {
 	return targetCPU
 }
 
+func getTargetConcurrency(c *cli.Context) int {
+	targetConcurrency := c.Int("targetconcurrency")
+	if targetConcurrency < 0 {
+		fatal("Target concurrency must be greater or equal to 0")
+	}
+	return targetConcurrency
+}
+
+// getIdleTimeout returns the --idletimeout flag, or nil if it isn't set.
+func getIdleTimeout(c *cli.Context) *int {
+	if !c.IsSet("idletimeout") {
+		return nil
+	}
+	idleTimeout := c.Int("idletimeout")
+	if idleTimeout < -1 {
+		fatal("Idle timeout must be -1, 0 or a number of seconds")
+	}
+	return &idleTimeout
+}
+
+// getWarmSchedule parses the --warm flags, each a window of the form
+// "<cron>;<duration>;<instances>".
+func getWarmSchedule(c *cli.Context) []fission.WarmWindow {
+	var schedule []fission.WarmWindow
+	for _, w := range c.StringSlice("warm") {
+		parts := strings.Split(w, ";")
+		if len(parts) != 3 {
+			fatal(fmt.Sprintf("Warm window '%v' must be of the form '<cron>;<duration>;<instances>'", w))
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || duration < time.Second {
+			fatal(fmt.Sprintf("Warm window '%v' has an invalid duration", w))
+		}
+		instances, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err != nil || instances < 1 {
+			fatal(fmt.Sprintf("Warm window '%v' must keep at least 1 instance", w))
+		}
+		schedule = append(schedule, fission.WarmWindow{
+			Cron:         strings.TrimSpace(parts[0]),
+			Duration:     int(duration.Seconds()),
+			MinInstances: instances,
+		})
+	}
+	return schedule
+}
+
 func fnCreate(c *cli.Context) error {
 	client := getClient(c.GlobalString("server"))
 
@@ -195,7 +255,10 @@ func fnCreate(c *cli.Context) error {
 		pkgMetadata = createPackage(client, envName, srcArchiveName, deployArchiveName, buildcmd, specFile)
 	}
 
-	invokeStrategy := getInvokeStrategy(c.Int("minscale"), c.Int("maxscale"), c.String("executortype"), getTargetCPU(c))
+	invokeStrategy := getInvokeStrategy(c.Int("minscale"), c.Int("maxscale"), getExecutorType(client, c.String("executortype")), getTargetCPU(c))
+	invokeStrategy.ExecutionStrategy.IdleTimeout = getIdleTimeout(c)
+	invokeStrategy.ExecutionStrategy.TargetConcurrency = getTargetConcurrency(c)
+	invokeStrategy.ExecutionStrategy.WarmSchedule = getWarmSchedule(c)
 	resourceReq := getResourceReq(c)
 	if (c.IsSet("mincpu") || c.IsSet("maxcpu") || c.IsSet("minmemory") || c.IsSet("maxmemory")) &&
 		invokeStrategy.ExecutionStrategy.ExecutorType == fission.ExecutorTypePoolmgr {
@@ -345,6 +408,28 @@ func fnGetMeta(c *cli.Context) error {
 	fmt.Fprintf(w, "%v\t%v\t%v\n",
 		f.Metadata.Name, f.Metadata.UID, f.Spec.Environment.Name)
 	w.Flush()
+
+	if status := f.Status.Specialization; status != nil {
+		fmt.Printf("\nLast specialization: ")
+		if status.Succeeded {
+			fmt.Printf("succeeded")
+		} else {
+			fmt.Printf("failed")
+		}
+		fmt.Printf(" at %v (%v)\n", status.Time, status.ExecutorType)
+		if len(status.PodName) > 0 {
+			fmt.Printf("Pod: %v\n", status.PodName)
+		}
+		if len(status.Error) > 0 {
+			fmt.Printf("Error: %v\n", status.Error)
+		}
+		if len(status.FetcherResponse) > 0 {
+			fmt.Printf("Fetcher response:\n%v\n", status.FetcherResponse)
+		}
+		if len(status.SpecializeResponse) > 0 {
+			fmt.Printf("Specialize response:\n%v\n", status.SpecializeResponse)
+		}
+	}
 	return err
 }
 
@@ -475,6 +560,18 @@ func fnUpdate(c *cli.Context) error {
 
 	function.Spec.InvokeStrategy.ExecutionStrategy.TargetCPUPercent = getTargetCPU(c)
 
+	if c.IsSet("idletimeout") {
+		function.Spec.InvokeStrategy.ExecutionStrategy.IdleTimeout = getIdleTimeout(c)
+	}
+
+	if c.IsSet("targetconcurrency") {
+		function.Spec.InvokeStrategy.ExecutionStrategy.TargetConcurrency = getTargetConcurrency(c)
+	}
+
+	if c.IsSet("warm") {
+		function.Spec.InvokeStrategy.ExecutionStrategy.WarmSchedule = getWarmSchedule(c)
+	}
+
 	if c.IsSet("minscale") {
 		minscale := c.Int("minscale")
 		maxscale := c.Int("maxscale")
@@ -499,17 +596,7 @@ func fnUpdate(c *cli.Context) error {
 	}
 
 	if c.String("executortype") != "" {
-		var fnExecutor fission.ExecutorType
-		switch c.String("executortype") {
-		case "":
-			fnExecutor = fission.ExecutorTypePoolmgr
-		case fission.ExecutorTypePoolmgr:
-			fnExecutor = fission.ExecutorTypePoolmgr
-		case fission.ExecutorTypeNewdeploy:
-			fnExecutor = fission.ExecutorTypeNewdeploy
-		default:
-			fatal("Executor type must be one of 'poolmgr' or 'newdeploy', defaults to 'poolmgr'")
-		}
+		fnExecutor := getExecutorType(client, c.String("executortype"))
 		if (c.IsSet("mincpu") || c.IsSet("maxcpu") || c.IsSet("minmemory") || c.IsSet("maxmemory")) &&
 			fnExecutor == fission.ExecutorTypePoolmgr {
 			warn("CPU/Memory specified for function with pool manager executor will be ignored in favor of resources specified at environment")
@@ -650,45 +737,51 @@ func fnLogs(c *cli.Context) error {
 func fnPods(c *cli.Context) error {
 	client := getClient(c.GlobalString("server"))
 
-	fmt.Println("This subcommand is deprecated and will be removed in the next release. Please use `kubectl get pods -l functionName=<name> --all-namespaces` instead.")
-
+	// without a name, list the function services of all functions
+	var m *metav1.ObjectMeta
 	fnName := c.String("name")
-	if len(fnName) == 0 {
-		fatal("Need name of function, use --name")
+	if len(fnName) > 0 {
+		m = &metav1.ObjectMeta{
+			Name:      fnName,
+			Namespace: metav1.NamespaceDefault,
+		}
 	}
 
-	dbType := c.String("dbtype")
-	if len(dbType) == 0 {
-		dbType = logdb.INFLUXDB
+	infos, err := client.FunctionServiceList(m)
+	checkErr(err, "list function services")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", "FUNCTION", "NAME", "EXECUTORTYPE", "ADDRESS", "OBJECTS", "AGE", "IDLE")
+	for _, info := range infos {
+		objects := make([]string, 0, len(info.KubernetesObjects))
+		for _, obj := range info.KubernetesObjects {
+			objects = append(objects, fmt.Sprintf("%v/%v", strings.ToLower(obj.Kind), obj.Name))
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			info.Function.Name, info.Name, info.Executor, info.Address, strings.Join(objects, ","),
+			time.Since(info.Ctime)/time.Second*time.Second, time.Since(info.Atime)/time.Second*time.Second)
 	}
+	w.Flush()
 
-	m := &metav1.ObjectMeta{Name: fnName}
+	return nil
+}
 
-	f, err := client.FunctionGet(m)
-	checkErr(err, "get function")
+func fnEvict(c *cli.Context) error {
+	client := getClient(c.GlobalString("server"))
 
-	// client first sends db query to the controller, then the controller
-	// will establish a proxy server that bridges the client and the database.
-	logDB, err := logdb.GetLogDB(dbType, getServerUrl())
-	if err != nil {
-		fatal("failed to connect log database")
+	fnName := c.String("name")
+	if len(fnName) == 0 {
+		fatal("Need name of function, use --name")
 	}
 
-	logFilter := logdb.LogFilter{
-		Function: f.Metadata.Name,
-		FuncUid:  string(f.Metadata.UID),
-	}
-	pods, err := logDB.GetPods(logFilter)
-	if err != nil {
-		fatal("failed to get pods of function")
-		return err
-	}
-	fmt.Printf("NAME\t\n")
-	for _, pod := range pods {
-		fmt.Println(pod)
-	}
+	err := client.FunctionServiceEvict(&metav1.ObjectMeta{
+		Name:      fnName,
+		Namespace: metav1.NamespaceDefault,
+	})
+	checkErr(err, "evict function services")
 
-	return err
+	fmt.Printf("function '%v' evicted, the next request gets a fresh function service\n", fnName)
+	return nil
 }
 
 func fnTest(c *cli.Context) error {
@@ -17,7 +17,9 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"strings"
@@ -30,6 +32,7 @@ import (
 	"github.com/fission/fission"
 	"github.com/fission/fission/controller/client"
 	"github.com/fission/fission/crd"
+	"github.com/fission/fission/openapi"
 )
 
 // returns one of http.Method*
@@ -195,3 +198,36 @@ func htList(c *cli.Context) error {
 
 	return nil
 }
+
+func htOpenAPI(c *cli.Context) error {
+	client := getClient(c.GlobalString("server"))
+
+	hts, err := client.HTTPTriggerList()
+	checkErr(err, "list HTTP triggers")
+
+	// Same document as the router's; schemas stored in ConfigMaps are
+	// documented as plain objects if they can't be read from here.
+	_, kubeClient, _, err := crd.GetKubernetesClient()
+	if err != nil {
+		warn(fmt.Sprintf("Schemas stored in ConfigMaps will be left out: %v", err))
+		kubeClient = nil
+	}
+	loader := func(ref *fission.JSONSchemaReference) (string, error) {
+		return openapi.LoadSchemaDocument(kubeClient, ref)
+	}
+	doc := openapi.Generate(c.String("title"), c.String("apiversion"), openapi.Public(hts), loader)
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	checkErr(err, "marshal OpenAPI document")
+
+	outputFile := c.String("output")
+	if len(outputFile) == 0 {
+		fmt.Println(string(out))
+		return nil
+	}
+	err = ioutil.WriteFile(outputFile, append(out, '\n'), 0644)
+	checkErr(err, "write OpenAPI document")
+
+	fmt.Printf("OpenAPI document written to %v\n", outputFile)
+	return nil
+}
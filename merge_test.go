@@ -17,8 +17,10 @@ package fission
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apiv1 "k8s.io/client-go/pkg/api/v1"
 )
 
@@ -118,3 +120,93 @@ func TestMergeContainerSpecsNil(t *testing.T) {
 	result := MergeContainerSpecs()
 	assert.EqualValues(t, expected, result)
 }
+
+func TestApplyScheduling(t *testing.T) {
+	envScheduling := &Scheduling{
+		NodeSelector: map[string]string{"pool": "spot"},
+		Tolerations: []apiv1.Toleration{
+			{Key: "spot", Operator: apiv1.TolerationOpExists, Effect: apiv1.TaintEffectNoSchedule},
+		},
+	}
+	fnScheduling := &Scheduling{
+		NodeSelector: map[string]string{"pool": "dedicated"},
+	}
+
+	podTemplate := apiv1.PodTemplateSpec{}
+	ApplyScheduling(&podTemplate, fnScheduling, envScheduling)
+	assert.Equal(t, fnScheduling.NodeSelector, podTemplate.Spec.NodeSelector)
+	assert.Equal(t, envScheduling.Tolerations, podTemplate.Spec.Tolerations)
+	assert.Nil(t, podTemplate.Spec.Affinity)
+
+	podTemplate = apiv1.PodTemplateSpec{}
+	ApplyScheduling(&podTemplate, nil, envScheduling)
+	assert.Equal(t, envScheduling.NodeSelector, podTemplate.Spec.NodeSelector)
+
+	// spreading adds anti-affinity to the pods' own labels, without
+	// changing the environment's affinity
+	envScheduling.Affinity = &apiv1.Affinity{
+		NodeAffinity: &apiv1.NodeAffinity{},
+		PodAntiAffinity: &apiv1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []apiv1.WeightedPodAffinityTerm{
+				{Weight: 10, PodAffinityTerm: apiv1.PodAffinityTerm{TopologyKey: "kubernetes.io/hostname"}},
+			},
+		},
+	}
+	envScheduling.SpreadTopologyKeys = []string{"failure-domain.beta.kubernetes.io/zone"}
+	labels := map[string]string{"functionName": "fn"}
+	podTemplate = apiv1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}}
+	ApplyScheduling(&podTemplate, nil, envScheduling)
+	assert.Equal(t, envScheduling.Affinity.NodeAffinity, podTemplate.Spec.Affinity.NodeAffinity)
+	terms := podTemplate.Spec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+	if assert.Len(t, terms, 2) {
+		assert.Equal(t, "failure-domain.beta.kubernetes.io/zone", terms[1].PodAffinityTerm.TopologyKey)
+		assert.Equal(t, labels, terms[1].PodAffinityTerm.LabelSelector.MatchLabels)
+	}
+	assert.Len(t, envScheduling.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, 1)
+}
+
+func TestApplyPodSecurity(t *testing.T) {
+	nonRoot := true
+	user := int64(1000)
+	envSecurity := &PodSecurity{
+		ServiceAccountName: "functions",
+		SecurityContext:    &apiv1.SecurityContext{RunAsNonRoot: &nonRoot},
+		SeccompProfile:     "runtime/default",
+	}
+	fnSecurity := &PodSecurity{
+		SecurityContext: &apiv1.SecurityContext{RunAsUser: &user},
+	}
+
+	podTemplate := apiv1.PodTemplateSpec{
+		Spec: apiv1.PodSpec{
+			ServiceAccountName: "fission-fetcher",
+			Containers: []apiv1.Container{
+				{Name: "fn"},
+				{Name: "fetcher"},
+			},
+		},
+	}
+	ApplyPodSecurity(&podTemplate, "fn", fnSecurity, envSecurity)
+	assert.Equal(t, "functions", podTemplate.Spec.ServiceAccountName)
+	assert.Equal(t, fnSecurity.SecurityContext, podTemplate.Spec.Containers[0].SecurityContext)
+	assert.Nil(t, podTemplate.Spec.Containers[1].SecurityContext)
+	assert.Equal(t, "runtime/default", podTemplate.ObjectMeta.Annotations[SECCOMP_CONTAINER_ANNOTATION_PREFIX+"fn"])
+	assert.Empty(t, podTemplate.ObjectMeta.Annotations[SECCOMP_CONTAINER_ANNOTATION_PREFIX+"fetcher"])
+}
+
+func TestWarmInstances(t *testing.T) {
+	es := ExecutionStrategy{
+		WarmSchedule: []WarmWindow{
+			{Cron: "0 0 9 * * *", Duration: 3600, MinInstances: 2},
+			{Cron: "0 30 9 * * *", Duration: 600, MinInstances: 5},
+		},
+	}
+	at := func(hour, min int) time.Time {
+		return time.Date(2018, 3, 1, hour, min, 0, 0, time.UTC)
+	}
+	assert.Equal(t, 0, es.WarmInstances(at(8, 59)))
+	assert.Equal(t, 2, es.WarmInstances(at(9, 0)))
+	assert.Equal(t, 5, es.WarmInstances(at(9, 35)))
+	assert.Equal(t, 2, es.WarmInstances(at(9, 40)))
+	assert.Equal(t, 0, es.WarmInstances(at(10, 0)))
+}
@@ -0,0 +1,297 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package openapi builds an OpenAPI 3 document describing the routes
+// served by a set of HTTP triggers.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+)
+
+const (
+	OPENAPI_VERSION = "3.0.0"
+
+	// Key of the schema document in a ConfigMap, if the reference
+	// doesn't name one.
+	SCHEMA_CONFIGMAP_KEY = "schema.json"
+)
+
+type (
+	Document struct {
+		OpenAPI string               `json:"openapi"`
+		Info    Info                 `json:"info"`
+		Paths   map[string]*PathItem `json:"paths"`
+	}
+
+	Info struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	}
+
+	// PathItem maps lower case HTTP methods to operations.
+	PathItem map[string]*Operation
+
+	Operation struct {
+		OperationID string              `json:"operationId,omitempty"`
+		Summary     string              `json:"summary,omitempty"`
+		Description string              `json:"description,omitempty"`
+		Servers     []Server            `json:"servers,omitempty"`
+		Parameters  []Parameter         `json:"parameters,omitempty"`
+		RequestBody *RequestBody        `json:"requestBody,omitempty"`
+		Responses   map[string]Response `json:"responses"`
+
+		// Function is the name of the function the trigger invokes.
+		Function string `json:"x-fission-function,omitempty"`
+	}
+
+	Server struct {
+		URL string `json:"url"`
+	}
+
+	Parameter struct {
+		Name     string          `json:"name"`
+		In       string          `json:"in"`
+		Required bool            `json:"required"`
+		Schema   json.RawMessage `json:"schema"`
+	}
+
+	RequestBody struct {
+		Required bool                 `json:"required"`
+		Content  map[string]MediaType `json:"content"`
+	}
+
+	Response struct {
+		Description string               `json:"description"`
+		Content     map[string]MediaType `json:"content,omitempty"`
+	}
+
+	MediaType struct {
+		Schema json.RawMessage `json:"schema,omitempty"`
+	}
+
+	// SchemaLoader returns the JSON Schema document a reference points
+	// to. Generate only resolves inline schemas by itself.
+	SchemaLoader func(ref *fission.JSONSchemaReference) (string, error)
+)
+
+// Public returns the triggers that may be published. Triggers with access
+// controls aren't, since they're meant for a limited set of clients.
+func Public(triggers []crd.HTTPTrigger) []crd.HTTPTrigger {
+	public := make([]crd.HTTPTrigger, 0, len(triggers))
+	for _, t := range triggers {
+		if t.Spec.AccessControl == nil {
+			public = append(public, t)
+		}
+	}
+	return public
+}
+
+// LoadSchemaDocument returns the JSON Schema document that ref points to,
+// reading it from its ConfigMap if it's not inline.
+func LoadSchemaDocument(kubeClient *kubernetes.Clientset, ref *fission.JSONSchemaReference) (string, error) {
+	if ref.ConfigMap == nil {
+		return ref.Inline, nil
+	}
+	key := ref.Key
+	if len(key) == 0 {
+		key = SCHEMA_CONFIGMAP_KEY
+	}
+	if kubeClient == nil {
+		return "", fmt.Errorf("no kubernetes client to read schema configmap %v/%v", ref.ConfigMap.Namespace, ref.ConfigMap.Name)
+	}
+	cm, err := kubeClient.CoreV1().ConfigMaps(ref.ConfigMap.Namespace).Get(ref.ConfigMap.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	doc, ok := cm.Data[key]
+	if !ok {
+		return "", fmt.Errorf("key %v not found in schema configmap %v/%v", key, ref.ConfigMap.Namespace, ref.ConfigMap.Name)
+	}
+	return doc, nil
+}
+
+// Generate builds the OpenAPI document for the given triggers. Triggers
+// are processed in name order; if two triggers share a path and method
+// (e.g. on different hosts) the first one is documented. Schemas that
+// can't be loaded are documented as plain JSON objects.
+func Generate(title string, version string, triggers []crd.HTTPTrigger, loader SchemaLoader) *Document {
+	doc := &Document{
+		OpenAPI: OPENAPI_VERSION,
+		Info: Info{
+			Title:   title,
+			Version: version,
+		},
+		Paths: make(map[string]*PathItem),
+	}
+
+	sorted := make([]crd.HTTPTrigger, 0, len(triggers))
+	for _, t := range triggers {
+		if len(t.Spec.RelativeURL) == 0 {
+			continue
+		}
+		sorted = append(sorted, t)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Metadata.Name < sorted[j].Metadata.Name
+	})
+
+	for _, t := range sorted {
+		path, params := ConvertPath(t.Spec.RelativeURL)
+
+		method := strings.ToLower(t.Spec.Method)
+		if len(method) == 0 {
+			method = strings.ToLower(http.MethodGet)
+		}
+
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		if _, ok := (*item)[method]; ok {
+			continue
+		}
+
+		op := &Operation{
+			OperationID: t.Metadata.Name,
+			Parameters:  params,
+			Function:    t.Spec.FunctionReference.Name,
+			Responses: map[string]Response{
+				"default": {Description: "Function response"},
+			},
+		}
+		if len(t.Spec.Host) > 0 {
+			op.Servers = []Server{{URL: fmt.Sprintf("http://%v", t.Spec.Host)}}
+		}
+
+		requestSchema := t.Spec.RequestSchema
+		docs := t.Spec.Documentation
+		if docs != nil {
+			op.Summary = docs.Summary
+			op.Description = docs.Description
+			if docs.RequestSchema != nil {
+				requestSchema = docs.RequestSchema
+			}
+			if docs.ResponseSchema != nil {
+				op.Responses["default"] = Response{
+					Description: "Function response",
+					Content: map[string]MediaType{
+						"application/json": {Schema: loadSchema(docs.ResponseSchema, loader)},
+					},
+				}
+			}
+		}
+		if requestSchema != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content: map[string]MediaType{
+					"application/json": {Schema: loadSchema(requestSchema, loader)},
+				},
+			}
+		}
+
+		(*item)[method] = op
+	}
+
+	return doc
+}
+
+func loadSchema(ref *fission.JSONSchemaReference, loader SchemaLoader) json.RawMessage {
+	schema := ref.Inline
+	if len(schema) == 0 && loader != nil {
+		s, err := loader(ref)
+		if err == nil {
+			schema = s
+		}
+	}
+	var doc interface{}
+	if len(schema) == 0 || json.Unmarshal([]byte(schema), &doc) != nil {
+		return json.RawMessage(`{"type":"object"}`)
+	}
+	return json.RawMessage(schema)
+}
+
+// ConvertPath turns a gorilla/mux path template into an OpenAPI path
+// template, e.g. "/users/{id:[0-9]+}" becomes "/users/{id}", and returns
+// a parameter for each path variable. Regexp patterns are kept as the
+// parameter's schema pattern.
+func ConvertPath(template string) (string, []Parameter) {
+	var path bytes.Buffer
+	var params []Parameter
+
+	for i := 0; i < len(template); i++ {
+		if template[i] != '{' {
+			path.WriteByte(template[i])
+			continue
+		}
+
+		// find the matching brace; patterns may contain braces too
+		level := 0
+		end := -1
+		for j := i; j < len(template); j++ {
+			if template[j] == '{' {
+				level++
+			} else if template[j] == '}' {
+				level--
+				if level == 0 {
+					end = j
+					break
+				}
+			}
+		}
+		if end < 0 {
+			// unbalanced; mux rejects these, so just copy it out
+			path.WriteString(template[i:])
+			break
+		}
+
+		name := template[i+1 : end]
+		pattern := ""
+		if colon := strings.Index(name, ":"); colon >= 0 {
+			pattern = name[colon+1:]
+			name = name[:colon]
+		}
+
+		schema := map[string]string{"type": "string"}
+		if len(pattern) > 0 {
+			schema["pattern"] = fmt.Sprintf("^%v$", pattern)
+		}
+		schemaJSON, _ := json.Marshal(schema)
+
+		params = append(params, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   schemaJSON,
+		})
+		fmt.Fprintf(&path, "{%v}", name)
+		i = end
+	}
+
+	return path.String(), params
+}
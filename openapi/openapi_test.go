@@ -0,0 +1,136 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openapi
+
+import (
+	"log"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+)
+
+func TestConvertPath(t *testing.T) {
+	cases := []struct {
+		template string
+		path     string
+		params   []string
+	}{
+		{"/foo", "/foo", nil},
+		{"/users/{id}", "/users/{id}", []string{"id"}},
+		{"/users/{id:[0-9]+}/posts/{post}", "/users/{id}/posts/{post}", []string{"id", "post"}},
+		{"/codes/{code:[a-z]{3}}", "/codes/{code}", []string{"code"}},
+	}
+	for _, c := range cases {
+		path, params := ConvertPath(c.template)
+		if path != c.path {
+			log.Panicf("%v: expected path %v, got %v", c.template, c.path, path)
+		}
+		if len(params) != len(c.params) {
+			log.Panicf("%v: expected %v params, got %v", c.template, len(c.params), len(params))
+		}
+		for i, p := range params {
+			if p.Name != c.params[i] || p.In != "path" || !p.Required {
+				log.Panicf("%v: unexpected param %v", c.template, p)
+			}
+		}
+	}
+
+	_, params := ConvertPath("/codes/{code:[a-z]{3}}")
+	if string(params[0].Schema) != `{"pattern":"^[a-z]{3}$","type":"string"}` {
+		log.Panicf("unexpected param schema %v", string(params[0].Schema))
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	triggers := []crd.HTTPTrigger{
+		{
+			Metadata: metav1.ObjectMeta{Name: "b"},
+			Spec: fission.HTTPTriggerSpec{
+				RelativeURL:       "/users/{id}",
+				Method:            "GET",
+				FunctionReference: fission.FunctionReference{Name: "get-user"},
+			},
+		},
+		{
+			Metadata: metav1.ObjectMeta{Name: "a"},
+			Spec: fission.HTTPTriggerSpec{
+				Host:              "api.example.com",
+				RelativeURL:       "/users",
+				Method:            "POST",
+				FunctionReference: fission.FunctionReference{Name: "create-user"},
+				RequestSchema:     &fission.JSONSchemaReference{Inline: `{"type":"object"}`},
+				Documentation: &fission.HTTPTriggerDocumentation{
+					Summary: "Create a user",
+					ResponseSchema: &fission.JSONSchemaReference{
+						ConfigMap: &fission.ConfigMapReference{Namespace: "default", Name: "schemas"},
+					},
+				},
+			},
+		},
+		{
+			// same route as "b"; the first trigger by name wins
+			Metadata: metav1.ObjectMeta{Name: "c"},
+			Spec: fission.HTTPTriggerSpec{
+				RelativeURL:       "/users/{id}",
+				Method:            "GET",
+				FunctionReference: fission.FunctionReference{Name: "other"},
+			},
+		},
+	}
+
+	loader := func(ref *fission.JSONSchemaReference) (string, error) {
+		return `{"type":"array"}`, nil
+	}
+	doc := Generate("test", "1.0.0", triggers, loader)
+
+	if len(doc.Paths) != 2 {
+		log.Panicf("expected 2 paths, got %v", len(doc.Paths))
+	}
+	get := (*doc.Paths["/users/{id}"])["get"]
+	if get == nil || get.Function != "get-user" || len(get.Parameters) != 1 {
+		log.Panicf("unexpected GET operation %v", get)
+	}
+	post := (*doc.Paths["/users"])["post"]
+	if post == nil || post.Summary != "Create a user" || post.Servers[0].URL != "http://api.example.com" {
+		log.Panicf("unexpected POST operation %v", post)
+	}
+	if string(post.RequestBody.Content["application/json"].Schema) != `{"type":"object"}` {
+		log.Panicf("unexpected request schema")
+	}
+	if string(post.Responses["default"].Content["application/json"].Schema) != `{"type":"array"}` {
+		log.Panicf("unexpected response schema")
+	}
+}
+
+func TestPublic(t *testing.T) {
+	triggers := []crd.HTTPTrigger{
+		{Metadata: metav1.ObjectMeta{Name: "open"}},
+		{
+			Metadata: metav1.ObjectMeta{Name: "restricted"},
+			Spec: fission.HTTPTriggerSpec{
+				AccessControl: &fission.AccessControlConfig{Allow: []string{"10.0.0.0/8"}},
+			},
+		},
+	}
+	public := Public(triggers)
+	if len(public) != 1 || public[0].Metadata.Name != "open" {
+		log.Panicf("unexpected public triggers %v", public)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/fission/fission"
+)
+
+// accessControl is the parsed form of a trigger's AccessControlConfig.
+type accessControl struct {
+	allow          []*net.IPNet
+	deny           []*net.IPNet
+	trustedProxies []*net.IPNet
+}
+
+func makeAccessControl(config *fission.AccessControlConfig) (*accessControl, error) {
+	var err error
+	ac := &accessControl{}
+	ac.allow, err = parseNetworks(config.Allow)
+	if err != nil {
+		return nil, err
+	}
+	ac.deny, err = parseNetworks(config.Deny)
+	if err != nil {
+		return nil, err
+	}
+	ac.trustedProxies, err = parseNetworks(config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return ac, nil
+}
+
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		ipNet, err := fission.ParseCIDROrIP(strings.TrimSpace(c))
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, ipNet)
+	}
+	return networks, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP finds the address of the client that made the request. If the
+// connection comes from a trusted proxy, X-Forwarded-For is walked from
+// right to left, skipping trusted proxies, and the first untrusted
+// address is the client. Returns nil if the address can't be parsed.
+func (ac *accessControl) clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || len(ac.trustedProxies) == 0 {
+		return ip
+	}
+
+	var hops []string
+	for _, xff := range r.Header[http.CanonicalHeaderKey("X-Forwarded-For")] {
+		hops = append(hops, strings.Split(xff, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0 && containsIP(ac.trustedProxies, ip); i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// Garbage from an untrusted client; stop at the last
+			// address we could verify.
+			break
+		}
+		ip = hop
+	}
+	return ip
+}
+
+// isAllowed evaluates the deny list first, then the allow list.
+func (ac *accessControl) isAllowed(r *http.Request) bool {
+	ip := ac.clientIP(r)
+	if ip == nil {
+		return false
+	}
+	if containsIP(ac.deny, ip) {
+		return false
+	}
+	if len(ac.allow) > 0 && !containsIP(ac.allow, ip) {
+		return false
+	}
+	return true
+}
+
+// anyAllowed reports whether any access control in the list allows the
+// request. An empty list allows everything.
+func anyAllowed(acs []*accessControl, r *http.Request) bool {
+	for _, ac := range acs {
+		if ac.isAllowed(r) {
+			return true
+		}
+	}
+	return len(acs) == 0
+}
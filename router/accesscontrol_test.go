@@ -0,0 +1,70 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"log"
+	"net/http"
+	"testing"
+
+	"github.com/fission/fission"
+)
+
+func TestAccessControl(t *testing.T) {
+	ac, err := makeAccessControl(&fission.AccessControlConfig{
+		Allow:          []string{"10.0.0.0/8", "192.168.1.1"},
+		Deny:           []string{"10.1.0.0/16"},
+		TrustedProxies: []string{"172.16.0.0/12"},
+	})
+	if err != nil {
+		log.Panicf("failed to parse access control: %v", err)
+	}
+
+	cases := []struct {
+		remoteAddr string
+		xff        string
+		allowed    bool
+	}{
+		{"10.2.3.4:1234", "", true},
+		{"192.168.1.1:1234", "", true},
+		{"192.168.1.2:1234", "", false},
+		{"10.1.2.3:1234", "", false},
+		// X-Forwarded-For from an untrusted peer is ignored
+		{"8.8.8.8:1234", "10.2.3.4", false},
+		// client behind a trusted proxy
+		{"172.16.0.5:1234", "10.2.3.4", true},
+		{"172.16.0.5:1234", "8.8.8.8", false},
+		// a spoofed left-most entry doesn't help
+		{"172.16.0.5:1234", "10.2.3.4, 8.8.8.8, 172.16.0.9", false},
+		{"172.16.0.5:1234", "8.8.8.8, 10.2.3.4", true},
+	}
+	for _, c := range cases {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = c.remoteAddr
+		if len(c.xff) > 0 {
+			r.Header.Set("X-Forwarded-For", c.xff)
+		}
+		if ac.isAllowed(r) != c.allowed {
+			log.Panicf("%v (X-Forwarded-For: %v): expected allowed=%v", c.remoteAddr, c.xff, c.allowed)
+		}
+	}
+
+	_, err = makeAccessControl(&fission.AccessControlConfig{Allow: []string{"not-a-cidr"}})
+	if err == nil {
+		log.Panicf("expected error for invalid CIDR")
+	}
+}
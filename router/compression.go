@@ -0,0 +1,237 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fission/fission"
+)
+
+const (
+	ENCODING_GZIP    = "gzip"
+	ENCODING_DEFLATE = "deflate"
+
+	defaultCompressionMinSize = 1024
+)
+
+var defaultCompressionContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+}
+
+// compressResponseWriter buffers the start of a response until it knows
+// whether the response should be compressed, i.e. until MinSize bytes
+// were written or the response is complete. Close must be called once
+// the handler is done.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding     string
+	minSize      int
+	contentTypes []string
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	writer  io.WriteCloser // nil when the response is relayed uncompressed
+}
+
+func makeCompressResponseWriter(w http.ResponseWriter, encoding string, config *fission.CompressionConfig) *compressResponseWriter {
+	minSize := config.MinSize
+	if minSize == 0 {
+		minSize = defaultCompressionMinSize
+	}
+	contentTypes := config.ContentTypes
+	if len(contentTypes) == 0 {
+		contentTypes = defaultCompressionContentTypes
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	return &compressResponseWriter{
+		ResponseWriter: w,
+		encoding:       encoding,
+		minSize:        minSize,
+		contentTypes:   contentTypes,
+	}
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding
+// header, preferring gzip on a tie. Returns "" if neither is acceptable.
+func negotiateEncoding(acceptEncoding string) string {
+	best := ""
+	bestQ := 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err == nil {
+					q = v
+				}
+			}
+		}
+		if coding == "*" {
+			coding = ENCODING_GZIP
+		}
+		if coding != ENCODING_GZIP && coding != ENCODING_DEFLATE {
+			continue
+		}
+		if q > bestQ || (q == bestQ && coding == ENCODING_GZIP) {
+			best = coding
+			bestQ = q
+		}
+	}
+	if bestQ <= 0 {
+		return ""
+	}
+	return best
+}
+
+// matchContentType reports whether contentType is in the allowlist.
+func matchContentType(contentType string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if strings.HasSuffix(a, "/*") {
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(a, "*")) {
+				return true
+			}
+		} else if mediaType == a {
+			return true
+		}
+	}
+	return false
+}
+
+func (cw *compressResponseWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if cw.decided {
+		if cw.writer != nil {
+			return cw.writer.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	n, _ := cw.buf.Write(b)
+	if cw.buf.Len() >= cw.minSize {
+		err := cw.decide()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (cw *compressResponseWriter) Flush() {
+	if !cw.decided {
+		cw.decide()
+	}
+	if fw, ok := cw.writer.(interface {
+		Flush() error
+	}); ok {
+		fw.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close flushes any buffered data and finishes the compressed stream.
+func (cw *compressResponseWriter) Close() error {
+	if !cw.decided {
+		if cw.status == 0 {
+			// nothing was written
+			return nil
+		}
+		err := cw.decide()
+		if err != nil {
+			return err
+		}
+	}
+	if cw.writer != nil {
+		return cw.writer.Close()
+	}
+	return nil
+}
+
+// decide sends the response header, choosing whether to compress based
+// on what has been buffered so far, and then writes out the buffer.
+func (cw *compressResponseWriter) decide() error {
+	cw.decided = true
+	header := cw.ResponseWriter.Header()
+
+	contentType := header.Get("Content-Type")
+	if len(contentType) == 0 {
+		contentType = http.DetectContentType(cw.buf.Bytes())
+	}
+
+	compress := cw.buf.Len() >= cw.minSize &&
+		len(header.Get("Content-Encoding")) == 0 &&
+		cw.status != http.StatusNoContent &&
+		cw.status != http.StatusNotModified &&
+		matchContentType(contentType, cw.contentTypes)
+
+	if compress {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		switch cw.encoding {
+		case ENCODING_DEFLATE:
+			fw, err := flate.NewWriter(cw.ResponseWriter, flate.DefaultCompression)
+			if err != nil {
+				return err
+			}
+			cw.writer = fw
+		default:
+			cw.writer = gzip.NewWriter(cw.ResponseWriter)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if cw.writer != nil {
+		_, err = cw.writer.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+	return err
+}
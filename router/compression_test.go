@@ -0,0 +1,89 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fission/fission"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                          "",
+		"gzip":                      ENCODING_GZIP,
+		"deflate":                   ENCODING_DEFLATE,
+		"deflate, gzip":             ENCODING_GZIP,
+		"gzip;q=0.5, deflate":       ENCODING_DEFLATE,
+		"gzip;q=0, deflate;q=0":     "",
+		"br, identity":              "",
+		"*":                         ENCODING_GZIP,
+		"GZIP;q=0.8, deflate;q=0.2": ENCODING_GZIP,
+	}
+	for header, expected := range cases {
+		if e := negotiateEncoding(header); e != expected {
+			log.Panicf("Accept-Encoding %q: expected %q, got %q", header, expected, e)
+		}
+	}
+}
+
+func TestCompressResponseWriter(t *testing.T) {
+	config := &fission.CompressionConfig{MinSize: 16}
+	large := strings.Repeat("{\"a\": 1}", 100)
+
+	serve := func(contentType, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		cw := makeCompressResponseWriter(rec, ENCODING_GZIP, config)
+		cw.Header().Set("Content-Type", contentType)
+		cw.WriteHeader(http.StatusOK)
+		cw.Write([]byte(body))
+		cw.Close()
+		return rec
+	}
+
+	// large JSON response is compressed
+	rec := serve("application/json; charset=utf-8", large)
+	if rec.Header().Get("Content-Encoding") != ENCODING_GZIP {
+		log.Panicf("expected gzip encoded response")
+	}
+	r, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		log.Panicf("failed to read gzip response: %v", err)
+	}
+	body, err := ioutil.ReadAll(r)
+	if err != nil || string(body) != large {
+		log.Panicf("unexpected decompressed body: %v", err)
+	}
+
+	// small response is relayed as-is
+	rec = serve("application/json", "{}")
+	if len(rec.Header().Get("Content-Encoding")) > 0 || rec.Body.String() != "{}" {
+		log.Panicf("small response shouldn't be compressed")
+	}
+
+	// content type outside the allowlist is relayed as-is
+	rec = serve("image/png", large)
+	if len(rec.Header().Get("Content-Encoding")) > 0 || rec.Body.String() != large {
+		log.Panicf("image response shouldn't be compressed")
+	}
+}
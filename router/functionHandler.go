@@ -18,17 +18,21 @@ package router
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/xeipuuv/gojsonschema"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
 	executorClient "github.com/fission/fission/executor/client"
 )
 
@@ -36,6 +40,14 @@ type functionHandler struct {
 	fmap     *functionServiceMap
 	executor *executorClient.Client
 	function *metav1.ObjectMeta
+
+	// httpTrigger is the trigger this handler serves; nil for the
+	// internal per-function routes.
+	httpTrigger      *crd.HTTPTrigger
+	idempotencyStore *idempotencyStore
+	accessControls   []*accessControl // one must allow a request
+	requestValidator *requestValidator
+	requestSchema    *gojsonschema.Schema // compiled inline RequestSchema of httpTrigger
 }
 
 // A layer on top of http.DefaultTransport, with retries.
@@ -51,6 +63,7 @@ type RetryingRoundTripper struct {
 // It first checks if the service address for this function came from router's cache.
 // If it didn't, it makes a request to executor to get a new service for function. If that succeeds, it adds the address
 // to it's cache and makes a request to that address with transport.RoundTrip call.
+// The executor may return several addresses for a scaled out function; the cache spreads requests across all of them.
 // Initial requests to new k8s services sometimes seem to fail, but retries work. So, it retries with an exponential
 // back-off for maxRetries times.
 //
@@ -91,7 +104,7 @@ func (roundTripper RetryingRoundTripper) RoundTrip(req *http.Request) (resp *htt
 			log.Printf("Calling getServiceForFunction for function: %s", roundTripper.funcHandler.function.Name)
 
 			// send a request to executor to specialize a new pod
-			service, err := roundTripper.funcHandler.executor.GetServiceForFunction(
+			services, err := roundTripper.funcHandler.executor.GetServicesForFunction(
 				roundTripper.funcHandler.function)
 			if err != nil {
 				// We might want a specific error code or header for fission failures as opposed to
@@ -99,14 +112,21 @@ func (roundTripper RetryingRoundTripper) RoundTrip(req *http.Request) (resp *htt
 				return nil, err
 			}
 
-			// parse the address into url
-			serviceUrl, err = url.Parse(fmt.Sprintf("http://%v", service))
-			if err != nil {
-				return nil, err
+			// parse the addresses into urls
+			serviceUrls := make([]*url.URL, 0, len(services))
+			for _, service := range services {
+				u, err := url.Parse(fmt.Sprintf("http://%v", service))
+				if err != nil {
+					return nil, err
+				}
+				serviceUrls = append(serviceUrls, u)
 			}
 
-			// add the address in router's cache
-			roundTripper.funcHandler.fmap.assign(roundTripper.funcHandler.function, serviceUrl)
+			// add the addresses in router's cache; the first one
+			// is the function service the executor just returned or
+			// created
+			roundTripper.funcHandler.fmap.assign(roundTripper.funcHandler.function, serviceUrls...)
+			serviceUrl = serviceUrls[0]
 
 			// flag denotes that service was not obtained from cache, instead, created just now by executor
 			serviceUrlFromExecutor = true
@@ -136,8 +156,12 @@ func (roundTripper RetryingRoundTripper) RoundTrip(req *http.Request) (resp *htt
 		}).DialContext
 
 		// forward the request to the function service
+		roundTripper.funcHandler.requestStarted(serviceUrl)
 		resp, err = transport.RoundTrip(req)
 		if err == nil {
+			// the request stays in flight until the response is copied
+			resp.Body = roundTripper.funcHandler.trackBody(serviceUrl, resp.Body)
+
 			// if transport.RoundTrip succeeds and it was a cached entry, then tapService
 			if !serviceUrlFromExecutor {
 				go roundTripper.funcHandler.tapService(serviceUrl)
@@ -146,6 +170,8 @@ func (roundTripper RetryingRoundTripper) RoundTrip(req *http.Request) (resp *htt
 			return resp, nil
 		}
 
+		roundTripper.funcHandler.requestFinished(serviceUrl)
+
 		// if transport.RoundTrip returns a non-network dial error, then relay it back to user
 		if !fission.IsNetworkDialError(err) {
 			return resp, err
@@ -183,7 +209,53 @@ func (fh *functionHandler) tapService(serviceUrl *url.URL) {
 	fh.executor.TapService(serviceUrl)
 }
 
+func (fh *functionHandler) requestStarted(serviceUrl *url.URL) {
+	if fh.executor == nil {
+		return
+	}
+	fh.executor.RequestStarted(serviceUrl)
+}
+
+func (fh *functionHandler) requestFinished(serviceUrl *url.URL) {
+	if fh.executor == nil {
+		return
+	}
+	fh.executor.RequestFinished(serviceUrl)
+}
+
+// trackBody wraps a response body so that the request is counted as
+// finished once the body is closed.
+func (fh *functionHandler) trackBody(serviceUrl *url.URL, body io.ReadCloser) io.ReadCloser {
+	return &trackedBody{
+		ReadCloser: body,
+		finish:     func() { fh.requestFinished(serviceUrl) },
+	}
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	once   sync.Once
+	finish func()
+}
+
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.finish)
+	return err
+}
+
 func (fh *functionHandler) handler(responseWriter http.ResponseWriter, request *http.Request) {
+	if !anyAllowed(fh.accessControls, request) {
+		http.Error(responseWriter, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if fh.httpTrigger != nil && fh.httpTrigger.Spec.RequestSchema != nil && fh.requestValidator != nil {
+		if !fh.requestValidator.validate(fh.httpTrigger.Spec.RequestSchema, fh.requestSchema, responseWriter, request) {
+			return
+		}
+	}
+
 	// retrieve url params and add them to request header
 	vars := mux.Vars(request)
 	for k, v := range vars {
@@ -211,5 +283,27 @@ func (fh *functionHandler) handler(responseWriter http.ResponseWriter, request *
 		},
 	}
 
+	// Compression wraps the idempotency cache, so that cached responses are
+	// kept uncompressed and re-encoded for each client.
+	if fh.httpTrigger != nil && fh.httpTrigger.Spec.Compression != nil {
+		encoding := negotiateEncoding(request.Header.Get("Accept-Encoding"))
+		if len(encoding) > 0 {
+			cw := makeCompressResponseWriter(responseWriter, encoding, fh.httpTrigger.Spec.Compression)
+			defer cw.Close()
+			responseWriter = cw
+		}
+	}
+
+	if fh.httpTrigger != nil && fh.httpTrigger.Spec.Idempotency != nil && fh.idempotencyStore != nil {
+		key := request.Header.Get(HEADER_IDEMPOTENCY_KEY)
+		if len(key) > 0 {
+			fh.idempotencyStore.serve(
+				idempotencyKey(fh.httpTrigger.Metadata.Namespace, fh.httpTrigger.Metadata.Name, key),
+				getIdempotencyTTL(fh.httpTrigger.Spec.Idempotency),
+				responseWriter, request, proxy)
+			return
+		}
+	}
+
 	proxy.ServeHTTP(responseWriter, request)
 }
@@ -19,6 +19,7 @@ package router
 import (
 	"log"
 	"net/url"
+	"sync/atomic"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -28,7 +29,14 @@ import (
 
 type (
 	functionServiceMap struct {
-		cache *cache.Cache // map[metadataKey]*url.URL
+		cache *cache.Cache // map[metadataKey]*functionServices
+	}
+
+	// functionServices are the addresses of a function's services.
+	// Requests are spread across them round-robin.
+	functionServices struct {
+		urls []*url.URL
+		next uint32
 	}
 
 	// metav1.ObjectMeta is not hashable, so we make a hashable copy
@@ -54,21 +62,26 @@ func keyFromMetadata(m *metav1.ObjectMeta) *metadataKey {
 	}
 }
 
+// lookup returns the address of one of the function's services.
 func (fmap *functionServiceMap) lookup(f *metav1.ObjectMeta) (*url.URL, error) {
 	mk := keyFromMetadata(f)
 	item, err := fmap.cache.Get(*mk)
 	if err != nil {
 		return nil, err
 	}
-	u := item.(*url.URL)
-	return u, nil
+	fs := item.(*functionServices)
+	i := atomic.AddUint32(&fs.next, 1) - 1
+	return fs.urls[int(i%uint32(len(fs.urls)))], nil
 }
 
-func (fmap *functionServiceMap) assign(f *metav1.ObjectMeta, serviceUrl *url.URL) {
+func (fmap *functionServiceMap) assign(f *metav1.ObjectMeta, serviceUrls ...*url.URL) {
+	if len(serviceUrls) == 0 {
+		return
+	}
 	mk := keyFromMetadata(f)
-	err, old := fmap.cache.Set(*mk, serviceUrl)
+	err, old := fmap.cache.Set(*mk, &functionServices{urls: serviceUrls})
 	if err != nil {
-		if *serviceUrl == *(old.(*url.URL)) {
+		if sameUrls(serviceUrls, old.(*functionServices).urls) {
 			return
 		}
 		log.Printf("error caching service url for function with a different value: %v", err)
@@ -80,3 +93,15 @@ func (fmap *functionServiceMap) remove(f *metav1.ObjectMeta) error {
 	mk := keyFromMetadata(f)
 	return fmap.cache.Delete(*mk)
 }
+
+func sameUrls(a []*url.URL, b []*url.URL) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if *a[i] != *b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -47,3 +47,24 @@ func TestFunctionServiceMap(t *testing.T) {
 		t.Errorf("No error on missing entry")
 	}
 }
+
+func TestFunctionServiceMapRoundRobin(t *testing.T) {
+	m := makeFunctionServiceMap(0)
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: metav1.NamespaceDefault}
+	a, _ := url.Parse("http://a:8888")
+	b, _ := url.Parse("http://b:8888")
+
+	m.assign(fn, a, b)
+
+	seen := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		v, err := m.lookup(fn)
+		if err != nil {
+			t.Errorf("Lookup error: %v", err)
+		}
+		seen[v.Host]++
+	}
+	if seen["a:8888"] != 2 || seen["b:8888"] != 2 {
+		t.Errorf("Expected requests spread evenly, got %v", seen)
+	}
+}
@@ -18,19 +18,24 @@ package router
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	k8sCache "k8s.io/client-go/tools/cache"
 
 	"github.com/fission/fission"
 	"github.com/fission/fission/crd"
 	executorClient "github.com/fission/fission/executor/client"
+	"github.com/fission/fission/openapi"
 )
 
 type HTTPTriggerSet struct {
@@ -39,6 +44,8 @@ type HTTPTriggerSet struct {
 
 	fissionClient     *crd.FissionClient
 	executor          *executorClient.Client
+	idempotencyStore  *idempotencyStore
+	requestValidator  *requestValidator
 	resolver          *functionReferenceResolver
 	crdClient         *rest.RESTClient
 	triggers          []crd.HTTPTrigger
@@ -47,9 +54,13 @@ type HTTPTriggerSet struct {
 	functions         []crd.Function
 	funcStore         k8sCache.Store
 	funcController    k8sCache.Controller
+
+	// OpenAPI document of the triggers, as of the last sync
+	openAPILock sync.RWMutex
+	openAPIDoc  []byte
 }
 
-func makeHTTPTriggerSet(fmap *functionServiceMap, fissionClient *crd.FissionClient,
+func makeHTTPTriggerSet(fmap *functionServiceMap, fissionClient *crd.FissionClient, kubeClient *kubernetes.Clientset,
 	executor *executorClient.Client, crdClient *rest.RESTClient) (*HTTPTriggerSet, k8sCache.Store, k8sCache.Store) {
 	httpTriggerSet := &HTTPTriggerSet{
 		functionServiceMap: fmap,
@@ -57,6 +68,8 @@ func makeHTTPTriggerSet(fmap *functionServiceMap, fissionClient *crd.FissionClie
 		fissionClient:      fissionClient,
 		executor:           executor,
 		crdClient:          crdClient,
+		idempotencyStore:   makeIdempotencyStore(),
+		requestValidator:   makeRequestValidator(kubeClient),
 	}
 	var tStore, fnStore k8sCache.Store
 	var tController, fnController k8sCache.Controller
@@ -75,6 +88,7 @@ func (ts *HTTPTriggerSet) subscribeRouter(ctx context.Context, mr *mutableRouter
 	ts.resolver = resolver
 	ts.mutableRouter = mr
 	mr.updateRouter(ts.getRouter())
+	ts.updateOpenAPI(ts.triggers)
 
 	if ts.fissionClient == nil {
 		// Used in tests only.
@@ -89,6 +103,10 @@ func defaultHomeHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+func forbiddenHandler(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "Forbidden", http.StatusForbidden)
+}
+
 func routerHealthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
@@ -98,7 +116,16 @@ func (ts *HTTPTriggerSet) getRouter() *mux.Router {
 
 	// HTTP triggers setup by the user
 	homeHandled := false
-	for _, trigger := range ts.triggers {
+
+	// Access controls of the triggers of each function, which also
+	// guard the function's internal route, and the functions with a
+	// trigger open to all clients, whose internal route is open too.
+	functionACLs := make(map[string][]*accessControl)
+	openFunctions := make(map[string]bool)
+	unenforceable := make(map[string]bool)
+
+	for i := range ts.triggers {
+		trigger := ts.triggers[i]
 
 		// resolve function reference
 		rr, err := ts.resolver.resolve(trigger.Metadata.Namespace, &trigger.Spec.FunctionReference)
@@ -117,9 +144,38 @@ func (ts *HTTPTriggerSet) getRouter() *mux.Router {
 		}
 
 		fh := &functionHandler{
-			fmap:     ts.functionServiceMap,
-			function: rr.functionMetadata,
-			executor: ts.executor,
+			fmap:             ts.functionServiceMap,
+			function:         rr.functionMetadata,
+			executor:         ts.executor,
+			httpTrigger:      &trigger,
+			idempotencyStore: ts.idempotencyStore,
+			requestValidator: ts.requestValidator,
+		}
+
+		fh.requestSchema, err = compileInlineSchema(trigger.Spec.RequestSchema)
+		if err != nil {
+			// Don't serve a trigger whose requests we can't validate;
+			// let it 404 like an unresolvable one.
+			log.Printf("Error compiling request schema of trigger %v: %v", trigger.Metadata.Name, err)
+			go ts.updateTriggerStatusFailed(&trigger, err)
+			continue
+		}
+
+		fnKey := functionKey(rr.functionMetadata)
+		if trigger.Spec.AccessControl == nil {
+			openFunctions[fnKey] = true
+		} else {
+			ac, err := makeAccessControl(trigger.Spec.AccessControl)
+			if err != nil {
+				// Don't serve a trigger whose access lists we can't
+				// enforce; let it 404 like an unresolvable one.
+				log.Printf("Error parsing access control of trigger %v: %v", trigger.Metadata.Name, err)
+				go ts.updateTriggerStatusFailed(&trigger, err)
+				unenforceable[fnKey] = true
+				continue
+			}
+			fh.accessControls = []*accessControl{ac}
+			functionACLs[fnKey] = append(functionACLs[fnKey], ac)
 		}
 
 		ht := muxRouter.HandleFunc(trigger.Spec.RelativeURL, fh.handler)
@@ -143,23 +199,72 @@ func (ts *HTTPTriggerSet) getRouter() *mux.Router {
 	}
 
 	// Internal triggers for each function by name. Non-http
-	// triggers route into these.
+	// triggers route into these. They're reachable by anyone who can
+	// reach the router, so unless one of the function's HTTP triggers
+	// is open to all clients, they only let in the clients that one
+	// of those triggers would; otherwise restricting the triggers
+	// would be pointless. The non-http triggers of such functions need
+	// their addresses allowed by one of them too. A function whose
+	// access controls can't be parsed is closed to all clients.
 	for _, function := range ts.functions {
 		m := function.Metadata
+		fnKey := functionKey(&m)
+		url := fission.UrlForFunction(function.Metadata.Name)
+		if unenforceable[fnKey] {
+			muxRouter.HandleFunc(url, forbiddenHandler)
+			continue
+		}
 		fh := &functionHandler{
 			fmap:     ts.functionServiceMap,
 			function: &m,
 			executor: ts.executor,
 		}
-		muxRouter.HandleFunc(fission.UrlForFunction(function.Metadata.Name), fh.handler)
+		if !openFunctions[fnKey] {
+			fh.accessControls = functionACLs[fnKey]
+		}
+		muxRouter.HandleFunc(url, fh.handler)
 	}
 
 	// Healthz endpoint for the router.
 	muxRouter.HandleFunc("/router-healthz", routerHealthHandler).Methods("GET")
 
+	// OpenAPI document describing the HTTP triggers.
+	muxRouter.HandleFunc("/router-openapi", ts.openAPIHandler).Methods("GET")
+
 	return muxRouter
 }
 
+// functionKey identifies a function across namespaces.
+func functionKey(m *metav1.ObjectMeta) string {
+	return fmt.Sprintf("%v/%v", m.Namespace, m.Name)
+}
+
+func (ts *HTTPTriggerSet) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	ts.openAPILock.RLock()
+	resp := ts.openAPIDoc
+	ts.openAPILock.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(resp)
+}
+
+// updateOpenAPI regenerates the OpenAPI document of the triggers. It's
+// done with each sync rather than per request, since schemas may be read
+// from ConfigMaps; the trigger resync picks up ConfigMap updates. Triggers
+// with access controls aren't published.
+func (ts *HTTPTriggerSet) updateOpenAPI(triggers []crd.HTTPTrigger) {
+	doc := openapi.Generate("Fission HTTP triggers", fission.Version, openapi.Public(triggers), ts.requestValidator.getSchemaDocument)
+	resp, err := json.Marshal(doc)
+	if err != nil {
+		log.Printf("Error encoding OpenAPI document: %v", err)
+		return
+	}
+
+	ts.openAPILock.Lock()
+	defer ts.openAPILock.Unlock()
+	ts.openAPIDoc = resp
+}
+
 func (ts *HTTPTriggerSet) updateTriggerStatusFailed(ht *crd.HTTPTrigger, err error) {
 	// TODO
 }
@@ -237,4 +342,5 @@ func (ts *HTTPTriggerSet) syncTriggers() {
 
 	// make a new router and use it
 	ts.mutableRouter.updateRouter(ts.getRouter())
+	ts.updateOpenAPI(triggers)
 }
@@ -0,0 +1,277 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fission/fission"
+)
+
+const (
+	HEADER_IDEMPOTENCY_KEY    = "Idempotency-Key"
+	HEADER_IDEMPOTENCY_REPLAY = "X-Fission-Idempotent-Replay"
+
+	// Responses larger than this are relayed but not cached, so a
+	// duplicate request invokes the function again.
+	maxIdempotentResponseSize = 1024 * 1024
+
+	defaultIdempotencyTTL = 5 * time.Minute
+)
+
+type (
+	// idempotencyStore caches function responses by trigger and
+	// idempotency key. It lives in the HTTPTriggerSet, so that cached
+	// responses survive router rebuilds on trigger/function updates.
+	idempotencyStore struct {
+		lock    sync.Mutex
+		entries map[string]*idempotentResponse
+	}
+
+	// idempotentResponse is either in flight (done is open) or
+	// complete. A complete response with cached == false was not
+	// cacheable; waiters then retry on their own.
+	idempotentResponse struct {
+		done   chan struct{}
+		expiry time.Time
+		cached bool
+
+		status int
+		header http.Header
+		body   []byte
+	}
+
+	// teeResponseWriter relays a response to the client while keeping
+	// a copy of it.
+	teeResponseWriter struct {
+		http.ResponseWriter
+		status   int
+		header   http.Header
+		body     bytes.Buffer
+		overflow bool
+	}
+)
+
+func makeIdempotencyStore() *idempotencyStore {
+	store := &idempotencyStore{
+		entries: make(map[string]*idempotentResponse),
+	}
+	go store.expiryService()
+	return store
+}
+
+func getIdempotencyTTL(config *fission.IdempotencyConfig) time.Duration {
+	if config == nil || config.TTL == 0 {
+		return defaultIdempotencyTTL
+	}
+	return time.Duration(config.TTL) * time.Second
+}
+
+// acquire returns the response for key, and whether the caller owns it.
+// The owner must invoke the function and call complete; everyone else
+// waits on the response's done channel.
+func (store *idempotencyStore) acquire(key string) (*idempotentResponse, bool) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	resp, ok := store.entries[key]
+	if ok && (!resp.isDone() || time.Now().Before(resp.expiry)) {
+		return resp, false
+	}
+	resp = &idempotentResponse{
+		done: make(chan struct{}),
+	}
+	store.entries[key] = resp
+	return resp, true
+}
+
+// complete records the result of the owner's request and wakes up
+// waiting duplicates.
+func (store *idempotencyStore) complete(key string, resp *idempotentResponse, w *teeResponseWriter, ttl time.Duration) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	// Don't cache router-side failures (e.g. the executor couldn't
+	// specialize a pod), so that a retry gets a real attempt.
+	if w.status < http.StatusInternalServerError && !w.overflow {
+		resp.cached = true
+		resp.status = w.status
+		resp.header = w.header
+		resp.body = w.body.Bytes()
+		resp.expiry = time.Now().Add(ttl)
+	} else {
+		delete(store.entries, key)
+	}
+	close(resp.done)
+}
+
+// abandon drops the response of an owner that failed without one, and
+// wakes up waiting duplicates to retry.
+func (store *idempotencyStore) abandon(key string, resp *idempotentResponse) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if store.entries[key] == resp {
+		delete(store.entries, key)
+	}
+	close(resp.done)
+}
+
+func (store *idempotencyStore) expiryService() {
+	for {
+		time.Sleep(time.Minute)
+		store.lock.Lock()
+		for key, resp := range store.entries {
+			if resp.isDone() && time.Now().After(resp.expiry) {
+				delete(store.entries, key)
+			}
+		}
+		store.lock.Unlock()
+	}
+}
+
+// serve relays the response for the request's idempotency key. The
+// first request for a key is passed on to next; replays and concurrent
+// duplicates get a copy of its response.
+func (store *idempotencyStore) serve(key string, ttl time.Duration, w http.ResponseWriter, r *http.Request, next http.Handler) {
+	for {
+		resp, owner := store.acquire(key)
+		if owner {
+			store.serveOwner(key, resp, ttl, w, r, next)
+			return
+		}
+
+		log.Printf("Waiting for response to idempotency key %v", key)
+		select {
+		case <-resp.done:
+		case <-r.Context().Done():
+			// the client went away
+			return
+		}
+		if resp.cached {
+			resp.write(w)
+			return
+		}
+		// The first attempt wasn't cacheable; try again ourselves.
+	}
+}
+
+// serveOwner passes the request on to next, and records its response. If
+// next panics, the entry is dropped so that duplicates make their own
+// attempt, rather than waiting for it forever.
+func (store *idempotencyStore) serveOwner(key string, resp *idempotentResponse, ttl time.Duration, w http.ResponseWriter, r *http.Request, next http.Handler) {
+	defer func() {
+		if p := recover(); p != nil {
+			store.abandon(key, resp)
+			panic(p)
+		}
+	}()
+
+	tw := &teeResponseWriter{ResponseWriter: w}
+	next.ServeHTTP(tw, r)
+	if tw.status == 0 {
+		tw.setStatus(http.StatusOK)
+	}
+	store.complete(key, resp, tw, ttl)
+}
+
+func (resp *idempotentResponse) isDone() bool {
+	select {
+	case <-resp.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (resp *idempotentResponse) write(w http.ResponseWriter) {
+	for k, v := range resp.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set(HEADER_IDEMPOTENCY_REPLAY, "true")
+	w.WriteHeader(resp.status)
+	w.Write(resp.body)
+}
+
+// setStatus records the status and a copy of the header as the function
+// sent them. Writers wrapping this one, like compression, may still change
+// the header for their own encoding of the response, and that mustn't be
+// cached with the plain body.
+func (w *teeResponseWriter) setStatus(status int) {
+	w.status = status
+	w.header = cloneHeader(w.Header())
+
+	// added by compression, which sets it again on replays it encodes
+	vary := make([]string, 0)
+	for _, v := range w.header["Vary"] {
+		if v != "Accept-Encoding" {
+			vary = append(vary, v)
+		}
+	}
+	if len(vary) > 0 {
+		w.header["Vary"] = vary
+	} else {
+		w.header.Del("Vary")
+	}
+}
+
+func (w *teeResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.setStatus(status)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *teeResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.setStatus(http.StatusOK)
+	}
+	if !w.overflow {
+		if w.body.Len()+len(b) > maxIdempotentResponseSize {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *teeResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+// idempotencyKey scopes a client-supplied key to a trigger, so that
+// unrelated triggers can't see each other's responses.
+func idempotencyKey(namespace, trigger, key string) string {
+	return fmt.Sprintf("%v/%v/%v", namespace, trigger, key)
+}
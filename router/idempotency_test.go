@@ -0,0 +1,241 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fission/fission"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	var invocations int32
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&invocations, 1)
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("X-Invocation", fmt.Sprintf("%v", n))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	})
+
+	store := makeIdempotencyStore()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := idempotencyKey("default", "foo", r.Header.Get(HEADER_IDEMPOTENCY_KEY))
+		store.serve(key, time.Minute, w, r, backend)
+	}))
+	defer server.Close()
+
+	post := func(key string) *http.Response {
+		req, err := http.NewRequest("POST", server.URL, nil)
+		if err != nil {
+			log.Panicf("failed to create request: %v", err)
+		}
+		req.Header.Set(HEADER_IDEMPOTENCY_KEY, key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Panicf("failed to make post request: %v", err)
+		}
+		return resp
+	}
+
+	// concurrent duplicates share the in-flight response
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := post("abc")
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusCreated || string(body) != "created" {
+				log.Panicf("unexpected response: %v %v", resp.StatusCode, string(body))
+			}
+			if resp.Header.Get("X-Invocation") != "1" {
+				log.Panicf("response from unexpected invocation %v", resp.Header.Get("X-Invocation"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// replays come from the cache
+	resp := post("abc")
+	resp.Body.Close()
+	if resp.Header.Get(HEADER_IDEMPOTENCY_REPLAY) != "true" {
+		log.Panicf("expected a replayed response")
+	}
+	if atomic.LoadInt32(&invocations) != 1 {
+		log.Panicf("expected 1 invocation, got %v", invocations)
+	}
+
+	// a different key invokes the function again
+	resp = post("def")
+	resp.Body.Close()
+	if atomic.LoadInt32(&invocations) != 2 {
+		log.Panicf("expected 2 invocations, got %v", invocations)
+	}
+}
+
+func TestIdempotencyReplayCompressed(t *testing.T) {
+	plain := strings.Repeat("hello world ", 200)
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(plain))
+	})
+
+	// same order as the function handler: compression wraps the cache
+	store := makeIdempotencyStore()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if len(encoding) > 0 {
+			cw := makeCompressResponseWriter(w, encoding, &fission.CompressionConfig{})
+			defer cw.Close()
+			w = cw
+		}
+		store.serve(idempotencyKey("default", "foo", "abc"), time.Minute, w, r, backend)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	get := func(acceptEncoding string) (*http.Response, string) {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			log.Panicf("failed to create request: %v", err)
+		}
+		if len(acceptEncoding) > 0 {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Panicf("failed to make request: %v", err)
+		}
+		defer resp.Body.Close()
+		var body io.Reader = resp.Body
+		if resp.Header.Get("Content-Encoding") == ENCODING_GZIP {
+			body, err = gzip.NewReader(resp.Body)
+			if err != nil {
+				log.Panicf("failed to read gzip response: %v", err)
+			}
+		}
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			log.Panicf("failed to read response: %v", err)
+		}
+		return resp, string(b)
+	}
+
+	resp, body := get("gzip")
+	if resp.Header.Get("Content-Encoding") != ENCODING_GZIP || body != plain {
+		log.Panicf("expected a gzip response, got %q", resp.Header.Get("Content-Encoding"))
+	}
+
+	// a replay to a client that doesn't accept gzip is plain
+	resp, body = get("")
+	if resp.Header.Get(HEADER_IDEMPOTENCY_REPLAY) != "true" {
+		log.Panicf("expected a replayed response")
+	}
+	if len(resp.Header.Get("Content-Encoding")) > 0 || len(resp.Header.Get("Vary")) > 0 || body != plain {
+		log.Panicf("unexpected replay: Content-Encoding %q, Vary %q, body %q...",
+			resp.Header.Get("Content-Encoding"), resp.Header.Get("Vary"), body[:20])
+	}
+
+	// and compressed again for one that does
+	resp, body = get("gzip")
+	if resp.Header.Get("Content-Encoding") != ENCODING_GZIP || body != plain {
+		log.Panicf("expected a gzip replay, got %q", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+func TestIdempotencyPanic(t *testing.T) {
+	var invocations int32
+	release := make(chan struct{})
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&invocations, 1) == 1 {
+			<-release
+			panic("function handler failed")
+		}
+		w.Write([]byte("ok"))
+	})
+
+	store := makeIdempotencyStore()
+	key := idempotencyKey("default", "foo", "abc")
+	serve := func(ctx context.Context) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/", nil).WithContext(ctx)
+		store.serve(key, time.Minute, w, r, backend)
+		return w
+	}
+
+	// the owner panics while a duplicate waits for it
+	panicked := make(chan interface{})
+	go func() {
+		defer func() {
+			panicked <- recover()
+		}()
+		serve(context.Background())
+	}()
+	for atomic.LoadInt32(&invocations) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// a waiting duplicate gives up with its client
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan struct{})
+	go func() {
+		serve(ctx)
+		close(cancelled)
+	}()
+	cancel()
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		log.Panicf("waiter didn't return after its request was cancelled")
+	}
+
+	waiter := make(chan *httptest.ResponseRecorder)
+	go func() {
+		waiter <- serve(context.Background())
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if p := <-panicked; p == nil {
+		log.Panicf("expected the owner's panic to propagate")
+	}
+	select {
+	case w := <-waiter:
+		if w.Code != http.StatusOK || w.Body.String() != "ok" {
+			log.Panicf("unexpected response: %v %v", w.Code, w.Body.String())
+		}
+	case <-time.After(time.Second):
+		log.Panicf("waiter stuck after the owner panicked")
+	}
+	if atomic.LoadInt32(&invocations) != 2 {
+		log.Panicf("expected 2 invocations, got %v", invocations)
+	}
+}
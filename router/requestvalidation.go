@@ -0,0 +1,201 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/cache"
+	"github.com/fission/fission/openapi"
+)
+
+const (
+	// Bodies larger than this are rejected instead of being read into
+	// memory for validation.
+	maxValidatedBodySize = 10 * 1024 * 1024
+)
+
+type (
+	// requestValidator validates request bodies against the JSON Schemas
+	// referenced by triggers. Compiled schemas are cached; schemas from
+	// ConfigMaps expire so that ConfigMap updates are picked up.
+	requestValidator struct {
+		kubernetesClient *kubernetes.Clientset
+		schemas          *cache.Cache // schemaKey -> *gojsonschema.Schema
+	}
+
+	schemaKey struct {
+		Inline    string
+		Namespace string
+		Name      string
+		Key       string
+	}
+
+	schemaFieldError struct {
+		Field       string `json:"field"`
+		Description string `json:"description"`
+	}
+
+	schemaValidationResponse struct {
+		Message string             `json:"message"`
+		Errors  []schemaFieldError `json:"errors,omitempty"`
+	}
+)
+
+func makeRequestValidator(kubernetesClient *kubernetes.Clientset) *requestValidator {
+	return &requestValidator{
+		kubernetesClient: kubernetesClient,
+		schemas:          cache.MakeCache(time.Minute, 0),
+	}
+}
+
+func makeSchemaKey(ref *fission.JSONSchemaReference) schemaKey {
+	if ref.ConfigMap == nil {
+		return schemaKey{Inline: ref.Inline}
+	}
+	key := schemaKey{
+		Namespace: ref.ConfigMap.Namespace,
+		Name:      ref.ConfigMap.Name,
+		Key:       ref.Key,
+	}
+	if len(key.Key) == 0 {
+		key.Key = openapi.SCHEMA_CONFIGMAP_KEY
+	}
+	return key
+}
+
+// getSchemaDocument returns the JSON Schema document that ref points to.
+func (rv *requestValidator) getSchemaDocument(ref *fission.JSONSchemaReference) (string, error) {
+	return openapi.LoadSchemaDocument(rv.kubernetesClient, ref)
+}
+
+// compileInlineSchema compiles the inline schema of ref, if it has one.
+// Triggers' inline schemas are compiled once, when their routes are set
+// up, rather than on requests.
+func compileInlineSchema(ref *fission.JSONSchemaReference) (*gojsonschema.Schema, error) {
+	if ref == nil || len(ref.Inline) == 0 {
+		return nil, nil
+	}
+	return gojsonschema.NewSchema(gojsonschema.NewStringLoader(ref.Inline))
+}
+
+func (rv *requestValidator) getSchema(ref *fission.JSONSchemaReference) (*gojsonschema.Schema, error) {
+	key := makeSchemaKey(ref)
+	s, err := rv.schemas.Get(key)
+	if err == nil {
+		return s.(*gojsonschema.Schema), nil
+	}
+
+	doc, err := rv.getSchemaDocument(ref)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(doc))
+	if err != nil {
+		return nil, err
+	}
+	rv.schemas.Set(key, schema)
+	return schema, nil
+}
+
+// validate checks the request body against the schema, which is either
+// the trigger's compiled inline schema or, if that's nil, loaded from ref.
+// It responds with an error and returns false if the request must not be
+// forwarded. An empty body is validated as JSON null, so it's only passed
+// on if the schema allows null. The request body is replaced so that it
+// can be read again.
+func (rv *requestValidator) validate(ref *fission.JSONSchemaReference, schema *gojsonschema.Schema, w http.ResponseWriter, r *http.Request) bool {
+	var body []byte
+	var err error
+	// A body of unknown length (-1) must be read to tell whether it's
+	// empty.
+	if r.Body != nil && r.ContentLength != 0 {
+		body, err = ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxValidatedBodySize))
+		if err != nil {
+			http.Error(w, "Failed to read request", http.StatusBadRequest)
+			return false
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+	}
+
+	if schema == nil {
+		schema, err = rv.getSchema(ref)
+		if err != nil {
+			log.Printf("Error loading request schema: %v", err)
+			http.Error(w, "Error loading request schema", http.StatusInternalServerError)
+			return false
+		}
+	}
+
+	message := "request body does not match the schema"
+	doc := body
+	if len(doc) == 0 {
+		message = "request body is required by the schema"
+		doc = []byte("null")
+	} else {
+		var v interface{}
+		if json.Unmarshal(doc, &v) != nil {
+			writeSchemaValidationResponse(w, schemaValidationResponse{
+				Message: "request body is not valid JSON",
+			})
+			return false
+		}
+	}
+
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(doc))
+	if err != nil {
+		log.Printf("Error validating request: %v", err)
+		http.Error(w, "Error validating request", http.StatusInternalServerError)
+		return false
+	}
+	if result.Valid() {
+		return true
+	}
+
+	resp := schemaValidationResponse{
+		Message: message,
+	}
+	for _, e := range result.Errors() {
+		resp.Errors = append(resp.Errors, schemaFieldError{
+			Field:       e.Field(),
+			Description: e.Description(),
+		})
+	}
+	writeSchemaValidationResponse(w, resp)
+	return false
+}
+
+func writeSchemaValidationResponse(w http.ResponseWriter, resp schemaValidationResponse) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, resp.Message, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(body)
+}
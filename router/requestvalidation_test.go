@@ -0,0 +1,117 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fission/fission"
+)
+
+func TestRequestValidator(t *testing.T) {
+	rv := makeRequestValidator(nil)
+	ref := &fission.JSONSchemaReference{
+		Inline: `{
+			"type": "object",
+			"properties": {"name": {"type": "string"}, "age": {"type": "integer", "minimum": 0}},
+			"required": ["name"]
+		}`,
+	}
+
+	schema, err := compileInlineSchema(ref)
+	if err != nil {
+		log.Panicf("failed to compile schema: %v", err)
+	}
+
+	validate := func(body string) (bool, *httptest.ResponseRecorder, *http.Request) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		return rv.validate(ref, schema, w, r), w, r
+	}
+
+	// valid body is passed on, and can still be read
+	body := `{"name": "fission", "age": 2}`
+	ok, _, r := validate(body)
+	if !ok {
+		log.Panicf("expected valid request")
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil || string(b) != body {
+		log.Panicf("request body wasn't restored: %v", err)
+	}
+
+	// schema violations are reported per field
+	ok, w, _ := validate(`{"age": -1}`)
+	if ok || w.Code != http.StatusBadRequest {
+		log.Panicf("expected 400 for invalid request, got %v", w.Code)
+	}
+	var resp schemaValidationResponse
+	err = json.Unmarshal(w.Body.Bytes(), &resp)
+	if err != nil {
+		log.Panicf("failed to parse validation response: %v", err)
+	}
+	if len(resp.Errors) != 2 {
+		log.Panicf("expected 2 validation errors, got %v", resp.Errors)
+	}
+
+	// malformed JSON
+	ok, w, _ = validate(`{"name": `)
+	if ok || w.Code != http.StatusBadRequest {
+		log.Panicf("expected 400 for malformed JSON, got %v", w.Code)
+	}
+
+	// an empty body is rejected if the schema doesn't allow null
+	ok, w, _ = validate("")
+	if ok || w.Code != http.StatusBadRequest {
+		log.Panicf("expected 400 for empty body, got %v", w.Code)
+	}
+	w = httptest.NewRecorder()
+	if rv.validate(ref, schema, w, httptest.NewRequest("GET", "/", nil)) || w.Code != http.StatusBadRequest {
+		log.Panicf("expected 400 for GET request without a body, got %v", w.Code)
+	}
+
+	// ...and passed on if it does
+	optionalRef := &fission.JSONSchemaReference{Inline: `{"type": ["object", "null"]}`}
+	optional, err := compileInlineSchema(optionalRef)
+	if err != nil {
+		log.Panicf("failed to compile schema: %v", err)
+	}
+	w = httptest.NewRecorder()
+	if !rv.validate(optionalRef, optional, w, httptest.NewRequest("POST", "/", strings.NewReader(""))) {
+		log.Panicf("expected empty body to be passed on, got %v", w.Code)
+	}
+
+	// a body of unknown length is read and validated
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"age": 1}`))
+	r.ContentLength = -1
+	w = httptest.NewRecorder()
+	if rv.validate(ref, schema, w, r) || w.Code != http.StatusBadRequest {
+		log.Panicf("expected 400 for invalid body of unknown length, got %v", w.Code)
+	}
+
+	// a schema that doesn't compile is rejected up front
+	_, err = compileInlineSchema(&fission.JSONSchemaReference{Inline: `{"type": 1}`})
+	if err == nil {
+		log.Panicf("expected invalid schema to fail to compile")
+	}
+}
@@ -18,7 +18,11 @@ package router
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -26,6 +30,7 @@ import (
 
 	"github.com/fission/fission"
 	"github.com/fission/fission/crd"
+	"github.com/fission/fission/openapi"
 )
 
 func TestRouter(t *testing.T) {
@@ -59,7 +64,7 @@ func TestRouter(t *testing.T) {
 	frr.refCache.Set(nfr, rr)
 
 	// HTTP trigger set with a trigger for this function
-	triggers, _, _ := makeHTTPTriggerSet(fmap, nil, nil, nil)
+	triggers, _, _ := makeHTTPTriggerSet(fmap, nil, nil, nil, nil)
 	triggerUrl := "/foo"
 	triggers.triggers = append(triggers.triggers,
 		crd.HTTPTrigger{
@@ -85,3 +90,124 @@ func TestRouter(t *testing.T) {
 	testUrl := fmt.Sprintf("http://localhost:%v%v", port, triggerUrl)
 	testRequest(testUrl, testResponseString)
 }
+
+func TestInternalRouteAccessControl(t *testing.T) {
+	fmap := makeFunctionServiceMap(0)
+	frr := makeFunctionReferenceResolver(nil)
+	triggers, _, _ := makeHTTPTriggerSet(fmap, nil, nil, nil, nil)
+
+	// triggers of each function, by path; nil is open to all clients
+	functions := map[string]map[string]*fission.AccessControlConfig{
+		"restricted": {
+			"/restricted": {Allow: []string{"10.0.0.0/8"}},
+		},
+		"open": {
+			"/open": nil,
+		},
+		"mixed": {
+			"/mixed-open":       nil,
+			"/mixed-restricted": {Allow: []string{"10.0.0.0/8"}},
+		},
+		"either": {
+			"/either-1": {Allow: []string{"10.0.0.0/8"}},
+			"/either-2": {Allow: []string{"192.168.0.0/16"}},
+		},
+		"broken": {
+			"/broken": {Allow: []string{"not a cidr"}},
+		},
+	}
+	for name, acls := range functions {
+		fn := &metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault}
+		fmap.assign(fn, createBackendService(name))
+		fr := fission.FunctionReference{
+			Type: fission.FunctionReferenceTypeFunctionName,
+			Name: fn.Name,
+		}
+		frr.refCache.Set(namespacedFunctionReference{
+			namespace:         metav1.NamespaceDefault,
+			functionReference: fr,
+		}, resolveResult{
+			resolveResultType: resolveResultSingleFunction,
+			functionMetadata:  fn,
+		})
+		triggers.functions = append(triggers.functions, crd.Function{Metadata: *fn})
+
+		for path, acl := range acls {
+			triggers.triggers = append(triggers.triggers, crd.HTTPTrigger{
+				Metadata: metav1.ObjectMeta{Name: path[1:], Namespace: metav1.NamespaceDefault},
+				Spec: fission.HTTPTriggerSpec{
+					RelativeURL:       path,
+					FunctionReference: fr,
+					Method:            "GET",
+					AccessControl:     acl,
+				},
+			})
+		}
+	}
+	triggers.resolver = frr
+	router := triggers.getRouter()
+
+	get := func(path string) int {
+		r := httptest.NewRequest("GET", path, nil)
+		r.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	expected := map[string]int{
+		"/restricted":                        http.StatusForbidden,
+		fission.UrlForFunction("restricted"): http.StatusForbidden,
+		"/open":                              http.StatusOK,
+		fission.UrlForFunction("open"):       http.StatusOK,
+
+		// the open trigger lets anyone in anyway
+		"/mixed-restricted":             http.StatusForbidden,
+		fission.UrlForFunction("mixed"): http.StatusOK,
+
+		// the client could go through the second trigger
+		"/either-1":                      http.StatusForbidden,
+		fission.UrlForFunction("either"): http.StatusOK,
+
+		// access controls that can't be enforced close the function
+		fission.UrlForFunction("broken"): http.StatusForbidden,
+	}
+	for path, code := range expected {
+		if got := get(path); got != code {
+			log.Panicf("%v: expected %v, got %v", path, code, got)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	triggers, _, _ := makeHTTPTriggerSet(makeFunctionServiceMap(0), nil, nil, nil, nil)
+	for _, name := range []string{"restricted", "open"} {
+		trigger := crd.HTTPTrigger{
+			Metadata: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault},
+			Spec: fission.HTTPTriggerSpec{
+				RelativeURL: "/" + name,
+				Method:      "GET",
+			},
+		}
+		if name == "restricted" {
+			trigger.Spec.AccessControl = &fission.AccessControlConfig{Allow: []string{"10.0.0.0/8"}}
+		}
+		triggers.triggers = append(triggers.triggers, trigger)
+	}
+	triggers.updateOpenAPI(triggers.triggers)
+
+	w := httptest.NewRecorder()
+	triggers.openAPIHandler(w, httptest.NewRequest("GET", "/router-openapi", nil))
+
+	var doc openapi.Document
+	err := json.Unmarshal(w.Body.Bytes(), &doc)
+	if err != nil {
+		log.Panicf("failed to parse OpenAPI document: %v", err)
+	}
+	if _, ok := doc.Paths["/open"]; !ok {
+		log.Panicf("expected /open in the OpenAPI document")
+	}
+	if _, ok := doc.Paths["/restricted"]; ok {
+		log.Panicf("expected /restricted, which has access controls, to be left out")
+	}
+}
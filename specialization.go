@@ -0,0 +1,44 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fission
+
+import (
+	"encoding/json"
+
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+)
+
+// LatestSpecialization returns the latest specialization status recorded
+// on the given events of a function, if any.
+func LatestSpecialization(events []apiv1.Event) *SpecializationStatus {
+	var latest *SpecializationStatus
+	for _, event := range events {
+		data, ok := event.ObjectMeta.Annotations[ANNOTATION_SPECIALIZATION_STATUS]
+		if !ok {
+			continue
+		}
+		var status SpecializationStatus
+		err := json.Unmarshal([]byte(data), &status)
+		if err != nil {
+			continue
+		}
+		if latest == nil || latest.Time.Time.Before(status.Time.Time) {
+			latest = &status
+		}
+	}
+	return latest
+}
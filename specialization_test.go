@@ -0,0 +1,54 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fission
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+)
+
+func TestLatestSpecialization(t *testing.T) {
+	event := func(status *SpecializationStatus) apiv1.Event {
+		data, err := json.Marshal(status)
+		assert.NoError(t, err)
+		return apiv1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{ANNOTATION_SPECIALIZATION_STATUS: string(data)},
+			},
+		}
+	}
+	now := time.Now()
+
+	assert.Nil(t, LatestSpecialization(nil))
+	assert.Nil(t, LatestSpecialization([]apiv1.Event{{Reason: "UpdateFailed"}}))
+
+	status := LatestSpecialization([]apiv1.Event{
+		event(&SpecializationStatus{Succeeded: true, Time: metav1.NewTime(now.Add(-time.Minute))}),
+		event(&SpecializationStatus{Error: "fetch failed", Time: metav1.NewTime(now)}),
+		{Reason: "UpdateFailed"},
+		event(&SpecializationStatus{Error: "older", Time: metav1.NewTime(now.Add(-2 * time.Minute))}),
+	})
+	if assert.NotNil(t, status) {
+		assert.False(t, status.Succeeded)
+		assert.Equal(t, "fetch failed", status.Error)
+	}
+}
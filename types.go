@@ -17,6 +17,8 @@ limitations under the License.
 package fission
 
 import (
+	"time"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apiv1 "k8s.io/client-go/pkg/api/v1"
 )
@@ -138,6 +140,58 @@ type (
 
 		// InvokeStrategy is a set of controls which affect how function executes
 		InvokeStrategy InvokeStrategy
+
+		// (Optional) Where the function's pods run. Only newdeploy
+		// functions have pods of their own, so it's rejected for
+		// other executor types. Overrides the scheduling of the
+		// environment.
+		Scheduling *Scheduling `json:"scheduling,omitempty"`
+
+		// (Optional) What the function's pods run as, for newdeploy
+		// functions. Overrides the security settings of the
+		// environment.
+		Security *PodSecurity `json:"security,omitempty"`
+
+		// (Optional) What the function's pods may connect to, besides
+		// Fission itself, when the executor manages network policies.
+		Network *NetworkAccess `json:"network,omitempty"`
+
+		// (Optional) Probes of the container running the function, for
+		// newdeploy functions. Pods get requests once the readiness
+		// probe passes.
+		Probes *FunctionProbes `json:"probes,omitempty"`
+
+		// (Optional) Environment variables of the function. Values may
+		// be literal, or taken from a key of a secret or configmap in
+		// the function's namespace. Newdeploy functions get literal
+		// values in their container; all values are passed to the
+		// environment server when the function is loaded, which
+		// needs an environment of version 2 or later.
+		Env []apiv1.EnvVar `json:"env,omitempty"`
+	}
+
+	// FunctionStatus isn't stored with the function, since updating it
+	// would change the function's resource version, which the function's
+	// services are cached by. The executor records it on the function's
+	// events, and the controller fills it in from them when the function
+	// is read.
+	FunctionStatus struct {
+		// The latest recorded attempt to specialize a pod for the
+		// function. Events expire, so this may be missing.
+		Specialization *SpecializationStatus `json:"specialization,omitempty"`
+	}
+
+	SpecializationStatus struct {
+		Succeeded    bool         `json:"succeeded"`
+		Time         metav1.Time  `json:"time"`
+		ExecutorType ExecutorType `json:"executorType,omitempty"`
+		PodName      string       `json:"podName,omitempty"`
+		Error        string       `json:"error,omitempty"`
+
+		// Responses of the fetcher and the environment server to
+		// the failed request, if it got that far
+		FetcherResponse    string `json:"fetcherResponse,omitempty"`
+		SpecializeResponse string `json:"specializeResponse,omitempty"`
 	}
 
 	/*InvokeStrategy is a set of controls over how the function executes.
@@ -162,15 +216,45 @@ type (
 	asynchronous nature. If MinScale is greater than 0 then MinScale number of pods are
 	created at the time of creation of function. This ensures faster response during first
 	invocation at the cost of consuming resources.
+	Newdeploy functions with a MinScale of 0 are scaled down to zero pods after their
+	IdleTimeout, and scaled back up on the next invocation.
 
 	MaxScale is the maximum number of pods that function will scale to based on TargetCPUPercent
 	and resources allocated to the function pod.
+	For poolmgr functions with a TargetConcurrency, it's the maximum number of specialized
+	pods; another pod is specialized when all of them are saturated.
+
+	IdleTimeout is the number of seconds a specialized pod may stay idle before it is
+	reaped, or a newdeploy function is scaled down. If it's not set, the executor's default is used. A value of 0 or -1 keeps
+	the function warm forever.
+
+	TargetConcurrency, if greater than 0, scales newdeploy functions on the number of
+	in-flight requests per pod reported by the routers, instead of on TargetCPUPercent.
+	Setting it opts poolmgr functions in to scaling out across several specialized pods,
+	up to MaxScale; it's the number of in-flight requests at which a pod is saturated.
+
+	WarmSchedule keeps the function warm at known busy times, whatever its traffic.
+	While one of its windows is open, poolmgr functions keep at least MinInstances
+	specialized pods, and newdeploy functions at least MinInstances replicas, both
+	up to MaxScale. Idle pods aren't reaped during a window.
 	*/
 	ExecutionStrategy struct {
-		ExecutorType     ExecutorType
-		MinScale         int
-		MaxScale         int
-		TargetCPUPercent int
+		ExecutorType      ExecutorType
+		MinScale          int
+		MaxScale          int
+		TargetCPUPercent  int
+		IdleTimeout       *int
+		TargetConcurrency int
+		WarmSchedule      []WarmWindow
+	}
+
+	// WarmWindow is a window of time during which a function is kept warm.
+	// Windows open at the times of Cron, which has the format of time
+	// triggers, and stay open for Duration seconds.
+	WarmWindow struct {
+		Cron         string
+		Duration     int
+		MinInstances int
 	}
 
 	FunctionReferenceType string
@@ -219,6 +303,15 @@ type (
 		// - ImagePullPolicy
 		// (optional)
 		Container *apiv1.Container `json:"container,omitempty"`
+
+		// LocalCommand is the command line that starts the runtime
+		// server as a local process, used by the "local" executor
+		// type instead of Image. $PORT, $USERFUNC_DIR, $CODE_PATH,
+		// $SECRETS_DIR and $CONFIGMAPS_DIR are expanded in the
+		// arguments and also set in the process environment. The
+		// server must listen on $PORT. Only allowed when the local
+		// executor is enabled with ENABLE_LOCAL_EXECUTOR. (optional)
+		LocalCommand []string `json:"localcommand,omitempty"`
 	}
 	Builder struct {
 		// Image for containing the language runtime.
@@ -256,6 +349,8 @@ type (
 		// Optional, defaults to 'AllowedFunctionsPerContainerSingle'
 		AllowedFunctionsPerContainer AllowedFunctionsPerContainer `json:"allowedFunctionsPerContainer,omitempty"`
 
+		// Whether function pods may connect outside the cluster, when
+		// Istio is enabled or the executor manages network policies.
 		// Optional, defaults to 'false'
 		AllowAccessToExternalNetwork bool `json:"allowAccessToExternalNetwork,omitempty"`
 
@@ -265,9 +360,114 @@ type (
 		// The initial pool size for environment
 		Poolsize int `json:"poolsize,omitempty"`
 
+		// (Optional) Adaptive pool sizing. If set, the pool manager resizes
+		// the environment's pool between the given bounds as demand for
+		// specialized pods changes, starting from Poolsize.
+		PoolScaling *PoolScaling `json:"poolScaling,omitempty"`
+
 		// The grace time for pod to perform connection draining before termination. The unit is in seconds.
 		// Optional, defaults to 360 seconds
 		TerminationGracePeriod int64
+
+		// (Optional) Where the environment's pool, builder and newdeploy
+		// function pods run.
+		Scheduling *Scheduling `json:"scheduling,omitempty"`
+
+		// (Optional) What the environment's pool and newdeploy function
+		// pods run as.
+		Security *PodSecurity `json:"security,omitempty"`
+	}
+
+	// EnvironmentStatus is maintained by the executor.
+	EnvironmentStatus struct {
+		// The latest replacement of the environment's pool, after the
+		// environment changed.
+		PoolRollout *PoolRolloutStatus `json:"poolRollout,omitempty"`
+	}
+
+	// PoolRolloutStatus tracks the functions moved from the old pool
+	// to the new one. The old pool is deleted once all are moved.
+	PoolRolloutStatus struct {
+		Status    string      `json:"status"`
+		StartTime metav1.Time `json:"startTime"`
+		Time      metav1.Time `json:"time"`
+		Replaced  int         `json:"replaced"`
+		Total     int         `json:"total"`
+		Message   string      `json:"message,omitempty"`
+	}
+
+	// Scheduling controls which nodes pods are scheduled on. Each field
+	// set at the function level replaces the environment's. Pod
+	// priority classes aren't supported: the Kubernetes API this
+	// release is built against has no pod priority.
+	Scheduling struct {
+		NodeSelector map[string]string  `json:"nodeSelector,omitempty"`
+		Affinity     *apiv1.Affinity    `json:"affinity,omitempty"`
+		Tolerations  []apiv1.Toleration `json:"tolerations,omitempty"`
+
+		// SpreadTopologyKeys are node labels, such as
+		// failure-domain.beta.kubernetes.io/zone, across whose values
+		// the pods of a pool, builder or function are spread where
+		// possible. They add preferred pod anti-affinity terms to the
+		// affinity.
+		SpreadTopologyKeys []string `json:"spreadTopologyKeys,omitempty"`
+	}
+
+	// PodSecurity sets the identity and privileges of function pods. The
+	// security context and seccomp profile apply to the container
+	// running the function; the fetcher keeps its own. Each field set at
+	// the function level replaces the environment's.
+	PodSecurity struct {
+		// Service account of the pods, in the function namespace. The
+		// fetcher still talks to Kubernetes as fission-fetcher.
+		ServiceAccountName string                 `json:"serviceAccountName,omitempty"`
+		SecurityContext    *apiv1.SecurityContext `json:"securityContext,omitempty"`
+
+		// Seccomp profile: "runtime/default", "docker/default",
+		// "unconfined" or "localhost/<profile>"
+		SeccompProfile string `json:"seccompProfile,omitempty"`
+	}
+
+	// NetworkAccess lists the destinations a function's pods are allowed
+	// to connect to. Pods of environments that allow access to the
+	// external network may connect anywhere.
+	NetworkAccess struct {
+		// IP ranges, e.g. "10.0.0.0/8"; a single address is a /32
+		EgressCIDRs []string `json:"egressCIDRs,omitempty"`
+
+		// Namespaces whose pods may be connected to. They must be
+		// labeled fission.io/namespace=<name> by whoever sets them
+		// up; the executor only labels the Fission namespace.
+		EgressNamespaces []string `json:"egressNamespaces,omitempty"`
+	}
+
+	FunctionProbes struct {
+		Readiness *HTTPProbe `json:"readiness,omitempty"`
+		Liveness  *HTTPProbe `json:"liveness,omitempty"`
+	}
+
+	// HTTPProbe is an HTTP GET on the function's port, which the
+	// environment passes on to the function. Unset fields take the
+	// Kubernetes defaults.
+	HTTPProbe struct {
+		// Path of the GET, which the environment server must route to
+		// the function. Optional; defaults to "/".
+		Path                string `json:"path"`
+		InitialDelaySeconds int32  `json:"initialDelaySeconds,omitempty"`
+		PeriodSeconds       int32  `json:"periodSeconds,omitempty"`
+		TimeoutSeconds      int32  `json:"timeoutSeconds,omitempty"`
+		SuccessThreshold    int32  `json:"successThreshold,omitempty"`
+		FailureThreshold    int32  `json:"failureThreshold,omitempty"`
+	}
+
+	PoolScaling struct {
+		// Bounds of the number of idle pods kept in the pool
+		MinPoolsize int `json:"minPoolsize"`
+		MaxPoolsize int `json:"maxPoolsize"`
+
+		// Minimum number of seconds between a resize and a following
+		// scale down. Optional, defaults to 60 seconds.
+		Cooldown int `json:"cooldown,omitempty"`
 	}
 
 	AllowedFunctionsPerContainer string
@@ -281,6 +481,111 @@ type (
 		RelativeURL       string            `json:"relativeurl"`
 		Method            string            `json:"method"`
 		FunctionReference FunctionReference `json:"functionref"`
+
+		// Idempotency enables response caching for requests carrying an
+		// Idempotency-Key header. Optional; disabled if unspecified.
+		Idempotency *IdempotencyConfig `json:"idempotency,omitempty"`
+
+		// Compression enables gzip or deflate compression of function
+		// responses, as negotiated by the request's Accept-Encoding.
+		// Optional; disabled if unspecified.
+		Compression *CompressionConfig `json:"compression,omitempty"`
+
+		// AccessControl restricts which client addresses may invoke the
+		// trigger. Optional; all clients are allowed if unspecified.
+		AccessControl *AccessControlConfig `json:"accesscontrol,omitempty"`
+
+		// RequestSchema is a JSON Schema that request bodies must
+		// satisfy. The router rejects non-conforming requests with a 400
+		// before invoking the function. An empty body is validated as
+		// null, so allow "null" in the schema's type if the body is
+		// optional. Optional.
+		RequestSchema *JSONSchemaReference `json:"requestschema,omitempty"`
+
+		// Documentation annotates the trigger in the generated OpenAPI
+		// document. It has no effect on routing. Optional.
+		Documentation *HTTPTriggerDocumentation `json:"documentation,omitempty"`
+	}
+
+	// IdempotencyConfig controls how the router deduplicates requests that
+	// carry an Idempotency-Key header. The first response for a key is cached
+	// and replayed to later requests with the same key, instead of invoking
+	// the function again. A concurrent duplicate waits for the in-flight
+	// response.
+	IdempotencyConfig struct {
+		// TTL is the number of seconds a response stays cached.
+		// Optional; default 300.
+		TTL int `json:"ttl,omitempty"`
+	}
+
+	// CompressionConfig controls which function responses the router
+	// compresses. Responses that already have a Content-Encoding are
+	// relayed as-is.
+	CompressionConfig struct {
+		// MinSize is the smallest response body, in bytes, that is
+		// compressed. Optional; default 1024.
+		MinSize int `json:"minsize,omitempty"`
+
+		// ContentTypes lists the media types that are compressed. A
+		// trailing "/*" matches all subtypes, e.g. "text/*". Optional;
+		// defaults to text/*, application/json, application/javascript
+		// and application/xml.
+		ContentTypes []string `json:"contenttypes,omitempty"`
+	}
+
+	// AccessControlConfig holds CIDR allow and deny lists for an HTTP
+	// trigger. Entries are CIDRs ("10.0.0.0/8") or single addresses.
+	// Requests denied by these lists get a 403. Unless the function
+	// also has an open trigger, the router's internal route to the
+	// function only lets in clients one of its triggers allows, so
+	// non-http triggers of the function must be allowed too.
+	AccessControlConfig struct {
+		// Allow lists the allowed client networks. If non-empty, clients
+		// outside all of them are denied.
+		Allow []string `json:"allow,omitempty"`
+
+		// Deny lists the denied client networks. Deny takes precedence
+		// over Allow.
+		Deny []string `json:"deny,omitempty"`
+
+		// TrustedProxies lists the networks of proxies, such as load
+		// balancers and ingress controllers, whose X-Forwarded-For
+		// entries are trusted. The client address is the right-most
+		// X-Forwarded-For entry that isn't a trusted proxy. Optional; if
+		// empty, X-Forwarded-For is ignored.
+		TrustedProxies []string `json:"trustedproxies,omitempty"`
+	}
+
+	// JSONSchemaReference specifies a JSON Schema document, either inline
+	// or stored in a ConfigMap. Exactly one of Inline and ConfigMap must
+	// be set.
+	JSONSchemaReference struct {
+		// Inline JSON Schema document.
+		Inline string `json:"inline,omitempty"`
+
+		// ConfigMap containing the schema document.
+		ConfigMap *ConfigMapReference `json:"configmap,omitempty"`
+
+		// Key of the schema document in the ConfigMap. Optional;
+		// default "schema.json".
+		Key string `json:"key,omitempty"`
+	}
+
+	// HTTPTriggerDocumentation describes an HTTP trigger's API for the
+	// generated OpenAPI document.
+	HTTPTriggerDocumentation struct {
+		// Summary is a short description of the operation.
+		Summary string `json:"summary,omitempty"`
+
+		// Description is a longer description of the operation.
+		Description string `json:"description,omitempty"`
+
+		// RequestSchema documents the request body. Optional; defaults
+		// to the trigger's RequestSchema.
+		RequestSchema *JSONSchemaReference `json:"requestschema,omitempty"`
+
+		// ResponseSchema documents the response body. Optional.
+		ResponseSchema *JSONSchemaReference `json:"responseschema,omitempty"`
 	}
 
 	KubernetesWatchTriggerSpec struct {
@@ -342,12 +647,85 @@ type (
 
 		// Metatdata
 		FunctionMetadata *metav1.ObjectMeta
+
+		// Environment variables of the function, to be set
+		// before the function is loaded. Optional.
+		Env map[string]string `json:"env,omitempty"`
+	}
+)
+
+//
+// Router-executor interface.
+//
+type (
+	// TapServicesRequest is sent periodically by each router to tell the
+	// executor which function services it used, and how busy they were.
+	TapServicesRequest struct {
+		// Reporter identifies the router instance, so that reports
+		// from several routers can be added up.
+		Reporter string `json:"reporter"`
+
+		Services []ServiceTap `json:"services"`
+	}
+
+	ServiceTap struct {
+		// URL of the function service
+		ServiceUrl string `json:"serviceUrl"`
+
+		// Average number of in-flight requests to the service since
+		// the router's previous report
+		Concurrency float64 `json:"concurrency"`
+	}
+)
+
+//
+// Executor management interface.
+//
+type (
+	// FunctionServiceInfo describes a function service cached by the
+	// executor: the pod, deployment or process serving a function.
+	FunctionServiceInfo struct {
+		Name        string            `json:"name"`
+		Function    metav1.ObjectMeta `json:"function"`
+		Environment metav1.ObjectMeta `json:"environment"`
+		Executor    ExecutorType      `json:"executorType"`
+
+		// Host:Port the function service is reached at
+		Address string `json:"address"`
+
+		// Kubernetes objects backing the function service, if any
+		KubernetesObjects []apiv1.ObjectReference `json:"kubernetesObjects,omitempty"`
+
+		// Creation and last access time
+		Ctime time.Time `json:"ctime"`
+		Atime time.Time `json:"atime"`
 	}
 )
 
 const EXECUTOR_INSTANCEID_LABEL string = "executorInstanceId"
 const POOLMGR_INSTANCEID_LABEL string = "poolmgrInstanceId"
 
+// Annotation of a function's event, holding the SpecializationStatus it
+// records as JSON.
+const ANNOTATION_SPECIALIZATION_STATUS = "fission.io/specialization-status"
+
+// Labels of the network policies the executor manages, and of the
+// namespaces they refer to, since policies select namespaces by label.
+const (
+	NETWORK_POLICY_LABEL = "fission.io/network-policy"
+	NAMESPACE_NAME_LABEL = "fission.io/namespace"
+)
+
+// Annotation prefix setting the seccomp profile of a container, followed
+// by the container's name
+const SECCOMP_CONTAINER_ANNOTATION_PREFIX = "container.seccomp.security.alpha.kubernetes.io/"
+
+const (
+	RolloutStatusProgressing = "progressing"
+	RolloutStatusSucceeded   = "succeeded"
+	RolloutStatusFailed      = "failed"
+)
+
 const (
 	ChecksumTypeSHA256 ChecksumType = "sha256"
 )
@@ -377,6 +755,7 @@ const (
 const (
 	ExecutorTypePoolmgr   = "poolmgr"
 	ExecutorTypeNewdeploy = "newdeploy"
+	ExecutorTypeLocal     = "local"
 )
 
 const (
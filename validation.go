@@ -19,13 +19,16 @@ package fission
 import (
 	"fmt"
 	"net/http"
+	"reflect"
 	"regexp"
 	"strings"
 
 	"github.com/hashicorp/go-multierror"
 	nsUtil "github.com/nats-io/nats-streaming-server/util"
 	"github.com/robfig/cron"
+	"github.com/xeipuuv/gojsonschema"
 	"k8s.io/apimachinery/pkg/util/validation"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
 )
 
 const (
@@ -116,9 +119,12 @@ func ValidateKubeLabel(field string, labels map[string]string) error {
 		// Example: XXX -> YYY
 		// KubernetesWatchTriggerSpec.LabelSelector.Key: Invalid value: XXX
 		// KubernetesWatchTriggerSpec.LabelSelector.Value: Invalid value: YYY
-		result = multierror.Append(result,
-			MakeValidationErr(ErrorInvalidValue, fmt.Sprintf("%v.Key", field), k, validation.IsQualifiedName(k)...),
-			MakeValidationErr(ErrorInvalidValue, fmt.Sprintf("%v.Value", field), v, validation.IsValidLabelValue(v)...))
+		if e := validation.IsQualifiedName(k); len(e) > 0 {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, fmt.Sprintf("%v.Key", field), k, e...))
+		}
+		if e := validation.IsValidLabelValue(v); len(e) > 0 {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, fmt.Sprintf("%v.Value", field), v, e...))
+		}
 	}
 
 	return result.ErrorOrNil()
@@ -166,6 +172,19 @@ func IsTopicValid(mqType MessageQueueType, topic string) bool {
 	return false
 }
 
+func ValidateCIDRs(field string, cidrs []string) error {
+	var result *multierror.Error
+
+	for _, c := range cidrs {
+		_, err := ParseCIDROrIP(c)
+		if err != nil {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, field, c, "not a valid CIDR or IP address"))
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
 func IsValidCronSpec(spec string) error {
 	_, err := cron.Parse(spec)
 	return err
@@ -259,6 +278,27 @@ func (ref FunctionPackageRef) Validate() error {
 	return result.ErrorOrNil()
 }
 
+// ValidateEnvSupport checks that the function's environment can apply its
+// environment variables. Environment servers set them when loading the
+// function from a v2 load request; with a v1 environment, only newdeploy
+// functions get them, and only literal values, in their container.
+func (spec FunctionSpec) ValidateEnvSupport(env *EnvironmentSpec) error {
+	if len(spec.Env) == 0 || env.Version >= 2 {
+		return nil
+	}
+	if spec.InvokeStrategy.ExecutionStrategy.ExecutorType != ExecutorTypeNewdeploy {
+		return MakeValidationErr(ErrorUnsupportedType, "FunctionSpec.Env", env.Version,
+			"environment variables need an environment of version 2 or later, or the newdeploy executor")
+	}
+	for _, v := range spec.Env {
+		if v.ValueFrom != nil {
+			return MakeValidationErr(ErrorUnsupportedType, "FunctionSpec.Env.ValueFrom", v.Name,
+				"values from secrets and configmaps need an environment of version 2 or later")
+		}
+	}
+	return nil
+}
+
 func (spec FunctionSpec) Validate() error {
 	var result *multierror.Error
 
@@ -277,10 +317,50 @@ func (spec FunctionSpec) Validate() error {
 		result = multierror.Append(result, c.Validate())
 	}
 
-	if spec.InvokeStrategy != (InvokeStrategy{}) {
+	if !reflect.DeepEqual(spec.InvokeStrategy, InvokeStrategy{}) {
 		result = multierror.Append(result, spec.InvokeStrategy.Validate())
 	}
 
+	if spec.Scheduling != nil {
+		result = multierror.Append(result, spec.Scheduling.Validate())
+		if spec.InvokeStrategy.ExecutionStrategy.ExecutorType != ExecutorTypeNewdeploy {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidObject, "FunctionSpec.Scheduling", spec.Scheduling,
+				"only newdeploy functions have pods of their own; set the scheduling of the environment instead"))
+		}
+	}
+
+	if spec.Security != nil {
+		result = multierror.Append(result, spec.Security.Validate())
+	}
+
+	if spec.Network != nil {
+		result = multierror.Append(result, spec.Network.Validate())
+	}
+
+	if spec.Probes != nil {
+		if spec.Probes.Readiness != nil {
+			result = multierror.Append(result, spec.Probes.Readiness.Validate())
+		}
+		if spec.Probes.Liveness != nil {
+			result = multierror.Append(result, spec.Probes.Liveness.Validate())
+		}
+	}
+
+	for _, v := range spec.Env {
+		if e := validation.IsCIdentifier(v.Name); len(e) > 0 {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "FunctionSpec.Env.Name", v.Name, e...))
+		}
+		if v.ValueFrom == nil {
+			continue
+		}
+		if len(v.Value) > 0 {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "FunctionSpec.Env.Value", v.Value, "value can't be set along with valueFrom"))
+		}
+		if v.ValueFrom.SecretKeyRef == nil && v.ValueFrom.ConfigMapKeyRef == nil {
+			result = multierror.Append(result, MakeValidationErr(ErrorUnsupportedType, "FunctionSpec.Env.ValueFrom", v.Name, "only secret and configmap keys are supported"))
+		}
+	}
+
 	return result.ErrorOrNil()
 }
 
@@ -302,9 +382,13 @@ func (es ExecutionStrategy) Validate() error {
 	var result *multierror.Error
 
 	switch es.ExecutorType {
-	case ExecutorTypeNewdeploy, ExecutorTypePoolmgr: // no op
+	case ExecutorTypeNewdeploy, ExecutorTypePoolmgr, ExecutorTypeLocal: // no op
 	default:
-		result = multierror.Append(result, MakeValidationErr(ErrorUnsupportedType, "ExecutionStrategy.ExecutorType", es.ExecutorType, "not a valid executor type"))
+		// Other executor types may be registered with the executor;
+		// only check that the name is well-formed.
+		if len(validation.IsDNS1123Label(string(es.ExecutorType))) > 0 {
+			result = multierror.Append(result, MakeValidationErr(ErrorUnsupportedType, "ExecutionStrategy.ExecutorType", es.ExecutorType, "not a valid executor type"))
+		}
 	}
 
 	if es.MinScale < 0 {
@@ -319,6 +403,36 @@ func (es ExecutionStrategy) Validate() error {
 		result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "ExecutionStrategy.TargetCPUPercent", es.TargetCPUPercent, "TargetCPUPercent must be a value between 1 - 100"))
 	}
 
+	if es.TargetConcurrency < 0 {
+		result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "ExecutionStrategy.TargetConcurrency", es.TargetConcurrency, "TargetConcurrency must be greater or equal to 0"))
+	}
+
+	if es.IdleTimeout != nil && *es.IdleTimeout < -1 {
+		result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "ExecutionStrategy.IdleTimeout", *es.IdleTimeout, "IdleTimeout must be -1, 0 or a number of seconds"))
+	}
+
+	for _, w := range es.WarmSchedule {
+		result = multierror.Append(result, w.Validate())
+	}
+
+	return result.ErrorOrNil()
+}
+
+func (w WarmWindow) Validate() error {
+	var result *multierror.Error
+
+	if err := IsValidCronSpec(w.Cron); err != nil {
+		result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "WarmWindow.Cron", w.Cron, "not a valid cron spec"))
+	}
+
+	if w.Duration <= 0 {
+		result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "WarmWindow.Duration", w.Duration, "Duration must be a number of seconds greater than 0"))
+	}
+
+	if w.MinInstances < 1 {
+		result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "WarmWindow.MinInstances", w.MinInstances, "MinInstances must be greater than 0"))
+	}
+
 	return result.ErrorOrNil()
 }
 
@@ -380,6 +494,118 @@ func (spec EnvironmentSpec) Validate() error {
 		result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "EnvironmentSpec.Poolsize", spec.Poolsize, "Poolsize must be greater or equal to 0"))
 	}
 
+	if spec.PoolScaling != nil {
+		result = multierror.Append(result, spec.PoolScaling.Validate())
+	}
+
+	if spec.Scheduling != nil {
+		result = multierror.Append(result, spec.Scheduling.Validate())
+	}
+
+	if spec.Security != nil {
+		result = multierror.Append(result, spec.Security.Validate())
+	}
+
+	return result.ErrorOrNil()
+}
+
+func (s Scheduling) Validate() error {
+	var result *multierror.Error
+
+	result = multierror.Append(result, ValidateKubeLabel("Scheduling.NodeSelector", s.NodeSelector))
+
+	for _, t := range s.Tolerations {
+		switch t.Operator {
+		case "", apiv1.TolerationOpEqual: // no op
+		case apiv1.TolerationOpExists:
+			if len(t.Value) > 0 {
+				result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "Scheduling.Tolerations.Value", t.Value, "value must be empty with the Exists operator"))
+			}
+		default:
+			result = multierror.Append(result, MakeValidationErr(ErrorUnsupportedType, "Scheduling.Tolerations.Operator", t.Operator, "not a valid toleration operator"))
+		}
+
+		switch t.Effect {
+		case "", apiv1.TaintEffectNoSchedule, apiv1.TaintEffectPreferNoSchedule, apiv1.TaintEffectNoExecute: // no op
+		default:
+			result = multierror.Append(result, MakeValidationErr(ErrorUnsupportedType, "Scheduling.Tolerations.Effect", t.Effect, "not a valid taint effect"))
+		}
+	}
+
+	for _, key := range s.SpreadTopologyKeys {
+		if e := validation.IsQualifiedName(key); len(e) > 0 {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "Scheduling.SpreadTopologyKeys", key, e...))
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
+func (ps PodSecurity) Validate() error {
+	var result *multierror.Error
+
+	if len(ps.ServiceAccountName) > 0 {
+		if e := validation.IsDNS1123Subdomain(ps.ServiceAccountName); len(e) > 0 {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "PodSecurity.ServiceAccountName", ps.ServiceAccountName, e...))
+		}
+	}
+
+	switch {
+	case len(ps.SeccompProfile) == 0, ps.SeccompProfile == "runtime/default",
+		ps.SeccompProfile == "docker/default", ps.SeccompProfile == "unconfined": // no op
+	case strings.HasPrefix(ps.SeccompProfile, "localhost/") && len(ps.SeccompProfile) > len("localhost/"):
+	default:
+		result = multierror.Append(result, MakeValidationErr(ErrorUnsupportedType, "PodSecurity.SeccompProfile", ps.SeccompProfile, "not a valid seccomp profile"))
+	}
+
+	return result.ErrorOrNil()
+}
+
+func (na NetworkAccess) Validate() error {
+	var result *multierror.Error
+
+	for _, cidr := range na.EgressCIDRs {
+		if _, err := ParseCIDROrIP(cidr); err != nil {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "NetworkAccess.EgressCIDRs", cidr, err.Error()))
+		}
+	}
+	for _, ns := range na.EgressNamespaces {
+		result = multierror.Append(result, ValidateKubeName("NetworkAccess.EgressNamespaces", ns))
+	}
+
+	return result.ErrorOrNil()
+}
+
+func (p HTTPProbe) Validate() error {
+	var result *multierror.Error
+
+	if len(p.Path) > 0 && !strings.HasPrefix(p.Path, "/") {
+		result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "HTTPProbe.Path", p.Path, "path must start with /"))
+	}
+	if p.InitialDelaySeconds < 0 || p.PeriodSeconds < 0 || p.TimeoutSeconds < 0 ||
+		p.SuccessThreshold < 0 || p.FailureThreshold < 0 {
+		result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "HTTPProbe", p, "delays, timeouts and thresholds must not be negative"))
+	}
+
+	return result.ErrorOrNil()
+}
+
+func (ps PoolScaling) Validate() error {
+	var result *multierror.Error
+
+	// an empty pool would never see any demand to grow from
+	if ps.MinPoolsize < 1 {
+		result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "PoolScaling.MinPoolsize", ps.MinPoolsize, "MinPoolsize must be greater or equal to 1"))
+	}
+
+	if ps.MaxPoolsize < ps.MinPoolsize {
+		result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "PoolScaling.MaxPoolsize", ps.MaxPoolsize, "MaxPoolsize must be greater or equal to MinPoolsize"))
+	}
+
+	if ps.Cooldown < 0 {
+		result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "PoolScaling.Cooldown", ps.Cooldown, "Cooldown must be greater or equal to 0"))
+	}
+
 	return result.ErrorOrNil()
 }
 
@@ -402,6 +628,62 @@ func (spec HTTPTriggerSpec) Validate() error {
 		}
 	}
 
+	if spec.Idempotency != nil && spec.Idempotency.TTL < 0 {
+		result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "HTTPTriggerSpec.Idempotency.TTL", spec.Idempotency.TTL, "TTL must be greater or equal to 0"))
+	}
+
+	if spec.Compression != nil {
+		if spec.Compression.MinSize < 0 {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "HTTPTriggerSpec.Compression.MinSize", spec.Compression.MinSize, "MinSize must be greater or equal to 0"))
+		}
+		for _, ct := range spec.Compression.ContentTypes {
+			if !strings.Contains(ct, "/") {
+				result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, "HTTPTriggerSpec.Compression.ContentTypes", ct, "not a valid media type"))
+			}
+		}
+	}
+
+	if spec.AccessControl != nil {
+		result = multierror.Append(result,
+			ValidateCIDRs("HTTPTriggerSpec.AccessControl.Allow", spec.AccessControl.Allow),
+			ValidateCIDRs("HTTPTriggerSpec.AccessControl.Deny", spec.AccessControl.Deny),
+			ValidateCIDRs("HTTPTriggerSpec.AccessControl.TrustedProxies", spec.AccessControl.TrustedProxies))
+	}
+
+	if spec.RequestSchema != nil {
+		result = multierror.Append(result, spec.RequestSchema.Validate("HTTPTriggerSpec.RequestSchema"))
+	}
+
+	if spec.Documentation != nil {
+		if spec.Documentation.RequestSchema != nil {
+			result = multierror.Append(result, spec.Documentation.RequestSchema.Validate("HTTPTriggerSpec.Documentation.RequestSchema"))
+		}
+		if spec.Documentation.ResponseSchema != nil {
+			result = multierror.Append(result, spec.Documentation.ResponseSchema.Validate("HTTPTriggerSpec.Documentation.ResponseSchema"))
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
+func (ref JSONSchemaReference) Validate(field string) error {
+	var result *multierror.Error
+
+	if (len(ref.Inline) > 0) == (ref.ConfigMap != nil) {
+		result = multierror.Append(result, MakeValidationErr(ErrorInvalidObject, field, ref, "exactly one of inline or configmap must be specified"))
+	}
+
+	if len(ref.Inline) > 0 {
+		_, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(ref.Inline))
+		if err != nil {
+			result = multierror.Append(result, MakeValidationErr(ErrorInvalidValue, fmt.Sprintf("%v.Inline", field), ref.Inline, fmt.Sprintf("not a valid JSON Schema: %v", err)))
+		}
+	}
+
+	if ref.ConfigMap != nil {
+		result = multierror.Append(result, ref.ConfigMap.Validate())
+	}
+
 	return result.ErrorOrNil()
 }
 
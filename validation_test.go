@@ -0,0 +1,87 @@
+/*
+Copyright 2018 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+)
+
+func TestHTTPProbeValidate(t *testing.T) {
+	assert.NoError(t, HTTPProbe{}.Validate())
+	assert.NoError(t, HTTPProbe{Path: "/", PeriodSeconds: 5}.Validate())
+	assert.NoError(t, HTTPProbe{Path: "/healthz"}.Validate())
+	assert.Error(t, HTTPProbe{Path: "healthz"}.Validate())
+	assert.Error(t, HTTPProbe{Path: "/", FailureThreshold: -1}.Validate())
+}
+
+func TestValidateEnvSupport(t *testing.T) {
+	literal := apiv1.EnvVar{Name: "FOO", Value: "bar"}
+	fromSecret := apiv1.EnvVar{
+		Name: "TOKEN",
+		ValueFrom: &apiv1.EnvVarSource{
+			SecretKeyRef: &apiv1.SecretKeySelector{
+				LocalObjectReference: apiv1.LocalObjectReference{Name: "creds"},
+				Key:                  "token",
+			},
+		},
+	}
+	newdeploy := InvokeStrategy{
+		ExecutionStrategy: ExecutionStrategy{ExecutorType: ExecutorTypeNewdeploy},
+	}
+	v1 := &EnvironmentSpec{Version: 1}
+	v2 := &EnvironmentSpec{Version: 2}
+
+	assert.NoError(t, FunctionSpec{}.ValidateEnvSupport(v1))
+	assert.NoError(t, FunctionSpec{Env: []apiv1.EnvVar{literal, fromSecret}}.ValidateEnvSupport(v2))
+
+	// v1 environment servers don't set them
+	assert.Error(t, FunctionSpec{Env: []apiv1.EnvVar{literal}}.ValidateEnvSupport(v1))
+
+	// newdeploy puts literal values in the container
+	assert.NoError(t, FunctionSpec{Env: []apiv1.EnvVar{literal}, InvokeStrategy: newdeploy}.ValidateEnvSupport(v1))
+	assert.Error(t, FunctionSpec{Env: []apiv1.EnvVar{fromSecret}, InvokeStrategy: newdeploy}.ValidateEnvSupport(v1))
+}
+
+func TestJSONSchemaReferenceValidate(t *testing.T) {
+	assert.NoError(t, JSONSchemaReference{Inline: `{"type": "object"}`}.Validate("RequestSchema"))
+	assert.Error(t, JSONSchemaReference{Inline: `{"type": `}.Validate("RequestSchema"))
+	assert.Error(t, JSONSchemaReference{Inline: `{"type": 1}`}.Validate("RequestSchema"))
+	assert.Error(t, JSONSchemaReference{}.Validate("RequestSchema"))
+}
+
+func TestFunctionSchedulingValidate(t *testing.T) {
+	scheduling := &Scheduling{NodeSelector: map[string]string{"pool": "dedicated"}}
+	newdeploy := InvokeStrategy{
+		StrategyType:      StrategyTypeExecution,
+		ExecutionStrategy: ExecutionStrategy{ExecutorType: ExecutorTypeNewdeploy, MaxScale: 1, TargetCPUPercent: 80},
+	}
+	poolmgr := InvokeStrategy{
+		StrategyType:      StrategyTypeExecution,
+		ExecutionStrategy: ExecutionStrategy{ExecutorType: ExecutorTypePoolmgr, MaxScale: 1, TargetCPUPercent: 80},
+	}
+
+	assert.NoError(t, FunctionSpec{Scheduling: scheduling, InvokeStrategy: newdeploy}.Validate())
+
+	// poolmgr functions run in the environment's pool pods
+	assert.Error(t, FunctionSpec{Scheduling: scheduling, InvokeStrategy: poolmgr}.Validate())
+	assert.Error(t, FunctionSpec{Scheduling: scheduling}.Validate())
+
+	assert.Error(t, Scheduling{SpreadTopologyKeys: []string{"not a label"}}.Validate())
+}